@@ -15,7 +15,13 @@ var clearCmd = &cobra.Command{
 	Short: "Clear out the project's workspace",
 	Long:  `Clear out the project's workspace`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		projectConfig, err := config.LoadProjectConfigFromFile()
+		projectDir, _, err := currentProjectDir()
+		if err != nil {
+			log.Info("Run " + colorstring.Green("gows init") + " to initialize a workspace & gows config for this project")
+			return err
+		}
+
+		projectConfig, err := config.LoadProjectConfigFromDir(projectDir)
 		if err != nil {
 			log.Info("Run " + colorstring.Green("gows init") + " to initialize a workspace & gows config for this project")
 			return fmt.Errorf("Failed to read Project Config: %s", err)
@@ -25,7 +31,7 @@ var clearCmd = &cobra.Command{
 			return fmt.Errorf("Package Name is empty")
 		}
 
-		if err := InitGOWS(projectConfig.PackageName, true); err != nil {
+		if err := initGOWS(projectDir, projectConfig.PackageName, []string{}, true); err != nil {
 			return fmt.Errorf("Failed to initialize: %s", err)
 		}
 
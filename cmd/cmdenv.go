@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/gows/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// gowsWorkspaceEnvKey is set to the workspace's root path (the GOPATH) for the commands run by gows
+	gowsWorkspaceEnvKey = "GOWS_WORKSPACE"
+	// gowsProjectDirEnvKey is set to the project's (original) directory for the commands run by gows
+	gowsProjectDirEnvKey = "GOWS_PROJECT_DIR"
+)
+
+// commandEnv resolves the environment variables specified for the project's commands.
+// The env files and env maps are applied in this order (later overrides earlier):
+// project env files, project env, run env (e.g. the env of the script run by gows run), user env files, user env.
+// $VAR references are expanded, based on the command's base environment (baseEnvs), the GOWS_WORKSPACE
+// and GOWS_PROJECT_DIR variables, and the variables defined earlier.
+func commandEnv(projectDir, workspaceRootPath string, projectConfig config.ProjectConfigModel, userConfig config.UserConfigModel, runEnv map[string]string, baseEnvs []string) (map[string]string, error) {
+	resolved := map[string]string{}
+	for _, envItem := range baseEnvs {
+		if split := strings.SplitN(envItem, "=", 2); len(split) == 2 {
+			resolved[split[0]] = split[1]
+		}
+	}
+
+	cmdEnv := map[string]string{}
+	set := func(key, value string) {
+		if key == "GOPATH" || key == "PWD" {
+			log.Warningf("%s is set by gows, it can't be specified in the config - ignoring it", key)
+			return
+		}
+		cmdEnv[key] = value
+		resolved[key] = value
+	}
+	set(gowsWorkspaceEnvKey, workspaceRootPath)
+	set(gowsProjectDirEnvKey, projectDir)
+	resolved["GOPATH"] = workspaceRootPath
+
+	expand := func(value string) string {
+		return os.Expand(value, func(key string) string {
+			return resolved[key]
+		})
+	}
+
+	applyEnvFiles := func(envFiles []string) error {
+		for _, envFile := range envFiles {
+			// the - prefix marks an optional env file, which is skipped if it does not exist
+			isOptional := strings.HasPrefix(envFile, "-")
+			envFilePth := strings.TrimPrefix(envFile, "-")
+			if !filepath.IsAbs(envFilePth) {
+				envFilePth = filepath.Join(projectDir, envFilePth)
+			}
+
+			items, err := config.LoadEnvFile(envFilePth)
+			if os.IsNotExist(err) {
+				if isOptional {
+					log.Debugf("Optional env file (%s) does not exist, skipping it", envFilePth)
+					continue
+				}
+				return fmt.Errorf("Env file (%s) does not exist - prefix it with - (e.g. -.env.local) if it's optional", envFilePth)
+			} else if err != nil {
+				return err
+			}
+
+			for _, item := range items {
+				if item.IsLiteral {
+					set(item.Key, item.Value)
+				} else {
+					set(item.Key, expand(item.Value))
+				}
+			}
+		}
+		return nil
+	}
+
+	applyEnv := func(env map[string]string) {
+		// every value is expanded based on the variables defined before this env map
+		expanded := map[string]string{}
+		for key, value := range env {
+			expanded[key] = expand(value)
+		}
+
+		keys := []string{}
+		for key := range expanded {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			set(key, expanded[key])
+		}
+	}
+
+	if err := applyEnvFiles(projectConfig.EnvFiles); err != nil {
+		return map[string]string{}, fmt.Errorf("Failed to load the env files of the project config: %s", err)
+	}
+	applyEnv(projectConfig.Env)
+	applyEnv(runEnv)
+	if err := applyEnvFiles(userConfig.EnvFiles); err != nil {
+		return map[string]string{}, fmt.Errorf("Failed to load the env files of the user config: %s", err)
+	}
+	applyEnv(userConfig.Env)
+
+	return cmdEnv, nil
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/gows/config"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_commandEnv(t *testing.T) {
+	projectDir, err := ioutil.TempDir("", "gows-cmdenv-")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(projectDir))
+	}()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(projectDir, ".env"), []byte("DB_HOST=localhost\nDB_DSN=postgres://$DB_HOST/db\nLITERAL='$HOME'\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(projectDir, ".env.local"), []byte("DB_HOST=127.0.0.1\n"), 0644))
+
+	baseEnvs := []string{"HOME=/home/user", "PATH=/bin"}
+
+	t.Log("Project config only")
+	{
+		projectConfig := config.ProjectConfigModel{
+			EnvFiles: []string{".env", "-.env.missing"},
+			Env: map[string]string{
+				"CGO_ENABLED": "0",
+				"TOOLS_DIR":   "$GOWS_WORKSPACE/tools",
+				"CONFIG":      "${GOWS_PROJECT_DIR}/config.yml",
+				"GOPATH_BIN":  "$GOPATH/bin",
+				"DB_NAME":     "$DB_DSN-test",
+			},
+		}
+
+		env, err := commandEnv(projectDir, "/ws/root", projectConfig, config.UserConfigModel{}, nil, baseEnvs)
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{
+			"GOWS_WORKSPACE":   "/ws/root",
+			"GOWS_PROJECT_DIR": projectDir,
+			"DB_HOST":          "localhost",
+			"DB_DSN":           "postgres://localhost/db",
+			"LITERAL":          "$HOME",
+			"CGO_ENABLED":      "0",
+			"TOOLS_DIR":        "/ws/root/tools",
+			"CONFIG":           projectDir + "/config.yml",
+			"GOPATH_BIN":       "/ws/root/bin",
+			"DB_NAME":          "postgres://localhost/db-test",
+		}, env)
+	}
+
+	t.Log("User config overrides the project config")
+	{
+		projectConfig := config.ProjectConfigModel{
+			EnvFiles: []string{".env"},
+			Env:      map[string]string{"CGO_ENABLED": "0", "LOG": "$HOME/project.log"},
+		}
+		userConfig := config.UserConfigModel{
+			EnvFiles: []string{".env.local"},
+			Env:      map[string]string{"CGO_ENABLED": "1", "GOPATH": "/not/allowed"},
+		}
+
+		env, err := commandEnv(projectDir, "/ws/root", projectConfig, userConfig, nil, baseEnvs)
+		require.NoError(t, err)
+		require.Equal(t, "127.0.0.1", env["DB_HOST"])
+		// expanded when the project's env file was loaded
+		require.Equal(t, "postgres://localhost/db", env["DB_DSN"])
+		require.Equal(t, "1", env["CGO_ENABLED"])
+		require.Equal(t, "/home/user/project.log", env["LOG"])
+		_, isFound := env["GOPATH"]
+		require.Equal(t, false, isFound)
+	}
+
+	t.Log("Run env overrides the project env, the user config overrides the run env")
+	{
+		projectConfig := config.ProjectConfigModel{
+			Env: map[string]string{"CGO_ENABLED": "0", "RACE": "false"},
+		}
+		userConfig := config.UserConfigModel{
+			Env: map[string]string{"RACE": "true"},
+		}
+		runEnv := map[string]string{"CGO_ENABLED": "1", "RACE": "maybe", "LOG": "$GOWS_WORKSPACE/run.log"}
+
+		env, err := commandEnv(projectDir, "/ws/root", projectConfig, userConfig, runEnv, baseEnvs)
+		require.NoError(t, err)
+		require.Equal(t, "1", env["CGO_ENABLED"])
+		require.Equal(t, "true", env["RACE"])
+		require.Equal(t, "/ws/root/run.log", env["LOG"])
+	}
+
+	t.Log("Missing env file - only optional ones are skipped")
+	{
+		_, err := commandEnv(projectDir, "/ws/root", config.ProjectConfigModel{EnvFiles: []string{".env.missing"}}, config.UserConfigModel{}, nil, baseEnvs)
+		require.EqualError(t, err, "Failed to load the env files of the project config: Env file ("+filepath.Join(projectDir, ".env.missing")+") does not exist - prefix it with - (e.g. -.env.local) if it's optional")
+
+		env, err := commandEnv(projectDir, "/ws/root", config.ProjectConfigModel{}, config.UserConfigModel{EnvFiles: []string{"-.env.missing", "-.env.local"}}, nil, baseEnvs)
+		require.NoError(t, err)
+		require.Equal(t, "127.0.0.1", env["DB_HOST"])
+	}
+
+	t.Log("Invalid env file")
+	{
+		require.NoError(t, ioutil.WriteFile(filepath.Join(projectDir, ".env.invalid"), []byte("INVALID\n"), 0644))
+		_, err := commandEnv(projectDir, "/ws/root", config.ProjectConfigModel{EnvFiles: []string{".env.invalid"}}, config.UserConfigModel{}, nil, baseEnvs)
+		require.Error(t, err)
+	}
+}
@@ -2,10 +2,14 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/pkg/errors"
@@ -15,82 +19,141 @@ import (
 	"github.com/bitrise-io/go-utils/fileutil"
 	"github.com/bitrise-io/go-utils/pathutil"
 	"github.com/bitrise-io/gows/config"
+	"github.com/bitrise-io/gows/dirsync"
 	"github.com/bitrise-io/gows/gows"
+	"github.com/bitrise-io/gows/version"
 )
 
 const (
-	gowsCopyModeActiveFilePath = "./GOWS-COPY-MODE-ACTIVE"
+	gowsCopyModeActiveFileName = "GOWS-COPY-MODE-ACTIVE"
 )
 
-// PrepareEnvironmentAndRunCommand ...
-// Returns the exit code of the command and any error occured in the function
-func PrepareEnvironmentAndRunCommand(userConfig config.UserConfigModel, cmdName string, cmdArgs ...string) (int, error) {
-	projectConfig, err := config.LoadProjectConfigFromFile()
+// currentProjectDir returns the root directory of the current project - the nearest directory
+// with a gows.yml, walking up from the working directory -, and the working directory's
+// path relative to the project's root directory
+func currentProjectDir() (string, string, error) {
+	currWorkDir, err := os.Getwd()
 	if err != nil {
-		log.Info("Run " + colorstring.Green("gows init") + " to initialize a workspace & gows config for this project")
-		return 0, fmt.Errorf("Failed to read Project Config: %s", err)
+		return "", "", fmt.Errorf("Failed to get current working directory: %s", err)
 	}
 
-	gowsConfig, err := config.LoadGOWSConfigFromFile()
+	projectDir, isFound, err := config.FindProjectRootDir(currWorkDir)
 	if err != nil {
-		return 0, fmt.Errorf("Failed to read gows configs: %s", err)
+		return "", "", fmt.Errorf("Failed to find the project's root directory: %s", err)
 	}
-	currWorkDir, err := os.Getwd()
-	if err != nil {
-		return 0, fmt.Errorf("[PrepareEnvironmentAndRunCommand] Failed to get current working directory: %s", err)
+	if !isFound {
+		return "", "", fmt.Errorf("No %s found in the current directory (%s) or in any of its parent directories", config.ProjectConfigFileName, currWorkDir)
 	}
 
-	wsConfig, isFound := gowsConfig.WorkspaceForProjectLocation(currWorkDir)
-	if !isFound {
-		log.Debugln("No initialized workspace dir found for this project, initializing one ...")
-		if err := initWorkspaceForProjectPath(currWorkDir, false); err != nil {
-			return 0, fmt.Errorf("[PrepareEnvironmentAndRunCommand] Failed to initialize Workspace for Project: %s", err)
-		}
-		log.Debugln("[DONE] workspace dir initialized - continue running ...")
+	relWorkDir, err := filepath.Rel(projectDir, currWorkDir)
+	if err != nil {
+		return "", "", fmt.Errorf("Failed to get the working directory's path relative to the project (%s), error: %s", projectDir, err)
+	}
 
-		// reload config
-		gowsConfig, err := config.LoadGOWSConfigFromFile()
-		if err != nil {
-			return 0, fmt.Errorf("Failed to read gows configs: %s", err)
-		}
-		wsConfig, isFound = gowsConfig.WorkspaceForProjectLocation(currWorkDir)
+	// the same project can be reached through different paths (e.g. through a symlink)
+	canonicalProjectDir, err := config.CanonicalProjectPath(projectDir)
+	if err != nil {
+		return "", "", fmt.Errorf("Failed to get canonical path of the project (%s), error: %s", projectDir, err)
 	}
-	if !isFound {
-		log.Info("Run " + colorstring.Green("gows init") + " to initialize a workspace & gows config for this project")
-		return 0, fmt.Errorf("No Workspace configuration found for the current project / working directory: %s", currWorkDir)
+
+	return canonicalProjectDir, relWorkDir, nil
+}
+
+// loadUserConfig loads the user config of the project (or the default user config if the project has none),
+// and applies the settings specified through environment variables
+func loadUserConfig(projectDir string) config.UserConfigModel {
+	userConfig, err := config.LoadUserConfigFromDir(projectDir)
+	if err != nil {
+		log.Debug("No User Config found, using defaults")
+		userConfig = config.CreateDefaultUserConfig()
 	}
 
-	origGOPATH := os.Getenv("GOPATH")
-	if origGOPATH == "" {
-		// since Go 1.8 GOPATH is no longer required, it defaults to $HOME/go if not set:
-		// https://golang.org/doc/go1.8#gopath
-		p, err := pathutil.AbsPath("$HOME/go")
+	if forceSyncMode := os.Getenv("GOWS_SYNC_MODE"); forceSyncMode != "" {
+		log.Debugf(" (i) Sync Mode specified as a parameter, using it (%s)", forceSyncMode)
+		userConfig.SyncMode = forceSyncMode
+	}
+	if forceSyncEngine := os.Getenv("GOWS_SYNC_ENGINE"); forceSyncEngine != "" {
+		log.Debugf(" (i) Sync Engine specified as a parameter, using it (%s)", forceSyncEngine)
+		userConfig.SyncEngine = forceSyncEngine
+	}
+	if forceSyncConflictPolicy := os.Getenv("GOWS_SYNC_CONFLICT_POLICY"); forceSyncConflictPolicy != "" {
+		log.Debugf(" (i) Sync Conflict Policy specified as a parameter, using it (%s)", forceSyncConflictPolicy)
+		userConfig.SyncConflictPolicy = forceSyncConflictPolicy
+	}
+	if forceRunLock := os.Getenv("GOWS_RUN_LOCK"); forceRunLock != "" {
+		log.Debugf(" (i) Run Lock specified as a parameter, using it (%s)", forceRunLock)
+		userConfig.RunLock = forceRunLock
+	}
+	if forceWorkspaceNameTemplate := os.Getenv("GOWS_WORKSPACE_NAME_TEMPLATE"); forceWorkspaceNameTemplate != "" {
+		log.Debugf(" (i) Workspace Name Template specified as a parameter, using it (%s)", forceWorkspaceNameTemplate)
+		userConfig.WorkspaceNameTemplate = forceWorkspaceNameTemplate
+	}
+	if forceRunLockTimeout := os.Getenv("GOWS_RUN_LOCK_TIMEOUT"); forceRunLockTimeout != "" {
+		timeout, err := strconv.Atoi(forceRunLockTimeout)
 		if err != nil {
-			return 0, errors.Wrap(err, "No GOPATH environment variable specified, and failed to get Abs path of default $HOME/go dir")
+			log.Warningf("Invalid GOWS_RUN_LOCK_TIMEOUT (%s), should be a number of seconds - ignoring it", forceRunLockTimeout)
+		} else {
+			log.Debugf(" (i) Run Lock Timeout specified as a parameter, using it (%d)", timeout)
+			userConfig.RunLockTimeout = timeout
 		}
-		origGOPATH = p
 	}
 
-	if err := pathutil.EnsureDirExist(origGOPATH); err != nil {
-		return 0, errors.Wrapf(err, "Failed to ensure that GOPATH exists at path: %s", origGOPATH)
+	return userConfig
+}
+
+// runOptions - options of a single command run in the project's workspace
+type runOptions struct {
+	// WorkDir - the directory (relative to the project's directory) to run the command in,
+	// if empty the command runs in the same sub directory of the project, where gows was called from
+	WorkDir string
+	// Env - environment variables to set for the command, in addition to the project's env
+	Env map[string]string
+	// Stdout - if not nil the command's standard output is written into it, instead of gows's standard output
+	Stdout io.Writer
+}
+
+// PrepareEnvironmentAndRunCommand ...
+// Returns the exit code of the command and any error occured in the function
+func PrepareEnvironmentAndRunCommand(userConfig config.UserConfigModel, cmdName string, cmdArgs ...string) (int, error) {
+	return prepareEnvironmentAndRunCommand(userConfig, runOptions{}, cmdName, cmdArgs...)
+}
+
+func prepareEnvironmentAndRunCommand(userConfig config.UserConfigModel, runOpts runOptions, cmdName string, cmdArgs ...string) (int, error) {
+	projectDir, relWorkDir, err := currentProjectDir()
+	if err != nil {
+		log.Info("Run " + colorstring.Green("gows init") + " to initialize a workspace & gows config for this project")
+		return 0, err
+	}
+	if runOpts.WorkDir != "" {
+		relWorkDir = runOpts.WorkDir
 	}
+	log.Debugf("[PrepareEnvironmentAndRunCommand] Project dir: %s (working dir inside: %s)", projectDir, relWorkDir)
 
-	if wsConfig.WorkspaceRootPath == "" {
-		return 0, fmt.Errorf("No gows Workspace root path found for the current project / working directory: %s", currWorkDir)
+	projectConfig, err := config.LoadProjectConfigFromDir(projectDir)
+	if err != nil {
+		log.Info("Run " + colorstring.Green("gows init") + " to initialize a workspace & gows config for this project")
+		return 0, fmt.Errorf("Failed to read Project Config: %s", err)
 	}
-	if projectConfig.PackageName == "" {
-		return 0, errors.New("No Package Name specified - make sure you initialized the workspace (with: gows init)")
+
+	wsConfig, err := workspaceForProject(projectDir)
+	if err != nil {
+		return 0, err
 	}
 
-	if err := pathutil.EnsureDirExist(wsConfig.WorkspaceRootPath); err != nil {
-		return 0, fmt.Errorf("Failed to create workspace root directory (path: %s), error: %s", wsConfig.WorkspaceRootPath, err)
+	runLock, err := acquireRunLock(userConfig, wsConfig.WorkspaceRootPath, projectDir, append([]string{cmdName}, cmdArgs...))
+	if err != nil {
+		return 0, err
 	}
+	defer runLock.release()
 
-	if err := gows.CreateGopathBinSymlink(origGOPATH, wsConfig.WorkspaceRootPath); err != nil {
-		return 0, fmt.Errorf("Failed to create GOPATH/bin symlink, error: %s", err)
+	if err := checkInterruptedCopySession(userConfig, projectDir, wsConfig.WorkspaceRootPath); err != nil {
+		return 0, err
 	}
 
-	fullPackageWorkspacePath := filepath.Join(wsConfig.WorkspaceRootPath, "src", projectConfig.PackageName)
+	cmdOptions, err := commandOptions(projectDir, wsConfig.WorkspaceRootPath, projectConfig, userConfig, runOpts.Env)
+	if err != nil {
+		return 0, err
+	}
 
 	userConfigSyncMode := userConfig.SyncMode
 	if userConfigSyncMode == "" {
@@ -98,6 +161,33 @@ func PrepareEnvironmentAndRunCommand(userConfig config.UserConfigModel, cmdName
 	}
 	log.Debug("[PrepareEnvironmentAndRunCommand] specified Sync Mode : ", userConfigSyncMode)
 
+	// the hooks around the command run in the project's package inside the workspace,
+	// except in mount Sync Mode, where the project is only mounted into the workspace for the command
+	hookWorkDir := workspacePackagePath(wsConfig.WorkspaceRootPath, projectConfig.PackageName)
+	if userConfigSyncMode == config.SyncModeMount {
+		hookWorkDir = projectDir
+	}
+	hooks := newHookRunner(projectDir, hookWorkDir, wsConfig.WorkspaceRootPath, projectConfig, userConfig, cmdOptions)
+	if err := hooks.run(config.HookPrePrepare, nil); err != nil {
+		return 0, err
+	}
+
+	fullPackageWorkspacePath, err := prepareWorkspace(projectDir, projectConfig, wsConfig)
+	if err != nil {
+		return 0, err
+	}
+
+	// the local directories linked into the workspace (see: gows link)
+	if err := applyWorkspaceLinks(userConfig, filepath.Join(wsConfig.WorkspaceRootPath, "src")); err != nil {
+		return 0, err
+	}
+	if err := linkProjectAliasesIntoWorkspace(projectConfig, wsConfig.WorkspaceRootPath, fullPackageWorkspacePath); err != nil {
+		return 0, err
+	}
+
+	var syncExcludeMatcher *dirsync.Matcher
+	copySessionJournal := config.CopySessionJournalModel{}
+
 	// --- prepare ---
 	{
 		fullPackageWorkspacePathFileInfo, fullPackageWorkspaceIsExists, err := pathutil.PathCheckAndInfos(fullPackageWorkspacePath)
@@ -107,21 +197,9 @@ func PrepareEnvironmentAndRunCommand(userConfig config.UserConfigModel, cmdName
 
 		switch userConfigSyncMode {
 		case config.SyncModeSymlink:
-			// create symlink for Project->Workspace
-			if fullPackageWorkspaceIsExists && fullPackageWorkspacePathFileInfo.Mode()&os.ModeSymlink == 0 {
-				// directory (non symlink) exists - remove it
-				log.Warningf("Directory exists (at: %s)", fullPackageWorkspacePath)
-				log.Warning("Removing it ...")
-				if err := os.RemoveAll(fullPackageWorkspacePath); err != nil {
-					return 0, fmt.Errorf("Failed to remove Directory (at: %s), error: %s", fullPackageWorkspacePath, err)
-				}
-			}
-
-			log.Debugf("=> Creating Symlink: (%s) -> (%s)", currWorkDir, fullPackageWorkspacePath)
-			if err := gows.CreateOrUpdateSymlink(currWorkDir, fullPackageWorkspacePath); err != nil {
-				return 0, fmt.Errorf("Failed to create Project->Workspace symlink, error: %s", err)
+			if err := linkProjectIntoWorkspace(projectDir, fullPackageWorkspacePath); err != nil {
+				return 0, err
 			}
-			log.Debugf(" [DONE] Symlink is in place")
 		case config.SyncModeCopy:
 			// Sync project into workspace
 			if fullPackageWorkspaceIsExists && fullPackageWorkspacePathFileInfo.Mode()&os.ModeSymlink != 0 {
@@ -133,46 +211,407 @@ func PrepareEnvironmentAndRunCommand(userConfig config.UserConfigModel, cmdName
 				}
 			}
 
-			log.Debugf("=> Sync project content into workspace: (%s) -> (%s)", currWorkDir, fullPackageWorkspacePath)
-			if err := syncDirWithDir(currWorkDir, fullPackageWorkspacePath); err != nil {
+			syncExcludeMatcher, err = createSyncExcludeMatcher(projectDir, projectConfig, userConfig)
+			if err != nil {
+				return 0, fmt.Errorf("Failed to read the sync exclude patterns, error: %s", err)
+			}
+
+			// journal the session, so that it can be recovered if gows is interrupted
+			copySessionJournal = config.CopySessionJournalModel{
+				ProjectPath:          projectDir,
+				WorkspaceRootPath:    wsConfig.WorkspaceRootPath,
+				WorkspacePackagePath: fullPackageWorkspacePath,
+				ActiveFilePath:       filepath.Join(projectDir, gowsCopyModeActiveFileName),
+				PID:                  os.Getpid(),
+				Command:              append([]string{cmdName}, cmdArgs...),
+				Phase:                config.CopySessionPhaseSyncIn,
+				StartedAt:            time.Now(),
+			}
+			if err := config.SaveCopySessionJournal(copySessionJournal); err != nil {
+				return 0, fmt.Errorf("Failed to save copy session journal, error: %s", err)
+			}
+			if err := saveCopySessionSnapshot(userConfig, syncExcludeMatcher, projectDir); err != nil {
+				log.Warningf(" [!] Failed to snapshot the project, conflicting changes won't be detected, error: %s", err)
+			}
+
+			log.Debugf("=> Sync project content into workspace: (%s) -> (%s)", projectDir, fullPackageWorkspacePath)
+			if err := syncDirWithDir(userConfig, syncExcludeMatcher, projectDir, fullPackageWorkspacePath); err != nil {
+				if err := config.RemoveCopySessionJournal(projectDir); err != nil {
+					log.Warningf(" [!] %s", err)
+				}
 				return 0, fmt.Errorf("Failed to sync the project path / workdir into the Workspace, error: %s", err)
 			}
-			if err := writeGowsCopySyncActiveFileToPath(gowsCopyModeActiveFilePath, fullPackageWorkspacePath, currWorkDir); err != nil {
-				log.Warningf(" [!] Failed to write gows-copy-mode-active file to path: %s", gowsCopyModeActiveFilePath)
+			if err := writeGowsCopySyncActiveFileToPath(copySessionJournal.ActiveFilePath, fullPackageWorkspacePath, projectDir); err != nil {
+				log.Warningf(" [!] Failed to write gows-copy-mode-active file to path: %s", copySessionJournal.ActiveFilePath)
+			}
+
+			copySessionJournal.Phase = config.CopySessionPhaseRunning
+			if err := config.SaveCopySessionJournal(copySessionJournal); err != nil {
+				log.Warningf(" [!] Failed to save copy session journal, error: %s", err)
 			}
 			log.Debugf(" [DONE] Sync project content into workspace")
+		case config.SyncModeMount:
+			// the project is bind mounted into the workspace inside the command's mount namespace,
+			// only the (empty) mount point is created here
+			if err := gows.CheckMountNamespaceSupport(); err != nil {
+				return 0, fmt.Errorf("The %s Sync Mode is not supported: %s - use the %s or %s Sync Mode instead", config.SyncModeMount, err, config.SyncModeSymlink, config.SyncModeCopy)
+			}
+			if fullPackageWorkspaceIsExists && fullPackageWorkspacePathFileInfo.Mode()&os.ModeSymlink != 0 {
+				// symlink exists - remove it
+				log.Debugf("Symlink exists (at: %s), removing it ...", fullPackageWorkspacePath)
+				if err := os.Remove(fullPackageWorkspacePath); err != nil {
+					return 0, fmt.Errorf("Failed to remove Symlink (at: %s), error: %s", fullPackageWorkspacePath, err)
+				}
+			}
+			if err := pathutil.EnsureDirExist(fullPackageWorkspacePath); err != nil {
+				return 0, fmt.Errorf("Failed to create the mount point (at: %s), error: %s", fullPackageWorkspacePath, err)
+			}
 		default:
 			return 0, fmt.Errorf("Unsupported Sync Mode: %s", userConfigSyncMode)
 		}
 	}
 
+	// From here on termination signals are forwarded to the command,
+	// and gows only exits once the command and the finishing phase are done
+	signalTrap := trapSignals()
+	defer signalTrap.stop()
+
 	// Run the command, in the prepared Workspace
-	exitCode, cmdErr := runCommand(fullPackageWorkspacePath, wsConfig, cmdName, cmdArgs...)
+	// in the same sub directory of the project, where gows was called from
+	// (or in the one specified in the run options)
+	cmdWorkDir := filepath.Join(fullPackageWorkspacePath, relWorkDir)
+	var exitCode int
+	var cmdErr error
+	// if a post_prepare or pre_command hook fails the command is not run,
+	// but the workspace is still synced back in copy mode
+	hookErr := hooks.run(config.HookPostPrepare, nil)
+	if hookErr == nil {
+		hookErr = hooks.run(config.HookPreCommand, nil)
+	}
+	if hookErr != nil {
+		cmdErr = hookErr
+	} else if userConfigSyncMode == config.SyncModeMount {
+		helperArgs := mountNamespaceHelperArgs(projectDir, fullPackageWorkspacePath, cmdWorkDir, cmdName, cmdArgs...)
+		cmd, err := gows.CreateMountNamespaceCommand(cmdWorkDir, wsConfig.WorkspaceRootPath, projectDir, cmdOptions, helperArgs...)
+		if err != nil {
+			return 0, fmt.Errorf("The %s Sync Mode is not supported: %s", config.SyncModeMount, err)
+		}
+		if runOpts.Stdout != nil {
+			cmd.Stdout = runOpts.Stdout
+		}
+		exitCode, cmdErr = runCommand(signalTrap, cmd)
+		if _, isExitError := cmdErr.(*exec.ExitError); cmdErr != nil && !isExitError {
+			// failed to start
+			cmdErr = fmt.Errorf("Failed to run the command in a user & mount namespace (user namespaces might be disabled on this system, use the %s or %s Sync Mode instead), error: %s", config.SyncModeSymlink, config.SyncModeCopy, cmdErr)
+		}
+	} else {
+		cmd, err := gows.CreateCommandWithOptions(cmdWorkDir, wsConfig.WorkspaceRootPath, cmdOptions, cmdName, cmdArgs...)
+		if err != nil {
+			cmdErr = err
+		} else {
+			if runOpts.Stdout != nil {
+				cmd.Stdout = runOpts.Stdout
+			}
+			exitCode, cmdErr = runCommand(signalTrap, cmd)
+		}
+	}
+	if hookErr == nil {
+		postCommandExitCode := commandExitCode(exitCode, cmdErr)
+		if err := hooks.run(config.HookPostCommand, &postCommandExitCode); err != nil {
+			// the hook's error is only returned if the command itself succeeded
+			log.Errorf("%s", err)
+			if exitCode == 0 && cmdErr == nil {
+				cmdErr = err
+			}
+		}
+	}
 
 	// cleanup / finishing
 	{
 		switch userConfigSyncMode {
-		case config.SyncModeSymlink:
+		case config.SyncModeSymlink, config.SyncModeMount:
 			// nothing to do
 		case config.SyncModeCopy:
 			// Sync back from workspace into project
-			log.Debugf("=> Sync workspace content into project: (%s) -> (%s)", fullPackageWorkspacePath, currWorkDir)
-			if err := syncDirWithDir(fullPackageWorkspacePath, currWorkDir); err != nil {
-				// we should return the command's exit code and error (if any)
-				// maybe if the exitCode==0 and cmdErr==nil only then we could return an error here ...
-				// for now we'll just print an error log, but it won't change the "output" of this function
+			copySessionJournal.Phase = config.CopySessionPhaseSyncBack
+			if err := config.SaveCopySessionJournal(copySessionJournal); err != nil {
+				log.Warningf(" [!] Failed to save copy session journal, error: %s", err)
+			}
+
+			log.Debugf("=> Sync workspace content into project: (%s) -> (%s)", fullPackageWorkspacePath, projectDir)
+			if err := syncBackCopySession(userConfig, syncExcludeMatcher, copySessionJournal); err != nil {
+				// we should return the command's exit code and error (if any),
+				// the sync back error is only returned if the command itself succeeded
 				log.Errorf("Failed to sync back the project content from the Workspace, error: %s", err)
+				log.Info("Run " + colorstring.Green("gows recover") + " to retry the sync back")
+				if exitCode == 0 && cmdErr == nil {
+					cmdErr = err
+				}
 			} else {
+				if err := finishCopySession(copySessionJournal); err != nil {
+					log.Warningf(" [!] %s", err)
+				}
 				log.Debugf(" [DONE] Sync back project content from workspace")
 			}
+
+			postSyncBackExitCode := commandExitCode(exitCode, cmdErr)
+			if err := hooks.run(config.HookPostSyncBack, &postSyncBackExitCode); err != nil {
+				log.Errorf("%s", err)
+				if exitCode == 0 && cmdErr == nil {
+					cmdErr = err
+				}
+			}
 		default:
 			return 0, fmt.Errorf("Unsupported Sync Mode: %s", userConfigSyncMode)
 		}
 	}
 
+	if sig, isReceived := signalTrap.receivedSignal(); isReceived {
+		log.Debugf("[PrepareEnvironmentAndRunCommand] Terminated by signal: %s", sig)
+		exitCode = signalExitCode(sig)
+	}
+
+	recordWorkspaceUse(projectDir, wsConfig.WorkspaceRootPath, projectConfig.PackageName, userConfigSyncMode, commandExitCode(exitCode, cmdErr))
+
 	return exitCode, cmdErr
 }
 
+// recordWorkspaceUse updates the usage metadata of the project's workspace in the gows config,
+// a failure is only logged
+func recordWorkspaceUse(projectDir, workspaceRootPath, packageName, syncMode string, exitCode int) {
+	err := config.UpdateGOWSConfig(func(gowsConfig *config.GOWSConfigModel) error {
+		wsConfig, isFound := gowsConfig.WorkspaceForProjectLocation(projectDir)
+		if !isFound || wsConfig.WorkspaceRootPath != workspaceRootPath {
+			// the project got a new workspace (or it was removed) in the meantime
+			return nil
+		}
+
+		wsConfig.LastUsedAt = time.Now()
+		wsConfig.PackageName = packageName
+		wsConfig.SyncMode = syncMode
+		wsConfig.GOWSVersion = version.VERSION
+		wsConfig.LastExitCode = &exitCode
+		wsConfig.RunCount++
+		return gowsConfig.SetWorkspaceForProjectLocation(projectDir, wsConfig)
+	})
+	if err != nil {
+		log.Warningf(" [!] Failed to record the use of the workspace in the gows config, error: %s", err)
+	}
+}
+
+// commandExitCode returns the exit code exposed to the post_command and post_sync_back hooks
+// (and recorded in the gows config), it is non zero if the command failed (even if it could not be started)
+func commandExitCode(exitCode int, cmdErr error) int {
+	if exitCode == 0 && cmdErr != nil {
+		return 1
+	}
+	return exitCode
+}
+
+// commandOptions returns the options of the commands run in the project's workspace
+func commandOptions(projectDir, workspaceRootPath string, projectConfig config.ProjectConfigModel, userConfig config.UserConfigModel, runEnv map[string]string) (gows.CommandOptions, error) {
+	opts := gows.CommandOptions{}
+
+	hermeticConfig := projectConfig.Hermetic
+	if hermeticConfig == nil {
+		hermeticConfig = &config.HermeticConfigModel{}
+	}
+	if hermeticConfig.Enabled || isHermeticFlag {
+		log.Debugf("[PrepareEnvironmentAndRunCommand] Hermetic mode (env allowlist: %v, isolate network: %v)", hermeticConfig.EnvAllowlist, hermeticConfig.IsolateNetwork)
+		opts.Hermetic = &gows.HermeticOptions{
+			EnvAllowlist:   hermeticConfig.EnvAllowlist,
+			IsolateNetwork: hermeticConfig.IsolateNetwork,
+		}
+	}
+
+	env, err := commandEnv(projectDir, workspaceRootPath, projectConfig, userConfig, runEnv, gows.BaseEnvironment(opts))
+	if err != nil {
+		return gows.CommandOptions{}, err
+	}
+	opts.Env = env
+
+	return opts, nil
+}
+
+// workspaceForProject returns the workspace config of the project,
+// the workspace is initialized if the project does not have one yet
+func workspaceForProject(projectDir string) (config.WorkspaceConfigModel, error) {
+	gowsConfig, err := config.LoadGOWSConfigFromFile()
+	if err != nil {
+		return config.WorkspaceConfigModel{}, fmt.Errorf("Failed to read gows configs: %s", err)
+	}
+
+	wsConfig, isFound := gowsConfig.WorkspaceForProjectLocation(projectDir)
+	if !isFound {
+		// the project might have been moved, with its ID
+		isRelinked, err := relinkMovedProject(projectDir)
+		if err != nil {
+			log.Warningf(" [!] Failed to relink the workspace of the moved project, error: %s", err)
+		} else if isRelinked {
+			gowsConfig, err = config.LoadGOWSConfigFromFile()
+			if err != nil {
+				return config.WorkspaceConfigModel{}, fmt.Errorf("Failed to read gows configs: %s", err)
+			}
+			wsConfig, isFound = gowsConfig.WorkspaceForProjectLocation(projectDir)
+		}
+	}
+	// a workspace shared with other projects (named by an earlier gows version) is replaced
+	isShared := isFound && len(gowsConfig.ProjectsOfWorkspace(wsConfig.WorkspaceRootPath)) > 1
+	if !isFound || isShared {
+		log.Debugln("No initialized (or only a shared) workspace dir found for this project, initializing one ...")
+		if err := initWorkspaceForProjectPath(projectDir, false); err != nil {
+			return config.WorkspaceConfigModel{}, fmt.Errorf("Failed to initialize Workspace for Project: %s", err)
+		}
+		log.Debugln("[DONE] workspace dir initialized - continue running ...")
+
+		// reload config
+		gowsConfig, err := config.LoadGOWSConfigFromFile()
+		if err != nil {
+			return config.WorkspaceConfigModel{}, fmt.Errorf("Failed to read gows configs: %s", err)
+		}
+		wsConfig, isFound = gowsConfig.WorkspaceForProjectLocation(projectDir)
+	}
+	if !isFound {
+		log.Info("Run " + colorstring.Green("gows init") + " to initialize a workspace & gows config for this project")
+		return config.WorkspaceConfigModel{}, fmt.Errorf("No Workspace configuration found for the current project / working directory: %s", projectDir)
+	}
+	if wsConfig.ProjectID == "" {
+		// the workspaces registered by earlier gows versions get the project's ID once
+		if err := registerProjectID(projectDir, wsConfig.WorkspaceRootPath); err != nil {
+			log.Warningf(" [!] Failed to register the ID of the project, error: %s", err)
+		}
+	}
+
+	return wsConfig, nil
+}
+
+// registerProjectID records the project's ID (a new one is generated if the project does not have one yet)
+// with the project's workspace in the gows config
+func registerProjectID(projectDir, workspaceRootPath string) error {
+	projectID, err := ensureProjectID(projectDir)
+	if err != nil {
+		return err
+	}
+
+	return config.UpdateGOWSConfig(func(gowsConfig *config.GOWSConfigModel) error {
+		wsConfig, isFound := gowsConfig.WorkspaceForProjectLocation(projectDir)
+		if !isFound || wsConfig.WorkspaceRootPath != workspaceRootPath {
+			// the project got a new workspace (or it was removed) in the meantime
+			return nil
+		}
+
+		wsConfig.ProjectID = projectID
+		return gowsConfig.SetWorkspaceForProjectLocation(projectDir, wsConfig)
+	})
+}
+
+// prepareWorkspace creates the workspace's directory structure (and the GOPATH/bin symlink),
+// and returns the path of the project's package inside the workspace
+func prepareWorkspace(projectDir string, projectConfig config.ProjectConfigModel, wsConfig config.WorkspaceConfigModel) (string, error) {
+	origGOPATH, err := originalGOPATH()
+	if err != nil {
+		return "", err
+	}
+
+	if err := pathutil.EnsureDirExist(origGOPATH); err != nil {
+		return "", errors.Wrapf(err, "Failed to ensure that GOPATH exists at path: %s", origGOPATH)
+	}
+
+	if wsConfig.WorkspaceRootPath == "" {
+		return "", fmt.Errorf("No gows Workspace root path found for the current project / working directory: %s", projectDir)
+	}
+	if projectConfig.PackageName == "" {
+		return "", errors.New("No Package Name specified - make sure you initialized the workspace (with: gows init)")
+	}
+
+	if err := pathutil.EnsureDirExist(wsConfig.WorkspaceRootPath); err != nil {
+		return "", fmt.Errorf("Failed to create workspace root directory (path: %s), error: %s", wsConfig.WorkspaceRootPath, err)
+	}
+	// the modification time of the workspace root directory is the time the workspace was last used (see: gows prune)
+	now := time.Now()
+	if err := os.Chtimes(wsConfig.WorkspaceRootPath, now, now); err != nil {
+		log.Warningf(" [!] Failed to update the modification time of the workspace root directory (path: %s), error: %s", wsConfig.WorkspaceRootPath, err)
+	}
+
+	if err := gows.CreateGopathBinSymlink(origGOPATH, wsConfig.WorkspaceRootPath); err != nil {
+		return "", fmt.Errorf("Failed to create GOPATH/bin symlink, error: %s", err)
+	}
+
+	fullPackageWorkspacePath := workspacePackagePath(wsConfig.WorkspaceRootPath, projectConfig.PackageName)
+
+	return fullPackageWorkspacePath, nil
+}
+
+// workspacePackagePath returns the path of the package inside the workspace
+func workspacePackagePath(workspaceRootPath, packageName string) string {
+	return filepath.Join(workspaceRootPath, "src", packageName)
+}
+
+// originalGOPATH returns the user's GOPATH (outside of the workspaces),
+// the GOPATH/bin directory of the workspaces is linked into it
+func originalGOPATH() (string, error) {
+	origGOPATH := os.Getenv("GOPATH")
+	if os.Getenv(gowsActiveEnvKey) != "" {
+		// called from a shell which entered a workspace with gows env,
+		// GOPATH points to the workspace
+		origGOPATH = os.Getenv(gowsOrigGOPATHEnvKey)
+	}
+	if origGOPATH == "" {
+		// since Go 1.8 GOPATH is no longer required, it defaults to $HOME/go if not set:
+		// https://golang.org/doc/go1.8#gopath
+		p, err := pathutil.AbsPath("$HOME/go")
+		if err != nil {
+			return "", errors.Wrap(err, "No GOPATH environment variable specified, and failed to get Abs path of default $HOME/go dir")
+		}
+		origGOPATH = p
+	}
+	return origGOPATH, nil
+}
+
+// linkProjectIntoWorkspace creates (or updates) the Project->Workspace symlink of the symlink sync mode
+func linkProjectIntoWorkspace(projectDir, fullPackageWorkspacePath string) error {
+	fullPackageWorkspacePathFileInfo, fullPackageWorkspaceIsExists, err := pathutil.PathCheckAndInfos(fullPackageWorkspacePath)
+	if err != nil {
+		return fmt.Errorf("Failed to check Symlink status (at: %s), error: %s", fullPackageWorkspacePath, err)
+	}
+
+	if fullPackageWorkspaceIsExists && fullPackageWorkspacePathFileInfo.Mode()&os.ModeSymlink == 0 {
+		// directory (non symlink) exists - remove it
+		log.Warningf("Directory exists (at: %s)", fullPackageWorkspacePath)
+		log.Warning("Removing it ...")
+		if err := os.RemoveAll(fullPackageWorkspacePath); err != nil {
+			return fmt.Errorf("Failed to remove Directory (at: %s), error: %s", fullPackageWorkspacePath, err)
+		}
+	}
+
+	log.Debugf("=> Creating Symlink: (%s) -> (%s)", projectDir, fullPackageWorkspacePath)
+	if err := gows.CreateOrUpdateSymlink(projectDir, fullPackageWorkspacePath); err != nil {
+		return fmt.Errorf("Failed to create Project->Workspace symlink, error: %s", err)
+	}
+	log.Debugf(" [DONE] Symlink is in place")
+	return nil
+}
+
+// linkProjectAliasesIntoWorkspace exposes the project's package at its alias import paths too,
+// with symlinks to the project's package path inside the workspace
+func linkProjectAliasesIntoWorkspace(projectConfig config.ProjectConfigModel, workspaceRootPath, fullPackageWorkspacePath string) error {
+	for _, alias := range projectConfig.Aliases {
+		if err := validateDependencyImportPath(alias); err != nil {
+			return fmt.Errorf("Invalid alias in %s: %s", config.ProjectConfigFileName, err)
+		}
+		if isImportPathOverlapping(alias, projectConfig.PackageName) {
+			return fmt.Errorf("Invalid alias in %s: the alias (%s) overlaps with the package name (%s)", config.ProjectConfigFileName, alias, projectConfig.PackageName)
+		}
+
+		aliasWorkspacePath := filepath.Join(workspaceRootPath, "src", filepath.FromSlash(alias))
+		log.Debugf("=> Creating Alias Symlink: (%s) -> (%s)", fullPackageWorkspacePath, aliasWorkspacePath)
+		if err := linkProjectIntoWorkspace(fullPackageWorkspacePath, aliasWorkspacePath); err != nil {
+			return fmt.Errorf("Failed to link the project into the workspace as %s, error: %s", alias, err)
+		}
+	}
+	return nil
+}
+
 func writeGowsCopySyncActiveFileToPath(pth, gowsWorkspacePath, originalProjectPath string) error {
 	gowsCopyModeActiveContent := fmt.Sprintf(`gows workspace is active at the path: %s
 
@@ -187,7 +626,76 @@ in this directory again.
 	return fileutil.WriteStringToFile(pth, gowsCopyModeActiveContent)
 }
 
-func syncDirWithDir(syncContentOf, syncIntoDir string) error {
+// createSyncExcludeMatcher collects the sync exclude patterns of gows.yml, .gows.user.yml and .gowsignore.
+// Include patterns are added last (as negated patterns), so that they override every exclude pattern.
+func createSyncExcludeMatcher(projectDir string, projectConfig config.ProjectConfigModel, userConfig config.UserConfigModel) (*dirsync.Matcher, error) {
+	ignorePatterns, err := config.LoadIgnorePatternsFromDir(projectDir)
+	if err != nil {
+		return nil, err
+	}
+
+	patterns := []string{}
+	patterns = append(patterns, projectConfig.SyncExclude...)
+	patterns = append(patterns, userConfig.SyncExclude...)
+	patterns = append(patterns, ignorePatterns...)
+	includePatterns := []string{}
+	includePatterns = append(includePatterns, projectConfig.SyncInclude...)
+	includePatterns = append(includePatterns, userConfig.SyncInclude...)
+	for _, includePattern := range includePatterns {
+		patterns = append(patterns, "!"+strings.TrimPrefix(includePattern, "!"))
+	}
+
+	// conflict copies are never synced
+	patterns = append(patterns, "*"+gowsConflictFileSuffix)
+
+	return dirsync.NewMatcher(patterns)
+}
+
+// saveCopySessionSnapshot fingerprints the project's files before syncing them into the workspace,
+// to be able to detect the files changed both in the project and in the workspace
+func saveCopySessionSnapshot(userConfig config.UserConfigModel, excludeMatcher *dirsync.Matcher, projectPath string) error {
+	snapshot, err := dirsync.TakeSnapshot(projectPath, dirsync.Options{
+		CompareContent: userConfig.SyncChecksum,
+		Exclude:        excludeMatcher,
+	})
+	if err != nil {
+		return err
+	}
+
+	snapshotFileAbsPath, err := config.CopySessionSnapshotFileAbsPath(projectPath)
+	if err != nil {
+		return fmt.Errorf("Failed to get absolute path of copy session snapshot: %s", err)
+	}
+	return snapshot.SaveToFile(snapshotFileAbsPath)
+}
+
+func syncDirWithDir(userConfig config.UserConfigModel, excludeMatcher *dirsync.Matcher, syncContentOf, syncIntoDir string) error {
+	syncEngine := userConfig.SyncEngine
+	if syncEngine == "" {
+		syncEngine = config.DefaultSyncEngine
+	}
+
+	switch syncEngine {
+	case config.SyncEngineNative:
+		log.Debugf("[syncDirWithDir] Syncing: (%s) -> (%s)", syncContentOf, syncIntoDir)
+		stats, err := dirsync.SyncDirWithDir(syncContentOf, syncIntoDir, dirsync.Options{
+			Delete:         true,
+			CompareContent: userConfig.SyncChecksum,
+			Exclude:        excludeMatcher,
+		})
+		if err != nil {
+			return fmt.Errorf("Failed to sync between (%s) and (%s), error: %s", syncContentOf, syncIntoDir, err)
+		}
+		log.Debugf("[syncDirWithDir] Sync stats: %s", stats)
+		return nil
+	case config.SyncEngineRsync:
+		return rsyncDirWithDir(excludeMatcher, syncContentOf, syncIntoDir)
+	default:
+		return fmt.Errorf("Unsupported Sync Engine: %s", syncEngine)
+	}
+}
+
+func rsyncDirWithDir(excludeMatcher *dirsync.Matcher, syncContentOf, syncIntoDir string) error {
 	syncContentOf = filepath.Clean(syncContentOf)
 	syncIntoDir = filepath.Clean(syncIntoDir)
 
@@ -195,18 +703,26 @@ func syncDirWithDir(syncContentOf, syncIntoDir string) error {
 		return fmt.Errorf("Failed to create target (at: %s), error: %s", syncIntoDir, err)
 	}
 
-	cmd := exec.Command("rsync", "-avhP", "--delete", syncContentOf+"/", syncIntoDir+"/")
+	rsyncArgs := []string{"-avhP", "--delete"}
+	// rsync uses the first matching rule, .gitignore style patterns the last matching one
+	patterns := excludeMatcher.Patterns()
+	for i := len(patterns) - 1; i >= 0; i-- {
+		rsyncArgs = append(rsyncArgs, "--filter="+patterns[i].RsyncFilterRule())
+	}
+	rsyncArgs = append(rsyncArgs, syncContentOf+"/", syncIntoDir+"/")
+
+	cmd := exec.Command("rsync", rsyncArgs...)
 	cmd.Stdin = os.Stdin
 
-	log.Debugf("[syncDirWithDir] Running command: $ %s", command.NewWithCmd(cmd).PrintableCommandArgs())
+	log.Debugf("[rsyncDirWithDir] Running command: $ %s", command.NewWithCmd(cmd).PrintableCommandArgs())
 	out, err := cmd.Output()
 	if err != nil {
 		if exitError, ok := err.(*exec.ExitError); ok {
-			log.Error("[syncDirWithDir] Sync Error")
-			log.Errorf("[syncDirWithDir] Output (Stdout) was: %s", out)
-			log.Errorf("[syncDirWithDir] Error Output (Stderr) was: %s", exitError.Stderr)
+			log.Error("[rsyncDirWithDir] Sync Error")
+			log.Errorf("[rsyncDirWithDir] Output (Stdout) was: %s", out)
+			log.Errorf("[rsyncDirWithDir] Error Output (Stderr) was: %s", exitError.Stderr)
 		} else {
-			log.Error("[syncDirWithDir] Failed to convert error to ExitError")
+			log.Error("[rsyncDirWithDir] Failed to convert error to ExitError")
 		}
 		return fmt.Errorf("Failed to rsync between (%s) and (%s), error: %s", syncContentOf, syncIntoDir, err)
 	}
@@ -215,24 +731,44 @@ func syncDirWithDir(syncContentOf, syncIntoDir string) error {
 
 // runCommand runs the command with it's arguments
 // Returns the exit code of the command and any error occured in the function
-func runCommand(cmdWorkdir string, wsConfig config.WorkspaceConfigModel, cmdName string, cmdArgs ...string) (int, error) {
-	log.Debugf("[RunCommand] Command Name: %s", cmdName)
-	log.Debugf("[RunCommand] Command Args: %#v", cmdArgs)
-	log.Debugf("[RunCommand] Command Work Dir: %#v", cmdWorkdir)
-
-	cmd := gows.CreateCommand(cmdWorkdir, wsConfig.WorkspaceRootPath, cmdName, cmdArgs...)
+func runCommand(signalTrap *signalTrap, cmd *exec.Cmd) (int, error) {
+	log.Debugf("[RunCommand] Command: %#v", cmd.Args)
+	log.Debugf("[RunCommand] Command Work Dir: %#v", cmd.Dir)
+
+	// Without a terminal (e.g. on CI) the command gets its own process group,
+	// so that the forwarded signals reach every process it started.
+	// With a terminal the command has to stay in the foreground process group.
+	isOwnProcessGroup := false
+	if !isInteractive() {
+		isOwnProcessGroup = startInOwnProcessGroup(cmd)
+	}
 
-	cmdExitCode := 0
-	if err := cmd.Run(); err != nil {
-		if exitError, ok := err.(*exec.ExitError); ok {
-			waitStatus, ok := exitError.Sys().(syscall.WaitStatus)
-			if !ok {
-				return 0, errors.New("Failed to cast exit status")
-			}
-			cmdExitCode = waitStatus.ExitStatus()
-		}
-		return cmdExitCode, err
+	if err := cmd.Start(); err != nil {
+		return 0, err
 	}
+	signalTrap.setCommandProcess(cmd.Process, isOwnProcessGroup)
+	err := cmd.Wait()
+	signalTrap.setCommandProcess(nil, false)
 
+	if err != nil {
+		return exitCodeOfCommandError(err)
+	}
 	return 0, nil
 }
+
+// exitCodeOfCommandError returns the exit code of the command,
+// or the conventional 128+signal code if the command was terminated by a signal
+func exitCodeOfCommandError(cmdErr error) (int, error) {
+	exitError, ok := cmdErr.(*exec.ExitError)
+	if !ok {
+		return 0, cmdErr
+	}
+	waitStatus, ok := exitError.Sys().(syscall.WaitStatus)
+	if !ok {
+		return 0, errors.New("Failed to cast exit status")
+	}
+	if waitStatus.Signaled() {
+		return signalExitCode(waitStatus.Signal()), cmdErr
+	}
+	return waitStatus.ExitStatus(), cmdErr
+}
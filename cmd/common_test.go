@@ -1 +1,164 @@
 package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/gows/config"
+	"github.com/bitrise-io/gows/version"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_recordWorkspaceUse(t *testing.T) {
+	homeDir, err := ioutil.TempDir("", "gows-record-home-")
+	require.NoError(t, err)
+	homeDir, err = filepath.EvalSymlinks(homeDir)
+	require.NoError(t, err)
+	origHome := os.Getenv("HOME")
+	require.NoError(t, os.Setenv("HOME", homeDir))
+	defer func() {
+		require.NoError(t, os.Setenv("HOME", origHome))
+		require.NoError(t, os.RemoveAll(homeDir))
+	}()
+
+	projectDir := filepath.Join(homeDir, "project")
+	require.NoError(t, os.MkdirAll(projectDir, 0755))
+
+	workspaceOf := func() config.WorkspaceConfigModel {
+		gowsConfig, err := config.LoadGOWSConfigFromFile()
+		require.NoError(t, err)
+		wsConfig, isFound := gowsConfig.WorkspaceForProjectLocation(projectDir)
+		require.Equal(t, true, isFound)
+		return wsConfig
+	}
+
+	startTime := time.Now().Add(-time.Second)
+	require.NoError(t, initWorkspaceForProjectPath(projectDir, false))
+	wsConfig := workspaceOf()
+	require.True(t, wsConfig.CreatedAt.After(startTime))
+	require.Equal(t, 0, wsConfig.RunCount)
+	require.Nil(t, wsConfig.LastExitCode)
+
+	t.Log("Every use is recorded")
+	{
+		recordWorkspaceUse(projectDir, wsConfig.WorkspaceRootPath, "github.com/org/project", config.SyncModeSymlink, 0)
+		recordWorkspaceUse(projectDir, wsConfig.WorkspaceRootPath, "github.com/org/project", config.SyncModeCopy, 3)
+
+		usedWsConfig := workspaceOf()
+		require.Equal(t, wsConfig.CreatedAt.Unix(), usedWsConfig.CreatedAt.Unix())
+		require.True(t, usedWsConfig.LastUsedAt.After(startTime))
+		require.Equal(t, "github.com/org/project", usedWsConfig.PackageName)
+		require.Equal(t, config.SyncModeCopy, usedWsConfig.SyncMode)
+		require.Equal(t, version.VERSION, usedWsConfig.GOWSVersion)
+		require.Equal(t, 3, *usedWsConfig.LastExitCode)
+		require.Equal(t, 2, usedWsConfig.RunCount)
+	}
+
+	t.Log("The metadata is kept when the workspace is reused")
+	{
+		require.NoError(t, initWorkspaceForProjectPath(projectDir, false))
+		require.Equal(t, 2, workspaceOf().RunCount)
+	}
+
+	t.Log("Reset creates a new workspace, with new metadata")
+	{
+		require.NoError(t, initWorkspaceForProjectPath(projectDir, true))
+		require.Equal(t, 0, workspaceOf().RunCount)
+		recordWorkspaceUse(projectDir, wsConfig.WorkspaceRootPath, "github.com/org/project", config.SyncModeSymlink, 0)
+		recordWorkspaceUse(projectDir, wsConfig.WorkspaceRootPath, "github.com/org/project", config.SyncModeSymlink, 0)
+	}
+
+	t.Log("The use of a replaced workspace is not recorded")
+	{
+		recordWorkspaceUse(projectDir, "/ws/replaced", "github.com/org/project", config.SyncModeSymlink, 0)
+		require.Equal(t, 2, workspaceOf().RunCount)
+	}
+
+	t.Log("The project's user config is not written")
+	{
+		userConfigFilePath := filepath.Join(projectDir, config.UserConfigFileName)
+		require.NoError(t, os.Remove(userConfigFilePath))
+		recordWorkspaceUse(projectDir, wsConfig.WorkspaceRootPath, "github.com/org/project", config.SyncModeSymlink, 0)
+		require.Equal(t, 3, workspaceOf().RunCount)
+		isExists, err := pathExists(userConfigFilePath)
+		require.NoError(t, err)
+		require.Equal(t, false, isExists)
+	}
+}
+
+func Test_workspaceForProject_RegisterProjectID(t *testing.T) {
+	homeDir, err := ioutil.TempDir("", "gows-wsforproject-home-")
+	require.NoError(t, err)
+	homeDir, err = filepath.EvalSymlinks(homeDir)
+	require.NoError(t, err)
+	origHome := os.Getenv("HOME")
+	require.NoError(t, os.Setenv("HOME", homeDir))
+	defer func() {
+		require.NoError(t, os.Setenv("HOME", origHome))
+		require.NoError(t, os.RemoveAll(homeDir))
+	}()
+
+	projectDir := filepath.Join(homeDir, "project")
+	require.NoError(t, os.MkdirAll(projectDir, 0755))
+	// registered by an earlier gows version, without the project's ID
+	legacyWsPath := filepath.Join(homeDir, ".bitrise-gows", "wsdirs", "project-1500000000")
+	require.NoError(t, config.UpdateGOWSConfig(func(gowsConfig *config.GOWSConfigModel) error {
+		return gowsConfig.SetWorkspaceForProjectLocation(projectDir, config.WorkspaceConfigModel{WorkspaceRootPath: legacyWsPath})
+	}))
+
+	_, err = workspaceForProject(projectDir)
+	require.NoError(t, err)
+	userConfig, err := config.LoadUserConfigFromDir(projectDir)
+	require.NoError(t, err)
+	require.NotEqual(t, "", userConfig.ProjectID)
+
+	gowsConfig, err := config.LoadGOWSConfigFromFile()
+	require.NoError(t, err)
+	wsConfig, isFound := gowsConfig.WorkspaceForProjectLocation(projectDir)
+	require.Equal(t, true, isFound)
+	require.Equal(t, legacyWsPath, wsConfig.WorkspaceRootPath)
+	require.Equal(t, userConfig.ProjectID, wsConfig.ProjectID)
+}
+
+func Test_linkProjectAliasesIntoWorkspace(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gows-aliases-")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(tmpDir))
+	}()
+
+	workspaceRootPath := filepath.Join(tmpDir, "ws")
+	fullPackageWorkspacePath := filepath.Join(workspaceRootPath, "src", "github.com", "ourteam", "x")
+	upstreamWorkspacePath := filepath.Join(workspaceRootPath, "src", "github.com", "upstream", "x")
+	// the upstream package fetched with go get
+	require.NoError(t, os.MkdirAll(upstreamWorkspacePath, 0755))
+
+	t.Log("The project is exposed at its aliases")
+	{
+		projectConfig := config.ProjectConfigModel{
+			PackageName: "github.com/ourteam/x",
+			Aliases:     []string{"github.com/upstream/x", "gopkg.in/x.v1"},
+		}
+		require.NoError(t, linkProjectAliasesIntoWorkspace(projectConfig, workspaceRootPath, fullPackageWorkspacePath))
+
+		for _, aliasWorkspacePath := range []string{upstreamWorkspacePath, filepath.Join(workspaceRootPath, "src", "gopkg.in", "x.v1")} {
+			target, err := os.Readlink(aliasWorkspacePath)
+			require.NoError(t, err)
+			require.Equal(t, fullPackageWorkspacePath, target)
+		}
+	}
+
+	t.Log("Invalid aliases")
+	{
+		projectConfig := config.ProjectConfigModel{PackageName: "github.com/ourteam/x", Aliases: []string{"github.com/ourteam/x/sub"}}
+		require.EqualError(t, linkProjectAliasesIntoWorkspace(projectConfig, workspaceRootPath, fullPackageWorkspacePath),
+			"Invalid alias in gows.yml: the alias (github.com/ourteam/x/sub) overlaps with the package name (github.com/ourteam/x)")
+
+		projectConfig = config.ProjectConfigModel{PackageName: "github.com/ourteam/x", Aliases: []string{"../x"}}
+		require.EqualError(t, linkProjectAliasesIntoWorkspace(projectConfig, workspaceRootPath, fullPackageWorkspacePath),
+			"Invalid alias in gows.yml: Invalid import path: ../x")
+	}
+}
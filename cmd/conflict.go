@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bitrise-io/go-utils/colorstring"
+	"github.com/bitrise-io/gows/config"
+	"github.com/bitrise-io/gows/dirsync"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	gowsConflictFileSuffix = ".gows-conflict"
+)
+
+// syncBackCopySession syncs the workspace back into the project.
+// Files changed both in the project and in the workspace since the project
+// was synced into the workspace are handled according to the sync conflict policy.
+func syncBackCopySession(userConfig config.UserConfigModel, excludeMatcher *dirsync.Matcher, journal config.CopySessionJournalModel) error {
+	snapshotFileAbsPath, err := config.CopySessionSnapshotFileAbsPath(journal.ProjectPath)
+	if err != nil {
+		return fmt.Errorf("Failed to get absolute path of copy session snapshot: %s", err)
+	}
+
+	snapshot, err := dirsync.LoadSnapshotFromFile(snapshotFileAbsPath)
+	if os.IsNotExist(err) {
+		log.Warning("No snapshot found of the project, can't detect conflicting changes")
+	} else if err != nil {
+		return fmt.Errorf("Failed to read copy session snapshot: %s", err)
+	} else {
+		conflicts, err := dirsync.DetectConflicts(snapshot, journal.ProjectPath, journal.WorkspacePackagePath, dirsync.Options{
+			CompareContent: userConfig.SyncChecksum,
+			Exclude:        excludeMatcher,
+		})
+		if err != nil {
+			return fmt.Errorf("Failed to detect conflicting changes, error: %s", err)
+		}
+		if len(conflicts) > 0 {
+			if err := resolveSyncBackConflicts(userConfig.SyncConflictPolicy, conflicts, journal.ProjectPath, journal.WorkspacePackagePath); err != nil {
+				return err
+			}
+		}
+	}
+
+	return syncDirWithDir(userConfig, excludeMatcher, journal.WorkspacePackagePath, journal.ProjectPath)
+}
+
+// resolveSyncBackConflicts prepares the conflicting files for the sync back:
+// the losing version of every conflicting file is preserved as a .gows-conflict copy in the project
+func resolveSyncBackConflicts(policy string, conflicts []dirsync.Conflict, projectPath, workspacePackagePath string) error {
+	if policy == "" {
+		policy = config.DefaultSyncConflictPolicy
+	}
+
+	log.Warningf("%d file(s) changed both in the project and in the workspace:", len(conflicts))
+	for _, conflict := range conflicts {
+		log.Warningf(" * %s (project: %s, workspace: %s)", conflict.Path, conflict.ProjectChange, conflict.WorkspaceChange)
+	}
+
+	switch policy {
+	case config.SyncConflictPolicyAbort:
+		log.Info("Resolve the conflicts, then run " + colorstring.Green("gows recover") + " to sync back the workspace")
+		return fmt.Errorf("Sync back aborted, %d conflicting file(s) found (sync conflict policy: %s)", len(conflicts), policy)
+	case config.SyncConflictPolicyWorkspaceWins:
+		for _, conflict := range conflicts {
+			if !conflict.IsInProject {
+				continue
+			}
+			projectFilePth := filepath.Join(projectPath, conflict.Path)
+			if err := dirsync.CopyPath(projectFilePth, projectFilePth+gowsConflictFileSuffix); err != nil {
+				return fmt.Errorf("Failed to preserve the project version of (%s), error: %s", conflict.Path, err)
+			}
+			log.Warningf(" Project version of %s saved as %s", conflict.Path, conflict.Path+gowsConflictFileSuffix)
+		}
+	case config.SyncConflictPolicyProjectWins:
+		for _, conflict := range conflicts {
+			projectFilePth := filepath.Join(projectPath, conflict.Path)
+			workspaceFilePth := filepath.Join(workspacePackagePath, conflict.Path)
+
+			if conflict.IsInWorkspace {
+				if err := dirsync.CopyPath(workspaceFilePth, projectFilePth+gowsConflictFileSuffix); err != nil {
+					return fmt.Errorf("Failed to preserve the workspace version of (%s), error: %s", conflict.Path, err)
+				}
+				log.Warningf(" Workspace version of %s saved as %s", conflict.Path, conflict.Path+gowsConflictFileSuffix)
+			}
+
+			// reset the workspace version, so that the sync back keeps the project version
+			if conflict.IsInProject {
+				if err := dirsync.CopyPath(projectFilePth, workspaceFilePth); err != nil {
+					return fmt.Errorf("Failed to reset the workspace version of (%s), error: %s", conflict.Path, err)
+				}
+			} else if err := os.RemoveAll(workspaceFilePth); err != nil {
+				return fmt.Errorf("Failed to remove the workspace version of (%s), error: %s", conflict.Path, err)
+			}
+		}
+	default:
+		return fmt.Errorf("Unsupported Sync Conflict Policy: %s", policy)
+	}
+
+	return nil
+}
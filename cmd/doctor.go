@@ -0,0 +1,422 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/colorstring"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-io/gows/config"
+	"github.com/bitrise-io/gows/gows"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/viktorbenei/cobra.v0"
+)
+
+const (
+	doctorSeverityError   = "error"
+	doctorSeverityWarning = "warning"
+	doctorSeverityInfo    = "info"
+)
+
+var (
+	isDoctorFix = false
+)
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the health of the gows setup, and fix the problems found",
+	Long: `Check the health of the gows setup, and fix the problems found.
+
+Checks the workspace of the current project, and every registered workspace:
+
+- the workspace's bin symlink (it should point to the bin directory of your GOPATH)
+- the project's symlink inside the workspace (it should point to the project,
+  it does not if the project was moved)
+- leftover GOWS-COPY-MODE-ACTIVE files and interrupted copy mode sessions
+- registry entries of deleted projects and workspaces
+- rsync (required by the rsync Sync Engine)
+
+Every finding is listed with its severity (error, warning or info).
+With --fix the safe fixes are applied (e.g. re-creating a symlink, or removing
+a leftover file or the registry entry of a deleted workspace).
+Exits with an error if an error is found (and not fixed).`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		gowsConfig, err := config.LoadGOWSConfigFromFile()
+		if err != nil {
+			return fmt.Errorf("Failed to load gows config: %s", err)
+		}
+		origGOPATH, err := originalGOPATH()
+		if err != nil {
+			return err
+		}
+
+		currProjectDir, _, err := currentProjectDir()
+		if err != nil {
+			log.Debugf("Failed to find the project's root directory: %s", err)
+		}
+
+		findings := doctorCheckGlobal(gowsConfig, loadUserConfig(currProjectDir))
+		findings = append(findings, doctorCheckProjects(gowsConfig, currProjectDir, origGOPATH)...)
+		// the global findings first, then the findings of the projects
+		sort.SliceStable(findings, func(i, j int) bool {
+			return findings[i].Subject < findings[j].Subject
+		})
+
+		fmt.Println(formatDoctorFindings(findings, isDoctorFix))
+
+		errorCount := 0
+		fixableCount := 0
+		for _, finding := range findings {
+			if finding.fix != nil && isDoctorFix {
+				if err := finding.fix(); err != nil {
+					log.Errorf("Failed to fix (%s): %s", finding.Message, err)
+				} else {
+					log.Infof("%s %s", colorstring.Green("Fixed:"), finding.Message)
+					continue
+				}
+			}
+			if finding.fix != nil {
+				fixableCount++
+			}
+			if finding.Severity == doctorSeverityError {
+				errorCount++
+			}
+		}
+
+		if fixableCount > 0 && !isDoctorFix {
+			log.Infof("Run %s to fix %d of the problem(s)", colorstring.Green("gows doctor --fix"), fixableCount)
+		}
+		if errorCount > 0 {
+			return fmt.Errorf("%d error(s) found", errorCount)
+		}
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().BoolVarP(&isDoctorFix, "fix", "", false, "Apply the safe fixes")
+}
+
+// doctorFinding is a problem found by gows doctor
+type doctorFinding struct {
+	Severity string
+	// Subject - the project the finding belongs to, empty for the global findings
+	Subject string
+	Message string
+	// Hint - what the user can do about the finding
+	Hint string
+	// FixDescription - what --fix does, if the finding can be fixed safely
+	FixDescription string
+	fix            func() error
+}
+
+// doctorCheckGlobal checks the tools gows depends on, and the registry
+func doctorCheckGlobal(gowsConfig config.GOWSConfigModel, userConfig config.UserConfigModel) []doctorFinding {
+	findings := []doctorFinding{}
+
+	if _, err := exec.LookPath("rsync"); err != nil {
+		if userConfig.SyncEngine == config.SyncEngineRsync {
+			findings = append(findings, doctorFinding{
+				Severity: doctorSeverityError,
+				Message:  "rsync not found, but the rsync Sync Engine is configured",
+				Hint:     fmt.Sprintf("install rsync, or use the %s Sync Engine", config.SyncEngineNative),
+			})
+		} else {
+			findings = append(findings, doctorFinding{
+				Severity: doctorSeverityInfo,
+				Message:  "rsync not found",
+				Hint:     fmt.Sprintf("only required by the %s Sync Engine", config.SyncEngineRsync),
+			})
+		}
+	}
+
+	projectPaths := []string{}
+	for projectPath := range gowsConfig.Workspaces {
+		projectPaths = append(projectPaths, projectPath)
+	}
+	sort.Strings(projectPaths)
+
+	for _, projectPath := range projectPaths {
+		wsRootPath := gowsConfig.Workspaces[projectPath].WorkspaceRootPath
+
+		if _, err := os.Stat(projectPath); os.IsNotExist(err) {
+			findings = append(findings, doctorFinding{
+				Severity: doctorSeverityWarning,
+				Subject:  projectPath,
+				Message:  "the project no longer exists",
+				Hint:     fmt.Sprintf("if it was moved run %s in its new location, or run %s to remove its workspace", colorstring.Green("gows relink "+projectPath), colorstring.Green("gows prune")),
+			})
+		} else if _, err := os.Stat(wsRootPath); os.IsNotExist(err) {
+			findings = append(findings, doctorFinding{
+				Severity:       doctorSeverityWarning,
+				Subject:        projectPath,
+				Message:        fmt.Sprintf("the workspace (%s) no longer exists", wsRootPath),
+				FixDescription: "remove the registry entry, a new workspace is created by the next gows command",
+				fix:            removeRegistryEntryOfDeletedWorkspace(projectPath, wsRootPath),
+			})
+		}
+	}
+
+	return findings
+}
+
+// removeRegistryEntryOfDeletedWorkspace removes the project's registry entry,
+// if it still points to the (deleted) workspace
+func removeRegistryEntryOfDeletedWorkspace(projectPath, wsRootPath string) func() error {
+	return func() error {
+		return config.UpdateGOWSConfig(func(gowsConfig *config.GOWSConfigModel) error {
+			wsConfig, isFound := gowsConfig.Workspaces[projectPath]
+			if !isFound || wsConfig.WorkspaceRootPath != wsRootPath {
+				return nil
+			}
+			if _, err := os.Stat(wsRootPath); !os.IsNotExist(err) {
+				return fmt.Errorf("the workspace (%s) exists", wsRootPath)
+			}
+			delete(gowsConfig.Workspaces, projectPath)
+			return nil
+		})
+	}
+}
+
+// doctorCheckProjects checks the workspaces of the registered projects, and of the current one (if any)
+func doctorCheckProjects(gowsConfig config.GOWSConfigModel, currProjectDir, origGOPATH string) []doctorFinding {
+	findings := []doctorFinding{}
+
+	if currProjectDir != "" {
+		if _, isFound := gowsConfig.WorkspaceForProjectLocation(currProjectDir); !isFound {
+			findings = append(findings, doctorFinding{
+				Severity: doctorSeverityInfo,
+				Subject:  currProjectDir,
+				Message:  "no workspace initialized for the project yet",
+				Hint:     "it is created by the next gows command",
+			})
+		}
+	}
+
+	projectPaths := []string{}
+	for projectPath := range gowsConfig.Workspaces {
+		projectPaths = append(projectPaths, projectPath)
+	}
+	sort.Strings(projectPaths)
+
+	for _, projectPath := range projectPaths {
+		wsRootPath := gowsConfig.Workspaces[projectPath].WorkspaceRootPath
+		if _, err := os.Stat(projectPath); err != nil {
+			continue
+		}
+		if _, err := os.Stat(wsRootPath); err != nil {
+			continue
+		}
+
+		projectFindings := doctorCheckBinSymlink(wsRootPath, origGOPATH)
+		projectFindings = append(projectFindings, doctorCheckCopyModeActiveFile(projectPath)...)
+		if projectConfig, err := config.LoadProjectConfigFromDir(projectPath); err == nil && projectConfig.PackageName != "" {
+			syncMode := loadUserConfig(projectPath).SyncMode
+			if syncMode == "" {
+				syncMode = config.DefaultSyncMode
+			}
+			if syncMode == config.SyncModeSymlink {
+				projectFindings = append(projectFindings, doctorCheckProjectSymlink(projectPath, filepath.Join(wsRootPath, "src", projectConfig.PackageName))...)
+			}
+		}
+
+		for idx := range projectFindings {
+			projectFindings[idx].Subject = projectPath
+		}
+		findings = append(findings, projectFindings...)
+	}
+
+	return findings
+}
+
+// doctorCheckBinSymlink checks that the workspace's bin directory is a symlink to GOPATH/bin
+func doctorCheckBinSymlink(wsRootPath, origGOPATH string) []doctorFinding {
+	binPath := filepath.Join(wsRootPath, "bin")
+	origBinPath, err := pathutil.AbsPath(filepath.Join(origGOPATH, "bin"))
+	if err != nil {
+		return []doctorFinding{{Severity: doctorSeverityError, Message: fmt.Sprintf("failed to get the path of GOPATH/bin (%s): %s", origGOPATH, err)}}
+	}
+	recreateSymlink := func() error {
+		return gows.CreateGopathBinSymlink(origGOPATH, wsRootPath)
+	}
+
+	fileInfo, isExists, err := pathutil.PathCheckAndInfos(binPath)
+	if err != nil {
+		return []doctorFinding{{Severity: doctorSeverityError, Message: fmt.Sprintf("failed to check the bin symlink (%s): %s", binPath, err)}}
+	}
+	if !isExists {
+		// created by the next gows command
+		return []doctorFinding{}
+	}
+
+	if fileInfo.Mode()&os.ModeSymlink == 0 {
+		if !fileInfo.IsDir() {
+			return []doctorFinding{{
+				Severity: doctorSeverityError,
+				Message:  fmt.Sprintf("the workspace's bin (%s) is a file, instead of a symlink to GOPATH/bin (%s)", binPath, origBinPath),
+				Hint:     "remove the file",
+			}}
+		}
+
+		entries, err := ioutil.ReadDir(binPath)
+		if err != nil {
+			return []doctorFinding{{Severity: doctorSeverityError, Message: fmt.Sprintf("failed to read the bin directory (%s): %s", binPath, err)}}
+		}
+		finding := doctorFinding{
+			Severity: doctorSeverityError,
+			Message:  fmt.Sprintf("the workspace's bin (%s) is a directory, instead of a symlink to GOPATH/bin (%s), the symlink can't be created", binPath, origBinPath),
+		}
+		if len(entries) == 0 {
+			finding.FixDescription = "remove the empty directory, and create the symlink"
+			finding.fix = func() error {
+				if err := os.Remove(binPath); err != nil {
+					return err
+				}
+				return recreateSymlink()
+			}
+		} else {
+			finding.Hint = fmt.Sprintf("move its content into %s, and remove the directory", origBinPath)
+		}
+		return []doctorFinding{finding}
+	}
+
+	target, err := os.Readlink(binPath)
+	if err != nil {
+		return []doctorFinding{{Severity: doctorSeverityError, Message: fmt.Sprintf("failed to read the bin symlink (%s): %s", binPath, err)}}
+	}
+	if filepath.Clean(target) != filepath.Clean(origBinPath) {
+		return []doctorFinding{{
+			Severity:       doctorSeverityWarning,
+			Message:        fmt.Sprintf("the workspace's bin symlink (%s) points to %s, instead of GOPATH/bin (%s)", binPath, target, origBinPath),
+			FixDescription: "re-create the symlink",
+			fix:            recreateSymlink,
+		}}
+	}
+	if _, err := os.Stat(target); os.IsNotExist(err) {
+		return []doctorFinding{{
+			Severity:       doctorSeverityWarning,
+			Message:        fmt.Sprintf("the workspace's bin symlink (%s) is dangling, GOPATH/bin (%s) does not exist", binPath, target),
+			FixDescription: fmt.Sprintf("create %s", target),
+			fix: func() error {
+				return pathutil.EnsureDirExist(target)
+			},
+		}}
+	}
+	return []doctorFinding{}
+}
+
+// doctorCheckProjectSymlink checks that the project's symlink inside the workspace (of the symlink Sync Mode)
+// points to the project
+func doctorCheckProjectSymlink(projectPath, fullPackageWorkspacePath string) []doctorFinding {
+	fileInfo, isExists, err := pathutil.PathCheckAndInfos(fullPackageWorkspacePath)
+	if err != nil {
+		return []doctorFinding{{Severity: doctorSeverityError, Message: fmt.Sprintf("failed to check the project's symlink (%s): %s", fullPackageWorkspacePath, err)}}
+	}
+	if !isExists || fileInfo.Mode()&os.ModeSymlink == 0 {
+		// created (or replaced) by the next gows command
+		return []doctorFinding{}
+	}
+
+	target, err := os.Readlink(fullPackageWorkspacePath)
+	if err != nil {
+		return []doctorFinding{{Severity: doctorSeverityError, Message: fmt.Sprintf("failed to read the project's symlink (%s): %s", fullPackageWorkspacePath, err)}}
+	}
+	if filepath.Clean(target) == filepath.Clean(projectPath) {
+		return []doctorFinding{}
+	}
+	return []doctorFinding{{
+		Severity:       doctorSeverityWarning,
+		Message:        fmt.Sprintf("the project's symlink (%s) points to %s, the project was probably moved", fullPackageWorkspacePath, target),
+		FixDescription: "point the symlink to the project",
+		fix: func() error {
+			return gows.CreateOrUpdateSymlink(projectPath, fullPackageWorkspacePath)
+		},
+	}}
+}
+
+// doctorCheckCopyModeActiveFile checks for interrupted copy mode sessions,
+// and GOWS-COPY-MODE-ACTIVE files left in the project
+func doctorCheckCopyModeActiveFile(projectPath string) []doctorFinding {
+	journal, isFound, err := config.LoadCopySessionJournalForProject(projectPath)
+	if err != nil {
+		return []doctorFinding{{Severity: doctorSeverityError, Message: fmt.Sprintf("failed to read the copy session journal: %s", err)}}
+	}
+	if isFound {
+		if isCopySessionRunning(journal) {
+			return []doctorFinding{{
+				Severity: doctorSeverityInfo,
+				Message:  fmt.Sprintf("a copy mode session is in progress (pid: %d, command: %s)", journal.PID, strings.Join(journal.Command, " ")),
+			}}
+		}
+		return []doctorFinding{{
+			Severity: doctorSeverityError,
+			Message:  fmt.Sprintf("the copy mode session was interrupted (command: %s, phase: %s)", strings.Join(journal.Command, " "), journal.Phase),
+			Hint:     fmt.Sprintf("run %s in the project to sync back or discard the changes", colorstring.Green("gows recover")),
+		}}
+	}
+
+	activeFilePath := filepath.Join(projectPath, gowsCopyModeActiveFileName)
+	if isExists, err := pathutil.IsPathExists(activeFilePath); err != nil {
+		return []doctorFinding{{Severity: doctorSeverityError, Message: fmt.Sprintf("failed to check %s: %s", activeFilePath, err)}}
+	} else if !isExists {
+		return []doctorFinding{}
+	}
+	return []doctorFinding{{
+		Severity:       doctorSeverityWarning,
+		Message:        fmt.Sprintf("leftover %s file, without a copy mode session", activeFilePath),
+		FixDescription: "remove the file",
+		fix: func() error {
+			return os.Remove(activeFilePath)
+		},
+	}}
+}
+
+// formatDoctorFindings lists the findings, grouped by their subject
+func formatDoctorFindings(findings []doctorFinding, isFix bool) string {
+	if len(findings) == 0 {
+		return colorstring.Green("No problems found")
+	}
+
+	lines := []string{"", "=== gows doctor ==="}
+	subject := "-"
+	for _, finding := range findings {
+		if finding.Subject != subject {
+			subject = finding.Subject
+			if subject == "" {
+				lines = append(lines, "Global:")
+			} else {
+				lines = append(lines, subject+":")
+			}
+		}
+
+		severity := fmt.Sprintf("[%s]", finding.Severity)
+		switch finding.Severity {
+		case doctorSeverityError:
+			severity = colorstring.Red(severity)
+		case doctorSeverityWarning:
+			severity = colorstring.Yellow(severity)
+		}
+		lines = append(lines, fmt.Sprintf(" %s %s", severity, finding.Message))
+
+		if finding.fix != nil {
+			if isFix {
+				lines = append(lines, fmt.Sprintf("   fix: %s", finding.FixDescription))
+			} else {
+				lines = append(lines, fmt.Sprintf("   fix (with --fix): %s", finding.FixDescription))
+			}
+		} else if finding.Hint != "" {
+			lines = append(lines, fmt.Sprintf("   hint: %s", finding.Hint))
+		}
+	}
+	lines = append(lines, "===================")
+	return strings.Join(lines, "\n")
+}
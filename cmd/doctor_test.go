@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/gows/config"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_doctorChecks(t *testing.T) {
+	homeDir, err := ioutil.TempDir("", "gows-doctor-home-")
+	require.NoError(t, err)
+	homeDir, err = filepath.EvalSymlinks(homeDir)
+	require.NoError(t, err)
+	origHome := os.Getenv("HOME")
+	require.NoError(t, os.Setenv("HOME", homeDir))
+	defer func() {
+		require.NoError(t, os.Setenv("HOME", origHome))
+		require.NoError(t, os.RemoveAll(homeDir))
+	}()
+
+	origGOPATH := filepath.Join(homeDir, "go")
+	wsRootPath := filepath.Join(homeDir, ".bitrise-gows", "wsdirs", "proj-1")
+	projectPath := filepath.Join(homeDir, "projects", "proj")
+	require.NoError(t, os.MkdirAll(wsRootPath, 0755))
+	require.NoError(t, os.MkdirAll(projectPath, 0755))
+	binPath := filepath.Join(wsRootPath, "bin")
+
+	t.Log("Dangling bin symlink")
+	{
+		require.NoError(t, os.Symlink(filepath.Join(origGOPATH, "bin"), binPath))
+
+		findings := doctorCheckBinSymlink(wsRootPath, origGOPATH)
+		require.Equal(t, 1, len(findings))
+		require.Equal(t, doctorSeverityWarning, findings[0].Severity)
+		require.Contains(t, findings[0].Message, "is dangling")
+		require.NoError(t, findings[0].fix())
+
+		require.DirExists(t, filepath.Join(origGOPATH, "bin"))
+		require.Equal(t, 0, len(doctorCheckBinSymlink(wsRootPath, origGOPATH)))
+	}
+
+	t.Log("bin is a directory")
+	{
+		require.NoError(t, os.Remove(binPath))
+		require.NoError(t, os.MkdirAll(binPath, 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(binPath, "tool"), []byte("binary"), 0755))
+
+		// not empty - can't be fixed safely
+		findings := doctorCheckBinSymlink(wsRootPath, origGOPATH)
+		require.Equal(t, 1, len(findings))
+		require.Equal(t, doctorSeverityError, findings[0].Severity)
+		require.Nil(t, findings[0].fix)
+		require.Contains(t, findings[0].Hint, "move its content into "+filepath.Join(origGOPATH, "bin"))
+
+		require.NoError(t, os.Remove(filepath.Join(binPath, "tool")))
+		findings = doctorCheckBinSymlink(wsRootPath, origGOPATH)
+		require.Equal(t, 1, len(findings))
+		require.NoError(t, findings[0].fix())
+
+		target, err := os.Readlink(binPath)
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(origGOPATH, "bin"), target)
+	}
+
+	t.Log("Project symlink pointing to a moved project")
+	{
+		fullPackageWorkspacePath := filepath.Join(wsRootPath, "src", "github.com", "org", "proj")
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPackageWorkspacePath), 0755))
+		require.NoError(t, os.Symlink(filepath.Join(homeDir, "old", "proj"), fullPackageWorkspacePath))
+
+		findings := doctorCheckProjectSymlink(projectPath, fullPackageWorkspacePath)
+		require.Equal(t, 1, len(findings))
+		require.Contains(t, findings[0].Message, "the project was probably moved")
+		require.NoError(t, findings[0].fix())
+
+		target, err := os.Readlink(fullPackageWorkspacePath)
+		require.NoError(t, err)
+		require.Equal(t, projectPath, target)
+		require.Equal(t, 0, len(doctorCheckProjectSymlink(projectPath, fullPackageWorkspacePath)))
+	}
+
+	t.Log("Leftover copy mode active file, and interrupted session")
+	{
+		activeFilePath := filepath.Join(projectPath, gowsCopyModeActiveFileName)
+		require.NoError(t, ioutil.WriteFile(activeFilePath, []byte("active"), 0644))
+
+		findings := doctorCheckCopyModeActiveFile(projectPath)
+		require.Equal(t, 1, len(findings))
+		require.Equal(t, doctorSeverityWarning, findings[0].Severity)
+		require.NoError(t, findings[0].fix())
+		_, err := os.Stat(activeFilePath)
+		require.True(t, os.IsNotExist(err))
+
+		require.NoError(t, config.SaveCopySessionJournal(config.CopySessionJournalModel{
+			ProjectPath:       projectPath,
+			WorkspaceRootPath: wsRootPath,
+			PID:               -1,
+			Command:           []string{"go", "test"},
+			Phase:             config.CopySessionPhaseRunning,
+		}))
+		findings = doctorCheckCopyModeActiveFile(projectPath)
+		require.Equal(t, 1, len(findings))
+		require.Equal(t, doctorSeverityError, findings[0].Severity)
+		require.Nil(t, findings[0].fix)
+		require.NoError(t, config.RemoveCopySessionJournal(projectPath))
+	}
+
+	t.Log("Registry entries of deleted projects and workspaces")
+	{
+		deletedProjectPath := filepath.Join(homeDir, "projects", "deleted")
+		deletedWsProjectPath := filepath.Join(homeDir, "projects", "deleted-ws")
+		require.NoError(t, os.MkdirAll(deletedWsProjectPath, 0755))
+		gowsConfig := config.GOWSConfigModel{
+			Workspaces: map[string]config.WorkspaceConfigModel{
+				projectPath:          {WorkspaceRootPath: wsRootPath},
+				deletedProjectPath:   {WorkspaceRootPath: wsRootPath},
+				deletedWsProjectPath: {WorkspaceRootPath: filepath.Join(homeDir, ".bitrise-gows", "wsdirs", "deleted-1")},
+			},
+		}
+		require.NoError(t, config.SaveGOWSConfigToFile(gowsConfig))
+
+		findings := doctorCheckGlobal(gowsConfig, config.UserConfigModel{})
+		registryFindings := []doctorFinding{}
+		for _, finding := range findings {
+			if finding.Subject != "" {
+				registryFindings = append(registryFindings, finding)
+			}
+		}
+		require.Equal(t, 2, len(registryFindings))
+		require.Equal(t, deletedProjectPath, registryFindings[0].Subject)
+		require.Nil(t, registryFindings[0].fix)
+		require.Equal(t, deletedWsProjectPath, registryFindings[1].Subject)
+		require.NoError(t, registryFindings[1].fix())
+
+		gowsConfig, err := config.LoadGOWSConfigFromFile()
+		require.NoError(t, err)
+		require.Equal(t, 2, len(gowsConfig.Workspaces))
+		_, isFound := gowsConfig.Workspaces[deletedWsProjectPath]
+		require.Equal(t, false, isFound)
+	}
+}
@@ -0,0 +1,232 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/gows/config"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/viktorbenei/cobra.v0"
+)
+
+const (
+	// gowsActiveEnvKey is set (to the project's path) in the shells which entered a workspace with gows env
+	gowsActiveEnvKey = "GOWS_ACTIVE"
+	// gowsOrigGOPATHEnvKey stores the GOPATH of the shell, before it entered the workspace
+	gowsOrigGOPATHEnvKey = "GOWS_ORIG_GOPATH"
+)
+
+const (
+	envFormatPOSIX = "posix"
+	envFormatFish  = "fish"
+	envFormatJSON  = "json"
+)
+
+var (
+	envFormat  = envFormatPOSIX
+	isEnvUnset = false
+	isEnvNoCd  = false
+)
+
+// envCmd represents the env command
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Print the commands to enter (or leave) the project's workspace in the current shell",
+	Long: `Print the commands to enter (or leave) the project's workspace in the current shell.
+
+The workspace is prepared the same way as for any other gows command,
+then the commands which set GOPATH and change the directory to the
+project's path inside the workspace are printed, to be used with eval:
+
+  eval "$(gows env)"                # bash, zsh, ...
+  eval (gows env --format fish)     # fish
+
+To leave the workspace:
+
+  eval "$(gows env --unset)"
+
+With direnv use the --no-cd flag in the project's .envrc:
+
+  eval "$(gows env --no-cd)"`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var change envChange
+		if isEnvUnset {
+			change = leaveWorkspaceEnvChange()
+		} else {
+			var err error
+			change, err = enterWorkspaceEnvChange()
+			if err != nil {
+				return err
+			}
+		}
+		if isEnvNoCd {
+			change.Cd = ""
+			delete(change.Set, "PWD")
+		}
+
+		out, err := change.format(envFormat)
+		if err != nil {
+			return err
+		}
+		fmt.Print(out)
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(envCmd)
+	envCmd.Flags().StringVarP(&envFormat, "format", "", envFormatPOSIX, "Output format (options: posix, fish, json)")
+	envCmd.Flags().BoolVarP(&isEnvUnset, "unset", "", false, "Print the commands to leave the workspace")
+	envCmd.Flags().BoolVarP(&isEnvNoCd, "no-cd", "", false, "Don't change the directory (e.g. for direnv)")
+}
+
+// envChange describes the changes to do in the shell's environment
+type envChange struct {
+	Set   map[string]string `json:"set"`
+	Unset []string          `json:"unset"`
+	// Cd is the directory to change to (optional)
+	Cd string `json:"cd,omitempty"`
+}
+
+func enterWorkspaceEnvChange() (envChange, error) {
+	projectDir, relWorkDir, err := currentProjectDir()
+	if err != nil {
+		return envChange{}, err
+	}
+
+	userConfig := loadUserConfig(projectDir)
+	if userConfig.SyncMode != "" && userConfig.SyncMode != config.SyncModeSymlink {
+		return envChange{}, fmt.Errorf("gows env is only supported in %s sync mode (current sync mode: %s)", config.SyncModeSymlink, userConfig.SyncMode)
+	}
+
+	projectConfig, err := config.LoadProjectConfigFromDir(projectDir)
+	if err != nil {
+		return envChange{}, fmt.Errorf("Failed to read Project Config: %s", err)
+	}
+
+	wsConfig, err := workspaceForProject(projectDir)
+	if err != nil {
+		return envChange{}, err
+	}
+
+	fullPackageWorkspacePath, err := prepareWorkspace(projectDir, projectConfig, wsConfig)
+	if err != nil {
+		return envChange{}, err
+	}
+	if err := linkProjectIntoWorkspace(projectDir, fullPackageWorkspacePath); err != nil {
+		return envChange{}, err
+	}
+	if err := applyWorkspaceLinks(userConfig, filepath.Join(wsConfig.WorkspaceRootPath, "src")); err != nil {
+		return envChange{}, err
+	}
+	if err := linkProjectAliasesIntoWorkspace(projectConfig, wsConfig.WorkspaceRootPath, fullPackageWorkspacePath); err != nil {
+		return envChange{}, err
+	}
+
+	cmdWorkDir := filepath.Join(fullPackageWorkspacePath, relWorkDir)
+	change := envChange{
+		Set: map[string]string{
+			"GOPATH":         wsConfig.WorkspaceRootPath,
+			"PWD":            cmdWorkDir,
+			gowsActiveEnvKey: projectDir,
+		},
+		Unset: []string{},
+		Cd:    cmdWorkDir,
+	}
+	// the original GOPATH is kept when switching from one workspace to another
+	if os.Getenv(gowsActiveEnvKey) == "" {
+		change.Set[gowsOrigGOPATHEnvKey] = os.Getenv("GOPATH")
+	}
+
+	return change, nil
+}
+
+func leaveWorkspaceEnvChange() envChange {
+	change := envChange{
+		Set:   map[string]string{},
+		Unset: []string{gowsActiveEnvKey, gowsOrigGOPATHEnvKey},
+	}
+
+	if origGOPATH := os.Getenv(gowsOrigGOPATHEnvKey); origGOPATH != "" {
+		change.Set["GOPATH"] = origGOPATH
+	} else {
+		change.Unset = append(change.Unset, "GOPATH")
+	}
+
+	// change back to the same directory inside the project
+	if projectDir, relWorkDir, err := currentProjectDir(); err == nil {
+		change.Cd = filepath.Join(projectDir, relWorkDir)
+		change.Set["PWD"] = change.Cd
+	} else if projectDir := os.Getenv(gowsActiveEnvKey); projectDir != "" {
+		log.Debugf("Failed to find the project's root directory: %s", err)
+		change.Cd = projectDir
+		change.Set["PWD"] = change.Cd
+	}
+
+	return change
+}
+
+// format renders the change as shell commands (or as JSON)
+func (change envChange) format(format string) (string, error) {
+	keys := []string{}
+	for key := range change.Set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	lines := []string{}
+	switch format {
+	case envFormatPOSIX:
+		for _, key := range keys {
+			lines = append(lines, fmt.Sprintf("export %s=%s;", key, posixQuote(change.Set[key])))
+		}
+		for _, key := range change.Unset {
+			lines = append(lines, fmt.Sprintf("unset %s;", key))
+		}
+		if change.Cd != "" {
+			lines = append(lines, fmt.Sprintf("cd %s;", posixQuote(change.Cd)))
+		}
+	case envFormatFish:
+		for _, key := range keys {
+			if key == "PWD" {
+				// PWD is read only in fish, it's set by cd
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("set -gx %s %s;", key, fishQuote(change.Set[key])))
+		}
+		for _, key := range change.Unset {
+			lines = append(lines, fmt.Sprintf("set -e %s;", key))
+		}
+		if change.Cd != "" {
+			lines = append(lines, fmt.Sprintf("cd %s;", fishQuote(change.Cd)))
+		}
+	case envFormatJSON:
+		bytes, err := json.MarshalIndent(change, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("Failed to generate JSON: %s", err)
+		}
+		lines = append(lines, string(bytes))
+	default:
+		return "", fmt.Errorf("Unsupported format: %s (options: %s, %s, %s)", format, envFormatPOSIX, envFormatFish, envFormatJSON)
+	}
+
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// posixQuote quotes the value for POSIX shells (sh, bash, zsh, ...)
+func posixQuote(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
+
+// fishQuote quotes the value for the fish shell
+func fishQuote(value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	value = strings.Replace(value, "'", `\'`, -1)
+	return "'" + value + "'"
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/gows/config"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_envChange_format(t *testing.T) {
+	change := envChange{
+		Set: map[string]string{
+			"GOPATH":      "/ws/root",
+			"PWD":         "/ws/root/src/github.com/bitrise-io/it's",
+			"GOWS_ACTIVE": "/proj",
+		},
+		Unset: []string{"GOWS_ORIG_GOPATH"},
+		Cd:    "/ws/root/src/github.com/bitrise-io/it's",
+	}
+
+	t.Log("posix")
+	{
+		out, err := change.format(envFormatPOSIX)
+		require.NoError(t, err)
+		require.Equal(t, `export GOPATH='/ws/root';
+export GOWS_ACTIVE='/proj';
+export PWD='/ws/root/src/github.com/bitrise-io/it'\''s';
+unset GOWS_ORIG_GOPATH;
+cd '/ws/root/src/github.com/bitrise-io/it'\''s';
+`, out)
+	}
+
+	t.Log("fish")
+	{
+		out, err := change.format(envFormatFish)
+		require.NoError(t, err)
+		require.Equal(t, `set -gx GOPATH '/ws/root';
+set -gx GOWS_ACTIVE '/proj';
+set -e GOWS_ORIG_GOPATH;
+cd '/ws/root/src/github.com/bitrise-io/it\'s';
+`, out)
+	}
+
+	t.Log("json")
+	{
+		out, err := change.format(envFormatJSON)
+		require.NoError(t, err)
+		require.Equal(t, `{
+  "set": {
+    "GOPATH": "/ws/root",
+    "GOWS_ACTIVE": "/proj",
+    "PWD": "/ws/root/src/github.com/bitrise-io/it's"
+  },
+  "unset": [
+    "GOWS_ORIG_GOPATH"
+  ],
+  "cd": "/ws/root/src/github.com/bitrise-io/it's"
+}
+`, out)
+	}
+
+	t.Log("unsupported format")
+	{
+		_, err := change.format("powershell")
+		require.Error(t, err)
+	}
+}
+
+func Test_enterWorkspaceEnvChange_Links(t *testing.T) {
+	homeDir, err := ioutil.TempDir("", "gows-env-home-")
+	require.NoError(t, err)
+	homeDir, err = filepath.EvalSymlinks(homeDir)
+	require.NoError(t, err)
+	origHome := os.Getenv("HOME")
+	require.NoError(t, os.Setenv("HOME", homeDir))
+	origWorkDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.Chdir(origWorkDir))
+		require.NoError(t, os.Setenv("HOME", origHome))
+		require.NoError(t, os.RemoveAll(homeDir))
+	}()
+
+	libDir := filepath.Join(homeDir, "dev", "lib")
+	require.NoError(t, os.MkdirAll(libDir, 0755))
+	projectDir := filepath.Join(homeDir, "dev", "project")
+	require.NoError(t, os.MkdirAll(projectDir, 0755))
+	require.NoError(t, config.SaveProjectConfigToDir(projectDir, config.ProjectConfigModel{PackageName: "github.com/org/project"}))
+	require.NoError(t, config.SaveUserConfigToDir(projectDir, config.UserConfigModel{
+		Links: []config.LinkConfigModel{{ImportPath: "github.com/org/lib", Path: libDir}},
+	}))
+	require.NoError(t, initWorkspaceForProjectPath(projectDir, false))
+	require.NoError(t, os.Chdir(projectDir))
+
+	change, err := enterWorkspaceEnvChange()
+	require.NoError(t, err)
+
+	// the linked directories are available in the workspace, just like with gows run
+	target, err := os.Readlink(filepath.Join(change.Set["GOPATH"], "src", "github.com", "org", "lib"))
+	require.NoError(t, err)
+	require.Equal(t, libDir, target)
+	require.Equal(t, filepath.Join(change.Set["GOPATH"], "src", "github.com", "org", "project"), change.Cd)
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/bitrise-io/go-utils/colorstring"
+	"github.com/bitrise-io/gows/config"
+	"github.com/bitrise-io/gows/vcs"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/viktorbenei/cobra.v0"
+)
+
+// freezeCmd represents the freeze command
+var freezeCmd = &cobra.Command{
+	Use:   "freeze",
+	Short: "Record the dependencies in the workspace into gows.lock.yml",
+	Long: `Record the dependencies in the workspace into gows.lock.yml.
+
+Every git and hg checkout in the src directory of the project's workspace
+(e.g. the packages fetched with go get) is recorded with its import path,
+remote and revision into gows.lock.yml, next to gows.yml.
+Commit gows.lock.yml, and your teammates can recreate the same dependency
+versions in their workspaces with gows restore.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		projectDir, _, err := currentProjectDir()
+		if err != nil {
+			return err
+		}
+		projectConfig, err := config.LoadProjectConfigFromDir(projectDir)
+		if err != nil {
+			return fmt.Errorf("Failed to read Project Config: %s", err)
+		}
+		wsConfig, err := workspaceForProject(projectDir)
+		if err != nil {
+			return err
+		}
+
+		depsLock, err := freezeDependencies(filepath.Join(wsConfig.WorkspaceRootPath, "src"), projectImportPaths(projectConfig))
+		if err != nil {
+			return err
+		}
+		if err := config.SaveDependenciesLockToDir(projectDir, depsLock); err != nil {
+			return err
+		}
+
+		for _, dep := range depsLock.Dependencies {
+			log.Infof(" * %s (%s) @ %s", dep.ImportPath, dep.VCS, shortRevision(dep.Revision))
+		}
+		log.Infof("%d dependencies frozen into %s", len(depsLock.Dependencies), colorstring.Green(config.DependenciesLockFileName))
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(freezeCmd)
+}
+
+// projectImportPaths returns the import paths of the project itself inside the workspace
+// (its package name and its aliases), which are not dependencies
+func projectImportPaths(projectConfig config.ProjectConfigModel) []string {
+	importPaths := []string{}
+	if projectConfig.PackageName != "" {
+		importPaths = append(importPaths, projectConfig.PackageName)
+	}
+	return append(importPaths, projectConfig.Aliases...)
+}
+
+// freezeDependencies records the VCS checkouts of the workspace's src directory,
+// except the ones at skipImportPaths
+func freezeDependencies(srcDir string, skipImportPaths []string) (config.DependenciesLockModel, error) {
+	checkouts, err := vcs.FindCheckouts(srcDir, skipImportPaths)
+	if err != nil {
+		return config.DependenciesLockModel{}, err
+	}
+
+	depsLock := config.DependenciesLockModel{Dependencies: []config.DependencyLockModel{}}
+	for _, checkout := range checkouts {
+		remote, err := checkout.Remote()
+		if err != nil {
+			return config.DependenciesLockModel{}, fmt.Errorf("Failed to get the remote of (%s), error: %s", checkout.ImportPath, err)
+		}
+		revision, err := checkout.Revision()
+		if err != nil {
+			return config.DependenciesLockModel{}, fmt.Errorf("Failed to get the revision of (%s), error: %s", checkout.ImportPath, err)
+		}
+
+		if remote == "" {
+			log.Warningf("%s has no remote, it can only be restored from a mirror", checkout.ImportPath)
+		}
+		if isDirty, err := checkout.IsDirty(); err != nil {
+			log.Warningf("Failed to check the uncommitted changes of %s: %s", checkout.ImportPath, err)
+		} else if isDirty {
+			log.Warningf("%s has uncommitted changes, only its revision (%s) is recorded", checkout.ImportPath, shortRevision(revision))
+		}
+
+		depsLock.Dependencies = append(depsLock.Dependencies, config.DependencyLockModel{
+			ImportPath: checkout.ImportPath,
+			VCS:        checkout.VCS,
+			Remote:     remote,
+			Revision:   revision,
+		})
+	}
+	return depsLock, nil
+}
+
+// shortRevision returns the first 12 characters of the revision
+func shortRevision(revision string) string {
+	if len(revision) > 12 {
+		return revision[:12]
+	}
+	return revision
+}
@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/gows/config"
+	"github.com/bitrise-io/gows/vcs"
+	"github.com/stretchr/testify/require"
+)
+
+func runGitInDir(t *testing.T, dir string, args ...string) {
+	args = append([]string{"-c", "user.name=gows", "-c", "user.email=gows@example.com", "-c", "init.defaultBranch=master"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+}
+
+func Test_freezeAndRestoreDependencies(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gows-freeze-")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(tmpDir))
+	}()
+
+	// a bare remote with one commit, checked out into the workspace
+	remotePath := filepath.Join(tmpDir, "remotes", "lib.git")
+	require.NoError(t, os.MkdirAll(remotePath, 0755))
+	runGitInDir(t, remotePath, "init", "--quiet", "--bare")
+
+	srcDir := filepath.Join(tmpDir, "ws", "src")
+	libDir := filepath.Join(srcDir, "github.com", "org", "lib")
+	runGitInDir(t, tmpDir, "clone", "--quiet", remotePath, libDir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(libDir, "lib.go"), []byte("package lib"), 0644))
+	runGitInDir(t, libDir, "add", "lib.go")
+	runGitInDir(t, libDir, "commit", "--quiet", "-m", "lib")
+	runGitInDir(t, libDir, "push", "--quiet", "origin", "HEAD:master")
+
+	// the project itself
+	projectDir := filepath.Join(srcDir, "github.com", "org", "project")
+	require.NoError(t, os.MkdirAll(projectDir, 0755))
+	runGitInDir(t, projectDir, "init", "--quiet")
+
+	var depsLock config.DependenciesLockModel
+	t.Log("Freeze - the project is not a dependency")
+	{
+		depsLock, err = freezeDependencies(srcDir, projectImportPaths(config.ProjectConfigModel{PackageName: "github.com/org/project"}))
+		require.NoError(t, err)
+		require.Equal(t, 1, len(depsLock.Dependencies))
+
+		revision, err := vcs.Checkout{Dir: libDir, VCS: vcs.Git}.Revision()
+		require.NoError(t, err)
+		require.Equal(t, config.DependencyLockModel{
+			ImportPath: "github.com/org/lib",
+			VCS:        vcs.Git,
+			Remote:     remotePath,
+			Revision:   revision,
+		}, depsLock.Dependencies[0])
+	}
+
+	t.Log("Restore into a new workspace")
+	{
+		newSrcDir := filepath.Join(tmpDir, "new-ws", "src")
+		require.NoError(t, restoreDependencies(newSrcDir, depsLock, []string{}))
+
+		newDepsLock, err := freezeDependencies(newSrcDir, []string{})
+		require.NoError(t, err)
+		require.Equal(t, depsLock, newDepsLock)
+	}
+
+	t.Log("Restore from a mirror")
+	{
+		mirrorDir := filepath.Join(tmpDir, "mirror")
+		require.NoError(t, os.MkdirAll(filepath.Join(mirrorDir, "github.com", "org"), 0755))
+		require.NoError(t, os.Rename(remotePath, filepath.Join(mirrorDir, "github.com", "org", "lib.git")))
+
+		newSrcDir := filepath.Join(tmpDir, "mirror-ws", "src")
+		require.NoError(t, restoreDependencies(newSrcDir, depsLock, []string{mirrorDir}))
+
+		newDepsLock, err := freezeDependencies(newSrcDir, []string{})
+		require.NoError(t, err)
+		require.Equal(t, depsLock, newDepsLock)
+	}
+
+	t.Log("Invalid import path")
+	{
+		err := restoreDependencies(srcDir, config.DependenciesLockModel{Dependencies: []config.DependencyLockModel{
+			{ImportPath: "../outside", VCS: vcs.Git, Remote: remotePath, Revision: depsLock.Dependencies[0].Revision},
+		}}, []string{})
+		require.EqualError(t, err, "Failed to restore 1 dependencies: ../outside")
+	}
+
+	t.Log("Clean - the checkouts not recorded are removed, except the project and the dirty ones")
+	{
+		extraDir := filepath.Join(srcDir, "example.com", "extra")
+		require.NoError(t, os.MkdirAll(extraDir, 0755))
+		runGitInDir(t, extraDir, "init", "--quiet")
+		dirtyDir := filepath.Join(srcDir, "example.com", "dirty")
+		require.NoError(t, os.MkdirAll(dirtyDir, 0755))
+		runGitInDir(t, dirtyDir, "init", "--quiet")
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dirtyDir, "new.go"), []byte("package dirty"), 0644))
+
+		require.NoError(t, cleanDependencies(srcDir, depsLock, []string{"github.com/org/project"}))
+
+		for pth, isExpected := range map[string]bool{libDir: true, projectDir: true, dirtyDir: true, extraDir: false} {
+			isExists, err := pathExists(pth)
+			require.NoError(t, err)
+			require.Equal(t, isExpected, isExists, pth)
+		}
+	}
+}
+
+func Test_validateDependencyImportPath(t *testing.T) {
+	require.NoError(t, validateDependencyImportPath("github.com/org/lib"))
+	for _, importPath := range []string{"", "/abs/path", "..", "../lib", "github.com/../../lib", "github.com/org/lib/"} {
+		require.Error(t, validateDependencyImportPath(importPath), importPath)
+	}
+}
+
+func Test_restoreDependencies_MaliciousLock(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gows-restore-")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(tmpDir))
+	}()
+
+	srcDir := filepath.Join(tmpDir, "src")
+	markerPth := filepath.Join(tmpDir, "pwned")
+	revision := "0123456789012345678901234567890123456789"
+	depsLock := config.DependenciesLockModel{
+		Dependencies: []config.DependencyLockModel{
+			{ImportPath: "github.com/org/hg-hook", VCS: vcs.Mercurial, Remote: "--config=hooks.pre-clone=touch " + markerPth, Revision: revision},
+			{ImportPath: "github.com/org/git-upload-pack", VCS: vcs.Git, Remote: "--upload-pack=touch " + markerPth, Revision: revision},
+			{ImportPath: "github.com/org/git-revision", VCS: vcs.Git, Remote: filepath.Join(tmpDir, "remote.git"), Revision: "--output=" + markerPth},
+			{ImportPath: "github.com/org/hgrc", VCS: vcs.Mercurial, Remote: "https://example.com/hgrc\n[hooks]\nupdate=touch " + markerPth, Revision: revision},
+		},
+	}
+
+	err = restoreDependencies(srcDir, depsLock, nil)
+	require.EqualError(t, err, "Failed to restore 4 dependencies: github.com/org/hg-hook, github.com/org/git-upload-pack, github.com/org/git-revision, github.com/org/hgrc")
+
+	// nothing is run, nothing is cloned
+	for _, pth := range []string{markerPth, srcDir} {
+		isExists, err := pathExists(pth)
+		require.NoError(t, err)
+		require.Equal(t, false, isExists, pth)
+	}
+
+	require.NoError(t, validateDependencyLock(config.DependencyLockModel{ImportPath: "github.com/org/lib", VCS: vcs.Git, Remote: "https://github.com/org/lib.git", Revision: revision}))
+}
+
+func Test_projectImportPaths(t *testing.T) {
+	require.Equal(t, []string{}, projectImportPaths(config.ProjectConfigModel{}))
+	require.Equal(t, []string{"github.com/ourteam/x", "github.com/upstream/x"}, projectImportPaths(config.ProjectConfigModel{
+		PackageName: "github.com/ourteam/x",
+		Aliases:     []string{"github.com/upstream/x"},
+	}))
+}
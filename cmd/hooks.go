@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/bitrise-io/gows/config"
+	"github.com/bitrise-io/gows/gows"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// gowsHookEnvKey is set to the name of the hook (pre_prepare, post_command, ...) for the hook commands
+	gowsHookEnvKey = "GOWS_HOOK"
+	// gowsExitCodeEnvKey is set to the exit code of the command for the post_command and post_sync_back hooks
+	gowsExitCodeEnvKey = "GOWS_EXIT_CODE"
+)
+
+var (
+	isNoHooksFlag = false
+)
+
+// hookRunner runs the hooks of the project config and of the user config
+type hookRunner struct {
+	projectDir string
+	// workspacePackageDir - the directory the post_prepare, pre_command and post_command hooks run in,
+	// the project's package inside the workspace (in copy mode the command sees only the changes made there)
+	workspacePackageDir string
+	workspaceRootPath   string
+	projectHooks      config.HooksConfigModel
+	userHooks         config.HooksConfigModel
+	// cmdOptions - the options of the command run by gows, the hooks get the same environment
+	cmdOptions gows.CommandOptions
+	isDisabled bool
+}
+
+func newHookRunner(projectDir, workspacePackageDir, workspaceRootPath string, projectConfig config.ProjectConfigModel, userConfig config.UserConfigModel, cmdOptions gows.CommandOptions) hookRunner {
+	return hookRunner{
+		projectDir:          projectDir,
+		workspacePackageDir: workspacePackageDir,
+		workspaceRootPath:   workspaceRootPath,
+		projectHooks:        projectConfig.Hooks,
+		userHooks:           userConfig.Hooks,
+		cmdOptions:          cmdOptions,
+		isDisabled:          isNoHooksFlag,
+	}
+}
+
+// workDir returns the directory the commands of the hook run in:
+// the project's directory before the workspace is prepared and after the sync back,
+// the project's package inside the workspace otherwise
+func (runner hookRunner) workDir(hook string) string {
+	switch hook {
+	case config.HookPrePrepare, config.HookPostSyncBack:
+		return runner.projectDir
+	default:
+		return runner.workspacePackageDir
+	}
+}
+
+// commands returns the commands of the hook, the project's hooks first, then the user's hooks
+func (runner hookRunner) commands(hook string) []string {
+	commands := []string{}
+	commands = append(commands, runner.projectHooks.Commands(hook)...)
+	commands = append(commands, runner.userHooks.Commands(hook)...)
+	return commands
+}
+
+// run runs the commands of the hook (with sh -c) in the hook's work directory, and stops at the first failing one.
+// exitCode is the exit code of the command run by gows, exposed as $GOWS_EXIT_CODE (if not nil).
+func (runner hookRunner) run(hook string, exitCode *int) error {
+	commands := runner.commands(hook)
+	if len(commands) == 0 {
+		return nil
+	}
+	if runner.isDisabled {
+		log.Debugf("[Hook] %s: hooks are disabled, skipping %d command(s)", hook, len(commands))
+		return nil
+	}
+
+	hookOptions := gows.CommandOptions{
+		Env: map[string]string{},
+	}
+	for key, value := range runner.cmdOptions.Env {
+		hookOptions.Env[key] = value
+	}
+	hookOptions.Env[gowsHookEnvKey] = hook
+	if exitCode != nil {
+		hookOptions.Env[gowsExitCodeEnvKey] = strconv.Itoa(*exitCode)
+	}
+	if runner.cmdOptions.Hermetic != nil {
+		// hooks (e.g. notifications) are never isolated from the network
+		hookOptions.Hermetic = &gows.HermeticOptions{
+			EnvAllowlist: runner.cmdOptions.Hermetic.EnvAllowlist,
+		}
+	}
+
+	for _, hookCommand := range commands {
+		log.Infof("[Hook] %s: $ %s", hook, hookCommand)
+		cmd, err := gows.CreateCommandWithOptions(runner.workDir(hook), runner.workspaceRootPath, hookOptions, "sh", "-c", hookCommand)
+		if err != nil {
+			return fmt.Errorf("Failed to create the %s hook command (%s), error: %s", hook, hookCommand, err)
+		}
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("The %s hook command (%s) failed, error: %s", hook, hookCommand, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/gows/config"
+	"github.com/bitrise-io/gows/gows"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_hookRunner(t *testing.T) {
+	projectDir, err := ioutil.TempDir("", "gows-hooks-")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(projectDir))
+	}()
+	projectDir, err = filepath.EvalSymlinks(projectDir)
+	require.NoError(t, err)
+	logPth := filepath.Join(projectDir, "hooks.log")
+	workspacePackageDir := filepath.Join(projectDir, "ws", "src", "github.com", "org", "project")
+	require.NoError(t, os.MkdirAll(workspacePackageDir, 0755))
+
+	projectConfig := config.ProjectConfigModel{
+		Hooks: config.HooksConfigModel{
+			PrePrepare:   []string{`echo "project $GOWS_HOOK $(pwd)" >> "$HOOKS_LOG"`},
+			PreCommand:   []string{`echo "project $GOWS_HOOK $(pwd) $GOPATH $CUSTOM" >> "$HOOKS_LOG"`},
+			PostCommand:  []string{`echo "project $GOWS_HOOK $GOWS_EXIT_CODE" >> "$HOOKS_LOG"`},
+			PostPrepare:  []string{`echo first >> "$HOOKS_LOG"`, "exit 2", `echo not-run >> "$HOOKS_LOG"`},
+			PostSyncBack: []string{`echo "project $GOWS_HOOK $(pwd)" >> "$HOOKS_LOG"`},
+		},
+	}
+	userConfig := config.UserConfigModel{
+		Hooks: config.HooksConfigModel{
+			PreCommand: []string{`echo "user $GOWS_HOOK" >> "$HOOKS_LOG"`},
+		},
+	}
+	cmdOptions := gows.CommandOptions{Env: map[string]string{"CUSTOM": "custom-value", "HOOKS_LOG": logPth}}
+
+	readLog := func() string {
+		content, err := ioutil.ReadFile(logPth)
+		require.NoError(t, err)
+		require.NoError(t, os.Remove(logPth))
+		return string(content)
+	}
+
+	t.Log("Project hooks run first, in the project's package inside the workspace, with the command's environment")
+	{
+		runner := newHookRunner(projectDir, workspacePackageDir, "/ws/root", projectConfig, userConfig, cmdOptions)
+		require.NoError(t, runner.run(config.HookPreCommand, nil))
+		require.Equal(t, "project pre_command "+workspacePackageDir+" /ws/root custom-value\nuser pre_command\n", readLog())
+	}
+
+	t.Log("pre_prepare and post_sync_back run in the project's directory")
+	{
+		runner := newHookRunner(projectDir, workspacePackageDir, "/ws/root", projectConfig, userConfig, cmdOptions)
+		require.NoError(t, runner.run(config.HookPrePrepare, nil))
+		require.NoError(t, runner.run(config.HookPostSyncBack, nil))
+		require.Equal(t, "project pre_prepare "+projectDir+"\nproject post_sync_back "+projectDir+"\n", readLog())
+	}
+
+	t.Log("Exit code")
+	{
+		runner := newHookRunner(projectDir, workspacePackageDir, "/ws/root", projectConfig, userConfig, cmdOptions)
+		exitCode := 3
+		require.NoError(t, runner.run(config.HookPostCommand, &exitCode))
+		require.Equal(t, "project post_command 3\n", readLog())
+	}
+
+	t.Log("Stops at the first failing command")
+	{
+		runner := newHookRunner(projectDir, workspacePackageDir, "/ws/root", projectConfig, userConfig, cmdOptions)
+		err := runner.run(config.HookPostPrepare, nil)
+		require.EqualError(t, err, "The post_prepare hook command (exit 2) failed, error: exit status 2")
+		require.Equal(t, "first\n", readLog())
+	}
+
+	t.Log("No hooks")
+	{
+		runner := newHookRunner(projectDir, workspacePackageDir, "/ws/root", config.ProjectConfigModel{}, config.UserConfigModel{}, cmdOptions)
+		require.NoError(t, runner.run(config.HookPostSyncBack, nil))
+	}
+
+	t.Log("Disabled hooks")
+	{
+		runner := newHookRunner(projectDir, workspacePackageDir, "/ws/root", projectConfig, userConfig, cmdOptions)
+		runner.isDisabled = true
+		require.NoError(t, runner.run(config.HookPreCommand, nil))
+		_, err := os.Stat(logPth)
+		require.True(t, os.IsNotExist(err))
+	}
+}
+
+func Test_hooks_CopyMode(t *testing.T) {
+	homeDir, err := ioutil.TempDir("", "gows-hooks-home-")
+	require.NoError(t, err)
+	homeDir, err = filepath.EvalSymlinks(homeDir)
+	require.NoError(t, err)
+	origHome := os.Getenv("HOME")
+	require.NoError(t, os.Setenv("HOME", homeDir))
+	origGOPATH := os.Getenv("GOPATH")
+	require.NoError(t, os.Setenv("GOPATH", filepath.Join(homeDir, "go")))
+	origWorkDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.Chdir(origWorkDir))
+		require.NoError(t, os.Setenv("GOPATH", origGOPATH))
+		require.NoError(t, os.Setenv("HOME", origHome))
+		require.NoError(t, os.RemoveAll(homeDir))
+	}()
+
+	projectDir := filepath.Join(homeDir, "project")
+	require.NoError(t, os.MkdirAll(projectDir, 0755))
+	require.NoError(t, config.SaveProjectConfigToDir(projectDir, config.ProjectConfigModel{
+		PackageName: "github.com/org/project",
+		Hooks: config.HooksConfigModel{
+			// e.g. code generation
+			PreCommand:  []string{"echo 'package project' > generated.go"},
+			PostCommand: []string{"echo $GOWS_EXIT_CODE > post_command.txt"},
+		},
+	}))
+	require.NoError(t, os.Chdir(projectDir))
+
+	// the command sees the files generated by the pre_command hook
+	exitCode, err := PrepareEnvironmentAndRunCommand(config.UserConfigModel{SyncMode: config.SyncModeCopy}, "test", "-f", "generated.go")
+	require.NoError(t, err)
+	require.Equal(t, 0, exitCode)
+
+	// and the hooks' changes are synced back into the project
+	content, err := ioutil.ReadFile(filepath.Join(projectDir, "generated.go"))
+	require.NoError(t, err)
+	require.Equal(t, "package project\n", string(content))
+	content, err = ioutil.ReadFile(filepath.Join(projectDir, "post_command.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "0\n", string(content))
+}
+
+func Test_commandExitCode(t *testing.T) {
+	require.Equal(t, 0, commandExitCode(0, nil))
+	require.Equal(t, 3, commandExitCode(3, errors.New("exit status 3")))
+	require.Equal(t, 1, commandExitCode(0, errors.New("failed to start")))
+}
@@ -11,6 +11,7 @@ import (
 
 	log "github.com/sirupsen/logrus"
 	"github.com/bitrise-io/go-utils/colorstring"
+	"github.com/bitrise-io/go-utils/pathutil"
 	"github.com/bitrise-io/gows/config"
 	"github.com/bitrise-io/gows/goutil"
 	"gopkg.in/viktorbenei/cobra.v0"
@@ -18,13 +19,20 @@ import (
 
 var (
 	isAllowReset = false
+	isFork       = false
 )
 
 // initCmd represents the init command
 var initCmd = &cobra.Command{
 	Use:           "init",
 	Short:         "Initialize gows for your Go project",
-	Long:          ``,
+	Long: `Initialize gows for your Go project.
+
+If go-package-name is not specified it's determined from the git remote (origin).
+
+With --fork the project is a fork of an upstream package: the package name of the
+upstream git remote is added to the aliases in gows.yml, so the project is
+exposed at the upstream package's import path too, inside the workspace.`,
 	SilenceUsage:  true,
 	SilenceErrors: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -47,11 +55,29 @@ var initCmd = &cobra.Command{
 			packageName = args[0]
 		}
 
+		aliases := []string{}
+		if isFork {
+			upstreamPackageName, err := autoScanPackageNameOfDir("", "upstream")
+			if err != nil {
+				log.Info("Add the upstream remote with: " + colorstring.Green("git remote add upstream URL"))
+				return fmt.Errorf("Failed to determine the upstream package name: %s", err)
+			}
+			if upstreamPackageName == packageName {
+				return fmt.Errorf("The upstream package name is the same as the package name (%s)", packageName)
+			}
+			log.Infof(" Scanned upstream package name: %s", upstreamPackageName)
+			aliases = append(aliases, upstreamPackageName)
+		}
+
+		projectDir, err := projectDirForInit(isAllowReset)
+		if err != nil {
+			return err
+		}
 		if isAllowReset {
-			log.Warning(colorstring.Red("Will reset the related workspace"))
+			log.Warning(colorstring.Red("Will reset the related workspace") + " (project: " + projectDir + ")")
 		}
 
-		if err := InitGOWS(packageName, isAllowReset); err != nil {
+		if err := initGOWS(projectDir, packageName, aliases, isAllowReset); err != nil {
 			return fmt.Errorf("Failed to initialize: %s", err)
 		}
 
@@ -67,11 +93,45 @@ func init() {
 		"reset", "",
 		false,
 		"Delete previous workspace (if any) and initialize a new one")
+	initCmd.Flags().BoolVarP(&isFork,
+		"fork", "",
+		false,
+		"The project is a fork: expose it at the package name of the upstream git remote too")
+}
+
+// projectDirForInit returns the directory to initialize: the working directory,
+// or with reset the root directory of the project the working directory belongs to.
+// A project can't be initialized inside another one.
+func projectDirForInit(isAllowReset bool) (string, error) {
+	projectDir, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("Failed to get current working directory: %s", err)
+	}
+
+	rootDir, relWorkDir, err := currentProjectDir()
+	if err != nil {
+		// not initialized yet
+		return projectDir, nil
+	}
+	if isAllowReset {
+		return rootDir, nil
+	}
+	if relWorkDir != "." {
+		return "", fmt.Errorf("The working directory is inside an initialized project (%s) - run gows init in the project's root directory, or use --reset to reset the project's workspace", rootDir)
+	}
+	return projectDir, nil
 }
 
 // AutoScanPackageName ...
 func AutoScanPackageName() (string, error) {
-	cmd := exec.Command("git", "remote", "get-url", "origin")
+	return autoScanPackageNameOfDir("", "origin")
+}
+
+// autoScanPackageNameOfDir determines the package name of the git checkout in dir
+// (the current directory if dir is empty) from the URL of its remote
+func autoScanPackageNameOfDir(dir, remoteName string) (string, error) {
+	cmd := exec.Command("git", "remote", "get-url", remoteName)
+	cmd.Dir = dir
 	out, err := cmd.Output()
 	if err != nil {
 		if exitError, ok := err.(*exec.ExitError); ok {
@@ -79,7 +139,7 @@ func AutoScanPackageName() (string, error) {
 		} else {
 			log.Error("[AutoScanPackageName] Failed to convert error to ExitError")
 		}
-		return "", fmt.Errorf("Failed to get git remote url for origin: %s", err)
+		return "", fmt.Errorf("Failed to get git remote url for %s: %s", remoteName, err)
 	}
 
 	outStr := string(out)
@@ -114,98 +174,175 @@ func initWorkspaceForProjectPath(projectPath string, isAllowReset bool) error {
 	if err != nil {
 		return fmt.Errorf("Failed to get absolute path for gows workspaces root dir, error: %s", err)
 	}
-
-	// Create the Workspace
-	gowsConfig, err := config.LoadGOWSConfigFromFile()
+	canonicalProjectPath, err := config.CanonicalProjectPath(projectPath)
+	if err != nil {
+		return fmt.Errorf("Failed to get canonical path of the project (%s), error: %s", projectPath, err)
+	}
+	workspaceNameTemplate := loadUserConfig(projectPath).WorkspaceNameTemplate
+	projectID, err := ensureProjectID(canonicalProjectPath)
 	if err != nil {
-		return fmt.Errorf("Failed to load gows config: %s", err)
+		return err
 	}
 
-	projectWorkspaceAbsPath := ""
-	wsConfig, isFound := gowsConfig.WorkspaceForProjectLocation(projectPath)
-	if isFound {
-		if wsConfig.WorkspaceRootPath == "" {
-			return fmt.Errorf("A workspace is found for this project (path: %s), but the workspace root directory path is not defined", projectPath)
-		}
-		projectWorkspaceAbsPath = wsConfig.WorkspaceRootPath
+	// Create the Workspace
+	// the gows config is locked while the workspace is created & registered,
+	// so that parallel gows processes can't register different workspaces for the same project
+	err = config.UpdateGOWSConfig(func(gowsConfig *config.GOWSConfigModel) error {
+		projectWorkspaceAbsPath := ""
+		isReused := false
+		wsConfig, isFound := gowsConfig.WorkspaceForProjectLocation(projectPath)
+		if isFound {
+			if wsConfig.WorkspaceRootPath == "" {
+				return fmt.Errorf("A workspace is found for this project (path: %s), but the workspace root directory path is not defined", projectPath)
+			}
+			projectWorkspaceAbsPath = wsConfig.WorkspaceRootPath
 
-		if isAllowReset {
-			if err := os.RemoveAll(projectWorkspaceAbsPath); err != nil {
-				return fmt.Errorf("Failed to delete previous workspace at path: %s", projectWorkspaceAbsPath)
+			otherProjectPaths := []string{}
+			for _, aProjectPath := range gowsConfig.ProjectsOfWorkspace(projectWorkspaceAbsPath) {
+				if aProjectPath != canonicalProjectPath {
+					otherProjectPaths = append(otherProjectPaths, aProjectPath)
+				}
 			}
-			// init a new one
-			projectWorkspaceAbsPath = ""
-		} else {
-			log.Warning(colorstring.Yellow("A workspace already exists for this project") + " (" + projectWorkspaceAbsPath + "), will be reused.")
-			log.Warning("If you want to delete the previous workspace of this project and generate a new one you should run: " + colorstring.Green("gows clear"))
-		}
-	}
 
-	if projectWorkspaceAbsPath == "" {
-		// generate one
-		projectBaseWorkspaceDirName := fmt.Sprintf("%s-%d", filepath.Base(projectPath), time.Now().Unix())
-		projectWorkspaceAbsPath = filepath.Join(gowsWorspacesRootDirAbsPath, projectBaseWorkspaceDirName)
-	}
+			if len(otherProjectPaths) > 0 {
+				// e.g. the workspaces of projects with the same name, initialized in the same second
+				// by an earlier gows version - the workspace is kept for the other project(s)
+				log.Warning(colorstring.Yellow("The workspace of this project") + " (" + projectWorkspaceAbsPath + ") " +
+					colorstring.Yellow("is shared with other projects") + " (" + strings.Join(otherProjectPaths, ", ") + "), a new workspace will be created for this project.")
+				projectWorkspaceAbsPath = ""
+			} else if isAllowReset {
+				if err := os.RemoveAll(projectWorkspaceAbsPath); err != nil {
+					return fmt.Errorf("Failed to delete previous workspace at path: %s", projectWorkspaceAbsPath)
+				}
+				// init a new one
+				projectWorkspaceAbsPath = ""
+			} else {
+				isReused = true
+				log.Warning(colorstring.Yellow("A workspace already exists for this project") + " (" + projectWorkspaceAbsPath + "), will be reused.")
+				log.Warning("If you want to delete the previous workspace of this project and generate a new one you should run: " + colorstring.Green("gows clear"))
+			}
+		}
 
-	log.Debugf("  projectWorkspaceAbsPath: %s", projectWorkspaceAbsPath)
-	if err := initGoWorkspaceAtPath(projectWorkspaceAbsPath); err != nil {
-		return fmt.Errorf("Failed to initialize workspace at path: %s", projectWorkspaceAbsPath)
-	}
-	log.Debugf("  Workspace successfully created")
+		if projectWorkspaceAbsPath == "" {
+			// generate one
+			projectBaseWorkspaceDirName, err := config.WorkspaceDirName(workspaceNameTemplate, projectPath)
+			if err != nil {
+				return err
+			}
+			projectWorkspaceAbsPath, err = gowsConfig.AvailableWorkspaceRootPath(gowsWorspacesRootDirAbsPath, projectBaseWorkspaceDirName, projectPath)
+			if err != nil {
+				return err
+			}
+		}
 
-	// Save the location into Workspace config
-	{
-		workspaceConf := config.WorkspaceConfigModel{
-			WorkspaceRootPath: projectWorkspaceAbsPath,
+		log.Debugf("  projectWorkspaceAbsPath: %s", projectWorkspaceAbsPath)
+		if err := initGoWorkspaceAtPath(projectWorkspaceAbsPath); err != nil {
+			return fmt.Errorf("Failed to initialize workspace at path: %s", projectWorkspaceAbsPath)
 		}
-		gowsConfig.Workspaces[projectPath] = workspaceConf
+		log.Debugf("  Workspace successfully created")
 
-		if err := config.SaveGOWSConfigToFile(gowsConfig); err != nil {
-			return fmt.Errorf("Failed to save gows config: %s", err)
+		// Save the location into Workspace config
+		// (the metadata of a reused workspace is kept)
+		workspaceConf := wsConfig
+		if !isReused {
+			workspaceConf = config.WorkspaceConfigModel{
+				WorkspaceRootPath: projectWorkspaceAbsPath,
+				CreatedAt:         time.Now(),
+			}
 		}
+		workspaceConf.ProjectID = projectID
+		if err := gowsConfig.SetWorkspaceForProjectLocation(projectPath, workspaceConf); err != nil {
+			return fmt.Errorf("Failed to register the workspace of the project: %s", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to save gows config: %s", err)
 	}
 	log.Debug("[Init] Workspace Config saved")
 
 	return nil
 }
 
-// InitGOWS ...
+// InitGOWS initializes the project in the current working directory, and its workspace
 func InitGOWS(packageName string, isAllowReset bool) error {
+	projectDir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("Failed to get current working directory: %s", err)
+	}
+	return initGOWS(projectDir, packageName, []string{}, isAllowReset)
+}
+
+// projectConfigForInit returns the project config to save on init: the existing project config of the project
+// (if any) with the package name set, and the aliases added to it (without duplicates)
+func projectConfigForInit(projectDir, packageName string, aliases []string) (config.ProjectConfigModel, error) {
+	projectConf := config.ProjectConfigModel{}
+	isExists, err := pathutil.IsPathExists(filepath.Join(projectDir, config.ProjectConfigFileName))
+	if err != nil {
+		return config.ProjectConfigModel{}, fmt.Errorf("Failed to check the Project Config, error: %s", err)
+	}
+	if isExists {
+		projectConf, err = config.LoadProjectConfigFromDir(projectDir)
+		if err != nil {
+			return config.ProjectConfigModel{}, fmt.Errorf("Failed to read Project Config: %s", err)
+		}
+	}
+
+	projectConf.PackageName = packageName
+	for _, alias := range aliases {
+		isAdded := alias == packageName
+		for _, existingAlias := range projectConf.Aliases {
+			if existingAlias == alias {
+				isAdded = true
+				break
+			}
+		}
+		if !isAdded {
+			projectConf.Aliases = append(projectConf.Aliases, alias)
+		}
+	}
+	return projectConf, nil
+}
+
+// initGOWS initializes the project (in projectDir) and its workspace.
+// The rest of an existing project config (scripts, hooks, env, ...) is kept,
+// only its package name is set, and the aliases are added to it.
+func initGOWS(projectDir, packageName string, aliases []string, isAllowReset bool) error {
 	log.Infof("[Init] Initializing package: %s", packageName)
 
 	log.Info("[Init] Initializing Project Config ...")
 	{
-		projectConf := config.ProjectConfigModel{
-			PackageName: packageName,
+		projectConf, err := projectConfigForInit(projectDir, packageName, aliases)
+		if err != nil {
+			return err
+		}
+		for _, alias := range projectConf.Aliases {
+			log.Infof("       alias: %s", alias)
 		}
 
-		if err := config.SaveProjectConfigToFile(projectConf); err != nil {
+		if err := config.SaveProjectConfigToDir(projectDir, projectConf); err != nil {
 			return fmt.Errorf("Failed to write Project Config into file: %s", err)
 		}
-		log.Infof("       [OK] Project Config file saved to: %s", colorstring.Green(config.ProjectConfigFilePath))
+		log.Infof("       [OK] Project Config file saved to: %s", colorstring.Green(filepath.Join(projectDir, config.ProjectConfigFileName)))
 	}
 
 	log.Info("[Init] Initializing User Config ...")
 	{
-		if _, err := config.LoadUserConfigFromFile(); err == nil {
-			log.Infof("       [OK] User Config file already exists at %s - will not generate a new one", config.UserConfigFilePath)
+		userConfigFilePath := filepath.Join(projectDir, config.UserConfigFileName)
+		if _, err := config.LoadUserConfigFromDir(projectDir); err == nil {
+			log.Infof("       [OK] User Config file already exists at %s - will not generate a new one", userConfigFilePath)
 		} else {
 			userConf := config.CreateDefaultUserConfig()
 
-			if err := config.SaveUserConfigToFile(userConf); err != nil {
+			if err := config.SaveUserConfigToDir(projectDir, userConf); err != nil {
 				return fmt.Errorf("Failed to write User Config into file: %s", err)
 			}
-			log.Info("       [OK] User Config file saved as " + colorstring.Green(config.UserConfigFilePath) + " - " + colorstring.Yellow("please add it to your .gitignore file!"))
+			log.Info("       [OK] User Config file saved as " + colorstring.Green(userConfigFilePath) + " - " + colorstring.Yellow("please add it to your .gitignore file!"))
 		}
 	}
 
 	// init workspace for project (path)
-	currWorkDir, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("Failed to get current working directory: %s", err)
-	}
-
-	if err := initWorkspaceForProjectPath(currWorkDir, isAllowReset); err != nil {
+	if err := initWorkspaceForProjectPath(projectDir, isAllowReset); err != nil {
 		return fmt.Errorf("Failed to initialize Workspace for Project: %s", err)
 	}
 
@@ -1 +1,195 @@
 package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/gows/config"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_initWorkspaceForProjectPath(t *testing.T) {
+	homeDir, err := ioutil.TempDir("", "gows-init-home-")
+	require.NoError(t, err)
+	homeDir, err = filepath.EvalSymlinks(homeDir)
+	require.NoError(t, err)
+	origHome := os.Getenv("HOME")
+	require.NoError(t, os.Setenv("HOME", homeDir))
+	defer func() {
+		require.NoError(t, os.Setenv("HOME", origHome))
+		require.NoError(t, os.RemoveAll(homeDir))
+	}()
+
+	projectDir := filepath.Join(homeDir, "team-a", "api")
+	otherProjectDir := filepath.Join(homeDir, "team-b", "api")
+	require.NoError(t, os.MkdirAll(projectDir, 0755))
+	require.NoError(t, os.MkdirAll(otherProjectDir, 0755))
+
+	workspaceOf := func(projectPath string) string {
+		gowsConfig, err := config.LoadGOWSConfigFromFile()
+		require.NoError(t, err)
+		wsConfig, isFound := gowsConfig.WorkspaceForProjectLocation(projectPath)
+		require.Equal(t, true, isFound)
+		return wsConfig.WorkspaceRootPath
+	}
+
+	t.Log("Projects with the same name get different workspaces")
+	{
+		require.NoError(t, initWorkspaceForProjectPath(projectDir, false))
+		require.NoError(t, initWorkspaceForProjectPath(otherProjectDir, false))
+
+		wsPath := workspaceOf(projectDir)
+		otherWsPath := workspaceOf(otherProjectDir)
+		require.NotEqual(t, wsPath, otherWsPath)
+		require.Regexp(t, `/api-[0-9a-f]{12}$`, wsPath)
+		require.DirExists(t, filepath.Join(wsPath, "src"))
+
+		t.Log("reset keeps the same (deterministic) name")
+		{
+			require.NoError(t, initWorkspaceForProjectPath(projectDir, true))
+			require.Equal(t, wsPath, workspaceOf(projectDir))
+		}
+	}
+
+	t.Log("Existing timestamp named workspaces are kept, shared ones are replaced")
+	{
+		legacyWsPath := filepath.Join(homeDir, ".bitrise-gows", "wsdirs", "api-1500000000")
+		require.NoError(t, config.UpdateGOWSConfig(func(gowsConfig *config.GOWSConfigModel) error {
+			gowsConfig.Workspaces[projectDir] = config.WorkspaceConfigModel{WorkspaceRootPath: legacyWsPath}
+			return nil
+		}))
+		require.NoError(t, initWorkspaceForProjectPath(projectDir, false))
+		require.Equal(t, legacyWsPath, workspaceOf(projectDir))
+
+		require.NoError(t, config.UpdateGOWSConfig(func(gowsConfig *config.GOWSConfigModel) error {
+			gowsConfig.Workspaces[otherProjectDir] = config.WorkspaceConfigModel{WorkspaceRootPath: legacyWsPath}
+			return nil
+		}))
+		require.NoError(t, initWorkspaceForProjectPath(otherProjectDir, false))
+		require.Equal(t, legacyWsPath, workspaceOf(projectDir))
+		require.NotEqual(t, legacyWsPath, workspaceOf(otherProjectDir))
+		// the project's earlier workspace directory exists, but it's no longer registered - it's not reused
+		require.Regexp(t, `/api-[0-9a-f]{12}-2$`, workspaceOf(otherProjectDir))
+	}
+}
+
+func Test_autoScanPackageNameOfDir(t *testing.T) {
+	projectDir, err := ioutil.TempDir("", "gows-fork-")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(projectDir))
+	}()
+
+	runGitInDir(t, projectDir, "init", "--quiet")
+	runGitInDir(t, projectDir, "remote", "add", "origin", "git@github.com:ourteam/x.git")
+	runGitInDir(t, projectDir, "remote", "add", "upstream", "https://github.com/upstream/x.git")
+
+	packageName, err := autoScanPackageNameOfDir(projectDir, "origin")
+	require.NoError(t, err)
+	require.Equal(t, "github.com/ourteam/x", packageName)
+
+	upstreamPackageName, err := autoScanPackageNameOfDir(projectDir, "upstream")
+	require.NoError(t, err)
+	require.Equal(t, "github.com/upstream/x", upstreamPackageName)
+
+	_, err = autoScanPackageNameOfDir(projectDir, "missing")
+	require.Error(t, err)
+}
+
+func Test_initGOWS_KeepsProjectConfig(t *testing.T) {
+	homeDir, err := ioutil.TempDir("", "gows-init-home-")
+	require.NoError(t, err)
+	homeDir, err = filepath.EvalSymlinks(homeDir)
+	require.NoError(t, err)
+	origHome := os.Getenv("HOME")
+	require.NoError(t, os.Setenv("HOME", homeDir))
+	defer func() {
+		require.NoError(t, os.Setenv("HOME", origHome))
+		require.NoError(t, os.RemoveAll(homeDir))
+	}()
+
+	projectDir := filepath.Join(homeDir, "dev", "x")
+	require.NoError(t, os.MkdirAll(projectDir, 0755))
+	projectConfig := config.ProjectConfigModel{
+		PackageName: "github.com/upstream/x",
+		Aliases:     []string{"gopkg.in/x.v1"},
+		SyncExclude: []string{"build/"},
+		Env:         map[string]string{"CGO_ENABLED": "0"},
+		EnvFiles:    []string{".env"},
+		Hermetic:    &config.HermeticConfigModel{EnvAllowlist: []string{"SSH_AUTH_SOCK"}},
+		Scripts:     map[string]config.ScriptConfigModel{"test": {Command: "go", Args: []string{"test", "./..."}}},
+		Hooks:       config.HooksConfigModel{PreCommand: []string{"echo pre"}},
+	}
+	require.NoError(t, config.SaveProjectConfigToDir(projectDir, projectConfig))
+
+	t.Log("init --fork - only the package name and the aliases change")
+	{
+		require.NoError(t, initGOWS(projectDir, "github.com/ourteam/x", []string{"github.com/upstream/x", "gopkg.in/x.v1"}, false))
+
+		savedProjectConfig, err := config.LoadProjectConfigFromDir(projectDir)
+		require.NoError(t, err)
+		expectedProjectConfig := projectConfig
+		expectedProjectConfig.PackageName = "github.com/ourteam/x"
+		expectedProjectConfig.Aliases = []string{"gopkg.in/x.v1", "github.com/upstream/x"}
+		require.Equal(t, expectedProjectConfig, savedProjectConfig)
+	}
+
+	t.Log("init --reset - the project config is kept")
+	{
+		require.NoError(t, initGOWS(projectDir, "github.com/ourteam/x", []string{}, true))
+
+		savedProjectConfig, err := config.LoadProjectConfigFromDir(projectDir)
+		require.NoError(t, err)
+		require.Equal(t, projectConfig.Scripts, savedProjectConfig.Scripts)
+		require.Equal(t, projectConfig.Hooks, savedProjectConfig.Hooks)
+		require.Equal(t, projectConfig.Env, savedProjectConfig.Env)
+		require.Equal(t, 2, len(savedProjectConfig.Aliases))
+	}
+}
+
+func Test_projectDirForInit(t *testing.T) {
+	origWorkDir, err := os.Getwd()
+	require.NoError(t, err)
+	tmpDir, err := ioutil.TempDir("", "gows-init-dir-")
+	require.NoError(t, err)
+	tmpDir, err = filepath.EvalSymlinks(tmpDir)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.Chdir(origWorkDir))
+		require.NoError(t, os.RemoveAll(tmpDir))
+	}()
+
+	projectDir := filepath.Join(tmpDir, "project")
+	subDir := filepath.Join(projectDir, "pkg", "foo")
+	require.NoError(t, os.MkdirAll(subDir, 0755))
+
+	t.Log("Not initialized yet - the working directory")
+	{
+		require.NoError(t, os.Chdir(subDir))
+		dir, err := projectDirForInit(false)
+		require.NoError(t, err)
+		require.Equal(t, subDir, dir)
+	}
+
+	require.NoError(t, config.SaveProjectConfigToDir(projectDir, config.ProjectConfigModel{PackageName: "github.com/org/project"}))
+
+	t.Log("Inside an initialized project - refused, except with reset")
+	{
+		_, err := projectDirForInit(false)
+		require.EqualError(t, err, "The working directory is inside an initialized project ("+projectDir+") - run gows init in the project's root directory, or use --reset to reset the project's workspace")
+
+		dir, err := projectDirForInit(true)
+		require.NoError(t, err)
+		require.Equal(t, projectDir, dir)
+	}
+
+	t.Log("In the project's root directory")
+	{
+		require.NoError(t, os.Chdir(projectDir))
+		dir, err := projectDirForInit(false)
+		require.NoError(t, err)
+		require.Equal(t, projectDir, dir)
+	}
+}
@@ -0,0 +1,324 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/colorstring"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-io/gows/config"
+	"github.com/bitrise-io/gows/gows"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/viktorbenei/cobra.v0"
+)
+
+// linkCmd represents the link command
+var linkCmd = &cobra.Command{
+	Use:   "link [path] [import-path]",
+	Short: "Link a local directory into the workspace",
+	Long: `Link a local directory (e.g. the checkout of a library you develop side by side
+with the project) into the workspace, at GOPATH/src/<import-path>, instead of
+the package fetched with go get.
+
+If import-path is not specified it's determined from the directory's gows.yml,
+or from its git remote (origin).
+In symlink and mount Sync Mode the directory is symlinked into the workspace,
+in copy Sync Mode it's synced into the workspace before every command
+(the changes made inside the workspace are not synced back into the directory).
+
+The links are stored in .gows.user.yml, and are re-applied by every gows command.
+Without arguments the links of the project are listed. Remove a link with gows unlink.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 2 {
+			return errors.New("Too many arguments specified")
+		}
+		projectDir, _, err := currentProjectDir()
+		if err != nil {
+			return err
+		}
+		userConfig, err := loadStoredUserConfig(projectDir)
+		if err != nil {
+			return err
+		}
+
+		if len(args) == 0 {
+			if len(userConfig.Links) == 0 {
+				log.Info("No links")
+				return nil
+			}
+			for _, link := range userConfig.Links {
+				fmt.Printf(" * %s -> %s\n", link.ImportPath, link.Path)
+			}
+			return nil
+		}
+
+		linkPath, err := pathutil.AbsPath(args[0])
+		if err != nil {
+			return fmt.Errorf("Failed to get absolute path of (%s), error: %s", args[0], err)
+		}
+		if isDir, err := pathutil.IsDirExists(linkPath); err != nil {
+			return fmt.Errorf("Failed to check (%s), error: %s", linkPath, err)
+		} else if !isDir {
+			return fmt.Errorf("Directory not found: %s", linkPath)
+		}
+
+		importPath := ""
+		if len(args) > 1 {
+			importPath = args[1]
+		} else {
+			importPath, err = detectLinkImportPath(linkPath)
+			if err != nil {
+				return err
+			}
+			log.Infof(" Detected import path: %s", importPath)
+		}
+
+		projectConfig, err := config.LoadProjectConfigFromDir(projectDir)
+		if err != nil {
+			return fmt.Errorf("Failed to read Project Config: %s", err)
+		}
+		if err := validateLinkImportPath(importPath, projectImportPaths(projectConfig)); err != nil {
+			return err
+		}
+
+		link := config.LinkConfigModel{ImportPath: importPath, Path: linkPath}
+		userConfig.Links = addWorkspaceLink(userConfig.Links, link)
+		if err := config.SaveUserConfigToDir(projectDir, userConfig); err != nil {
+			return err
+		}
+
+		// apply the link right away, if the project has a workspace already
+		if err := applyLinkNow(projectDir, []string{"gows", "link"}, func(syncModeUserConfig config.UserConfigModel, srcDir string) error {
+			return applyWorkspaceLink(syncModeUserConfig, srcDir, link)
+		}); err != nil {
+			return err
+		}
+
+		log.Infof("Linked %s -> %s", colorstring.Green(link.ImportPath), link.Path)
+		return nil
+	},
+}
+
+// unlinkCmd represents the unlink command
+var unlinkCmd = &cobra.Command{
+	Use:   "unlink import-path|path",
+	Short: "Remove a link created by gows link",
+	Long: `Remove a link created by gows link, specified by its import path or by the linked directory.
+
+The link (or in copy Sync Mode the synced copy of the directory) is removed from the workspace,
+the linked directory itself is not changed.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("Specify the import path or the path of the link to remove")
+		}
+		projectDir, _, err := currentProjectDir()
+		if err != nil {
+			return err
+		}
+		userConfig, err := loadStoredUserConfig(projectDir)
+		if err != nil {
+			return err
+		}
+
+		linkPath, err := pathutil.AbsPath(args[0])
+		if err != nil {
+			return fmt.Errorf("Failed to get absolute path of (%s), error: %s", args[0], err)
+		}
+		links, link, isFound := removeWorkspaceLink(userConfig.Links, args[0], linkPath)
+		if !isFound {
+			return fmt.Errorf("No link found for: %s", args[0])
+		}
+		userConfig.Links = links
+		if err := config.SaveUserConfigToDir(projectDir, userConfig); err != nil {
+			return err
+		}
+
+		if err := applyLinkNow(projectDir, []string{"gows", "unlink"}, func(_ config.UserConfigModel, srcDir string) error {
+			return removeWorkspaceLinkDir(srcDir, link)
+		}); err != nil {
+			return err
+		}
+
+		log.Infof("Unlinked %s", colorstring.Green(link.ImportPath))
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(linkCmd)
+	RootCmd.AddCommand(unlinkCmd)
+}
+
+// loadStoredUserConfig loads the project's .gows.user.yml as it is stored
+// (without the settings specified through environment variables), to update it
+func loadStoredUserConfig(projectDir string) (config.UserConfigModel, error) {
+	isExists, err := pathutil.IsPathExists(filepath.Join(projectDir, config.UserConfigFileName))
+	if err != nil {
+		return config.UserConfigModel{}, fmt.Errorf("Failed to check the user config, error: %s", err)
+	}
+	if !isExists {
+		return config.CreateDefaultUserConfig(), nil
+	}
+	return config.LoadUserConfigFromDir(projectDir)
+}
+
+// applyLinkNow applies a change of the links in the project's workspace (if it exists),
+// while holding the workspace's run lock
+func applyLinkNow(projectDir string, lockCommand []string, apply func(userConfig config.UserConfigModel, srcDir string) error) error {
+	gowsConfig, err := config.LoadGOWSConfigFromFile()
+	if err != nil {
+		return fmt.Errorf("Failed to load gows config: %s", err)
+	}
+	wsConfig, isFound := gowsConfig.WorkspaceForProjectLocation(projectDir)
+	if !isFound || wsConfig.WorkspaceRootPath == "" {
+		// applied when the workspace is created
+		return nil
+	}
+
+	// the workspace can't be changed while it's used by the running commands
+	userConfig := loadUserConfig(projectDir)
+	if userConfig.RunLock == "" || userConfig.RunLock == config.RunLockShared {
+		userConfig.RunLock = config.RunLockWait
+	}
+	runLock, err := acquireRunLock(userConfig, wsConfig.WorkspaceRootPath, projectDir, lockCommand)
+	if err != nil {
+		return err
+	}
+	defer runLock.release()
+
+	return apply(userConfig, filepath.Join(wsConfig.WorkspaceRootPath, "src"))
+}
+
+// detectLinkImportPath determines the import path of the directory from its gows.yml or from its git remote
+func detectLinkImportPath(dir string) (string, error) {
+	if projectConfig, err := config.LoadProjectConfigFromDir(dir); err == nil && projectConfig.PackageName != "" {
+		return projectConfig.PackageName, nil
+	}
+	importPath, err := autoScanPackageNameOfDir(dir, "origin")
+	if err != nil {
+		return "", fmt.Errorf("Failed to determine the import path of (%s) - specify it as the second argument, error: %s", dir, err)
+	}
+	return importPath, nil
+}
+
+// validateLinkImportPath checks that the link stays inside the workspace's src directory,
+// and that it does not replace (a part of) the project itself, at any of its import paths
+func validateLinkImportPath(importPath string, projectImportPaths []string) error {
+	if err := validateDependencyImportPath(importPath); err != nil {
+		return err
+	}
+	for _, projectImportPath := range projectImportPaths {
+		if isImportPathOverlapping(importPath, projectImportPath) {
+			return fmt.Errorf("The import path (%s) overlaps with the project's package (%s)", importPath, projectImportPath)
+		}
+	}
+	return nil
+}
+
+// isImportPathOverlapping returns true if the import paths are the same, or one is inside the other
+func isImportPathOverlapping(importPath, otherImportPath string) bool {
+	return importPath == otherImportPath || strings.HasPrefix(importPath, otherImportPath+"/") || strings.HasPrefix(otherImportPath, importPath+"/")
+}
+
+// addWorkspaceLink adds the link (replacing the link with the same import path), sorted by import path
+func addWorkspaceLink(links []config.LinkConfigModel, link config.LinkConfigModel) []config.LinkConfigModel {
+	newLinks := []config.LinkConfigModel{link}
+	for _, aLink := range links {
+		if aLink.ImportPath != link.ImportPath {
+			newLinks = append(newLinks, aLink)
+		}
+	}
+	sort.Slice(newLinks, func(i, j int) bool {
+		return newLinks[i].ImportPath < newLinks[j].ImportPath
+	})
+	return newLinks
+}
+
+// removeWorkspaceLink removes the link with the import path, or with the linked directory's path,
+// and returns the removed link
+func removeWorkspaceLink(links []config.LinkConfigModel, importPath, linkPath string) ([]config.LinkConfigModel, config.LinkConfigModel, bool) {
+	newLinks := []config.LinkConfigModel{}
+	removedLink := config.LinkConfigModel{}
+	isFound := false
+	for _, link := range links {
+		if !isFound && (link.ImportPath == importPath || link.Path == linkPath) {
+			removedLink = link
+			isFound = true
+			continue
+		}
+		newLinks = append(newLinks, link)
+	}
+	return newLinks, removedLink, isFound
+}
+
+// applyWorkspaceLinks links (or in copy Sync Mode syncs) the linked directories into the workspace's src directory.
+// A link of a directory which does not exist anymore is skipped, with a warning.
+func applyWorkspaceLinks(userConfig config.UserConfigModel, srcDir string) error {
+	for _, link := range userConfig.Links {
+		if isDir, err := pathutil.IsDirExists(link.Path); err != nil || !isDir {
+			log.Warningf(" [!] The linked directory of %s (%s) is not found, run %s to remove the link", link.ImportPath, link.Path, colorstring.Green("gows unlink "+link.ImportPath))
+			continue
+		}
+		if err := applyWorkspaceLink(userConfig, srcDir, link); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyWorkspaceLink(userConfig config.UserConfigModel, srcDir string, link config.LinkConfigModel) error {
+	if err := validateDependencyImportPath(link.ImportPath); err != nil {
+		return err
+	}
+	linkLocationPath := filepath.Join(srcDir, filepath.FromSlash(link.ImportPath))
+	fileInfo, isExists, err := pathutil.PathCheckAndInfos(linkLocationPath)
+	if err != nil {
+		return fmt.Errorf("Failed to check (%s), error: %s", linkLocationPath, err)
+	}
+	isSymlink := isExists && fileInfo.Mode()&os.ModeSymlink != 0
+
+	if userConfig.SyncMode == config.SyncModeCopy {
+		if isSymlink {
+			if err := os.Remove(linkLocationPath); err != nil {
+				return fmt.Errorf("Failed to remove Symlink (at: %s), error: %s", linkLocationPath, err)
+			}
+		}
+		log.Debugf("=> Sync linked directory into workspace: (%s) -> (%s)", link.Path, linkLocationPath)
+		if err := syncDirWithDir(userConfig, nil, link.Path, linkLocationPath); err != nil {
+			return fmt.Errorf("Failed to sync the linked directory of %s into the workspace, error: %s", link.ImportPath, err)
+		}
+		return nil
+	}
+
+	if isExists && !isSymlink {
+		// e.g. the package fetched with go get
+		log.Warningf("Directory exists (at: %s), replacing it with the link of %s", linkLocationPath, link.Path)
+		if err := os.RemoveAll(linkLocationPath); err != nil {
+			return fmt.Errorf("Failed to remove Directory (at: %s), error: %s", linkLocationPath, err)
+		}
+	}
+	if err := gows.CreateOrUpdateSymlink(link.Path, linkLocationPath); err != nil {
+		return fmt.Errorf("Failed to link %s into the workspace, error: %s", link.ImportPath, err)
+	}
+	return nil
+}
+
+// removeWorkspaceLinkDir removes the link (or the synced copy) of the linked directory from the workspace's src directory
+func removeWorkspaceLinkDir(srcDir string, link config.LinkConfigModel) error {
+	if err := validateDependencyImportPath(link.ImportPath); err != nil {
+		return err
+	}
+	linkLocationPath := filepath.Join(srcDir, filepath.FromSlash(link.ImportPath))
+	if err := os.RemoveAll(linkLocationPath); err != nil {
+		return fmt.Errorf("Failed to remove (%s), error: %s", linkLocationPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/gows/config"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_workspaceLinks(t *testing.T) {
+	t.Log("Add - replaces the link of the same import path")
+	{
+		links := addWorkspaceLink([]config.LinkConfigModel{}, config.LinkConfigModel{ImportPath: "github.com/org/b", Path: "/dev/b"})
+		links = addWorkspaceLink(links, config.LinkConfigModel{ImportPath: "github.com/org/a", Path: "/dev/a"})
+		links = addWorkspaceLink(links, config.LinkConfigModel{ImportPath: "github.com/org/b", Path: "/dev/b2"})
+		require.Equal(t, []config.LinkConfigModel{
+			{ImportPath: "github.com/org/a", Path: "/dev/a"},
+			{ImportPath: "github.com/org/b", Path: "/dev/b2"},
+		}, links)
+	}
+
+	t.Log("Remove - by import path or by path")
+	{
+		links := []config.LinkConfigModel{
+			{ImportPath: "github.com/org/a", Path: "/dev/a"},
+			{ImportPath: "github.com/org/b", Path: "/dev/b"},
+		}
+		newLinks, link, isFound := removeWorkspaceLink(links, "github.com/org/a", "/cwd/github.com/org/a")
+		require.Equal(t, true, isFound)
+		require.Equal(t, "/dev/a", link.Path)
+		require.Equal(t, []config.LinkConfigModel{{ImportPath: "github.com/org/b", Path: "/dev/b"}}, newLinks)
+
+		newLinks, link, isFound = removeWorkspaceLink(links, "../b", "/dev/b")
+		require.Equal(t, true, isFound)
+		require.Equal(t, "github.com/org/b", link.ImportPath)
+		require.Equal(t, 1, len(newLinks))
+
+		_, _, isFound = removeWorkspaceLink(links, "github.com/org/c", "/cwd/github.com/org/c")
+		require.Equal(t, false, isFound)
+	}
+
+	t.Log("Validate")
+	{
+		require.NoError(t, validateLinkImportPath("github.com/org/lib", []string{"github.com/org/project"}))
+		require.NoError(t, validateLinkImportPath("github.com/org/project-lib", []string{"github.com/org/project"}))
+		require.EqualError(t, validateLinkImportPath("github.com/org/project/sub", []string{"github.com/org/project"}), "The import path (github.com/org/project/sub) overlaps with the project's package (github.com/org/project)")
+		require.Error(t, validateLinkImportPath("github.com/org", []string{"github.com/org/project"}))
+		require.Error(t, validateLinkImportPath("../lib", []string{"github.com/org/project"}))
+		require.EqualError(t, validateLinkImportPath("github.com/upstream/project", []string{"github.com/org/project", "github.com/upstream/project"}), "The import path (github.com/upstream/project) overlaps with the project's package (github.com/upstream/project)")
+	}
+}
+
+func Test_applyWorkspaceLinks(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gows-link-")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(tmpDir))
+	}()
+
+	libDir := filepath.Join(tmpDir, "dev", "lib")
+	require.NoError(t, os.MkdirAll(libDir, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(libDir, "lib.go"), []byte("package lib"), 0644))
+	require.NoError(t, config.SaveProjectConfigToDir(libDir, config.ProjectConfigModel{PackageName: "github.com/org/lib"}))
+
+	importPath, err := detectLinkImportPath(libDir)
+	require.NoError(t, err)
+	require.Equal(t, "github.com/org/lib", importPath)
+
+	srcDir := filepath.Join(tmpDir, "ws", "src")
+	linkLocationPath := filepath.Join(srcDir, "github.com", "org", "lib")
+	// the package fetched with go get
+	require.NoError(t, os.MkdirAll(linkLocationPath, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(linkLocationPath, "fetched.go"), []byte("package lib"), 0644))
+
+	userConfig := config.UserConfigModel{
+		SyncMode: config.SyncModeSymlink,
+		Links: []config.LinkConfigModel{
+			{ImportPath: importPath, Path: libDir},
+			{ImportPath: "github.com/org/removed", Path: filepath.Join(tmpDir, "dev", "removed")},
+		},
+	}
+
+	t.Log("Symlink mode - the fetched package is replaced, the missing directory is skipped")
+	{
+		require.NoError(t, applyWorkspaceLinks(userConfig, srcDir))
+		target, err := os.Readlink(linkLocationPath)
+		require.NoError(t, err)
+		require.Equal(t, libDir, target)
+
+		isExists, err := pathExists(filepath.Join(srcDir, "github.com", "org", "removed"))
+		require.NoError(t, err)
+		require.Equal(t, false, isExists)
+	}
+
+	t.Log("Copy mode - the directory is synced, instead of the symlink")
+	{
+		userConfig.SyncMode = config.SyncModeCopy
+		require.NoError(t, applyWorkspaceLinks(userConfig, srcDir))
+		fileInfo, err := os.Lstat(linkLocationPath)
+		require.NoError(t, err)
+		require.Equal(t, true, fileInfo.IsDir())
+		content, err := ioutil.ReadFile(filepath.Join(linkLocationPath, "lib.go"))
+		require.NoError(t, err)
+		require.Equal(t, "package lib", string(content))
+	}
+
+	t.Log("Unlink")
+	{
+		require.NoError(t, removeWorkspaceLinkDir(srcDir, userConfig.Links[0]))
+		isExists, err := pathExists(linkLocationPath)
+		require.NoError(t, err)
+		require.Equal(t, false, isExists)
+		// the linked directory is kept
+		isExists, err = pathExists(filepath.Join(libDir, "lib.go"))
+		require.NoError(t, err)
+		require.Equal(t, true, isExists)
+	}
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/bitrise-io/gows/gows"
+	"gopkg.in/viktorbenei/cobra.v0"
+)
+
+const mountNamespaceExecCmdName = "__mount-namespace-exec"
+
+// mountNamespaceExecCmd is the helper command of the mount sync mode,
+// it's called by gows inside the created user & mount namespace
+var mountNamespaceExecCmd = &cobra.Command{
+	Use:                mountNamespaceExecCmdName + " PROJECT_DIR MOUNT_POINT WORK_DIR COMMAND [ARGS...]",
+	Short:              "Internal - runs the command inside the mount namespace of the mount sync mode",
+	Hidden:             true,
+	DisableFlagParsing: true,
+	SilenceUsage:       true,
+	SilenceErrors:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 4 {
+			return errors.New("Invalid arguments, usage: gows " + cmd.Use)
+		}
+
+		err := gows.ExecInMountNamespace(args[0], args[1], args[2], args[3], args[4:]...)
+		// only returns if the command could not be started
+		fmt.Fprintf(os.Stderr, "gows: failed to run the command in the mount namespace: %s\n", err)
+		os.Exit(1)
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(mountNamespaceExecCmd)
+}
+
+// mountNamespaceHelperArgs returns the gows arguments which run the command inside the mount namespace
+func mountNamespaceHelperArgs(projectDir, mountPointPth, cmdWorkDir, cmdName string, cmdArgs ...string) []string {
+	return append([]string{mountNamespaceExecCmdName, projectDir, mountPointPth, cmdWorkDir, cmdName}, cmdArgs...)
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+package cmd
+
+import (
+	"syscall"
+)
+
+// isProcessRunning returns true if a process with the given PID is running
+func isProcessRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	// signal 0 only checks whether the process exists,
+	// EPERM means it exists, but it's owned by someone else
+	err := syscall.Kill(pid, syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,35 @@
+//go:build windows
+// +build windows
+
+package cmd
+
+import (
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/windows"
+)
+
+// stillActiveExitCode - the exit code (STILL_ACTIVE) of a process which is still running
+const stillActiveExitCode = 259
+
+// isProcessRunning returns true if a process with the given PID is running
+func isProcessRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	handle, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		// the process exists, but it's owned by someone else
+		return err == windows.ERROR_ACCESS_DENIED
+	}
+	defer func() {
+		if err := windows.CloseHandle(handle); err != nil {
+			log.Debugf("[isProcessRunning] Failed to close process handle, error: %s", err)
+		}
+	}()
+
+	var exitCode uint32
+	if err := windows.GetExitCodeProcess(handle, &exitCode); err != nil {
+		return false
+	}
+	return exitCode == stillActiveExitCode
+}
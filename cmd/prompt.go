@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// isInteractive returns true if the input is a terminal,
+// so that the user can be asked
+func isInteractive() bool {
+	fileInfo, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return fileInfo.Mode()&os.ModeCharDevice != 0
+}
+
+// askForOption asks the user to choose one of the options,
+// until a valid option is chosen
+func askForOption(question string, options []string) (string, error) {
+	if !isInteractive() {
+		return "", errors.New("Can't ask for an option, the input is not interactive")
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Printf("%s [%s]: ", question, strings.Join(options, "/"))
+		answer, err := reader.ReadString('\n')
+		if err != nil {
+			return "", fmt.Errorf("Failed to read the answer, error: %s", err)
+		}
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		for _, option := range options {
+			if answer == option {
+				return option, nil
+			}
+		}
+		fmt.Printf("Invalid option: %s\n", answer)
+	}
+}
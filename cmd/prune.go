@@ -0,0 +1,382 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/colorstring"
+	"github.com/bitrise-io/gows/config"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/viktorbenei/cobra.v0"
+)
+
+const (
+	pruneReasonProjectNotFound = "project not found"
+	pruneReasonNotRegistered   = "not registered"
+	pruneReasonUnused          = "unused"
+)
+
+var (
+	pruneOlderThan = ""
+	isPruneDryRun  = false
+	isPruneYes     = false
+)
+
+// pruneCmd represents the prune command
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove orphaned and stale workspaces",
+	Long: `Remove orphaned and stale workspaces:
+
+- registered workspaces of projects which no longer exist
+- workspace directories (in ~/.bitrise-gows/wsdirs) not registered for any project
+- with --older-than: workspaces not used for longer than the specified duration
+  (e.g. 720h or 30d)
+
+The workspaces to remove are listed (with their size), and removed after confirmation
+(or without asking, with --yes). Workspaces in use by a gows command, and workspaces
+with an interrupted copy mode session (see: gows recover) are never removed.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		olderThan := time.Duration(0)
+		if pruneOlderThan != "" {
+			var err error
+			olderThan, err = parseAge(pruneOlderThan)
+			if err != nil {
+				return err
+			}
+		}
+
+		gowsWorspacesRootDirAbsPath, err := config.GOWSWorspacesRootDirAbsPath()
+		if err != nil {
+			return fmt.Errorf("Failed to get absolute path for gows workspaces root dir, error: %s", err)
+		}
+		// the workspace directories are listed while the gows config is locked,
+		// so that a workspace just being created (and registered) by another gows process is not listed
+		candidates := []pruneCandidate{}
+		err = config.ViewGOWSConfig(func(gowsConfig config.GOWSConfigModel) error {
+			var err error
+			candidates, err = findPruneCandidates(gowsConfig, gowsWorspacesRootDirAbsPath, olderThan, time.Now())
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if len(candidates) == 0 {
+			log.Info("Nothing to prune")
+			return nil
+		}
+
+		fmt.Println(formatPruneCandidates(candidates))
+
+		if isPruneDryRun {
+			log.Info("Dry run, nothing was removed")
+			return nil
+		}
+		if !isPruneYes {
+			if !isInteractive() {
+				return errors.New("The input is not interactive, use --yes to remove the workspaces without confirmation")
+			}
+			answer, err := askForOption("Remove the workspaces?", []string{"y", "n"})
+			if err != nil {
+				return err
+			}
+			if answer != "y" {
+				log.Info("Nothing was removed")
+				return nil
+			}
+		}
+
+		removedSize := int64(0)
+		removedCount := 0
+		err = config.UpdateGOWSConfig(func(gowsConfig *config.GOWSConfigModel) error {
+			for _, candidate := range candidates {
+				if err := pruneWorkspace(gowsConfig, gowsWorspacesRootDirAbsPath, candidate); err != nil {
+					log.Warningf(" [!] Skipping %s: %s", candidate.WorkspaceRootPath, err)
+					continue
+				}
+				removedSize += candidate.Size
+				removedCount++
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("Failed to save gows config: %s", err)
+		}
+
+		log.Infof("Removed %d workspace(s), %s", removedCount, formatSize(removedSize))
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(pruneCmd)
+	pruneCmd.Flags().StringVarP(&pruneOlderThan, "older-than", "", "", "Also remove the workspaces not used for longer than this (e.g. 720h or 30d)")
+	pruneCmd.Flags().BoolVarP(&isPruneDryRun, "dry-run", "", false, "Only list the workspaces to remove")
+	pruneCmd.Flags().BoolVarP(&isPruneYes, "yes", "y", false, "Remove the workspaces without confirmation")
+}
+
+// pruneCandidate is a workspace (and/or its registry entry) to remove
+type pruneCandidate struct {
+	// ProjectPath - the project the workspace is registered for, empty if it's not registered
+	ProjectPath       string
+	WorkspaceRootPath string
+	Reason            string
+	LastUsedAt        time.Time
+	Size              int64
+	// IsRemoveDir - false if only the registry entry should be removed
+	// (the workspace is outside of the workspaces root dir, or it's used by another project)
+	IsRemoveDir bool
+}
+
+// findPruneCandidates returns the registry entries of the projects which no longer exist,
+// the workspace directories not registered for any project, and (if olderThan is not 0)
+// the workspaces not used since now-olderThan
+func findPruneCandidates(gowsConfig config.GOWSConfigModel, workspacesRootDir string, olderThan time.Duration, now time.Time) ([]pruneCandidate, error) {
+	journals, err := config.LoadAllCopySessionJournals()
+	if err != nil {
+		return []pruneCandidate{}, fmt.Errorf("Failed to load the copy session journals, error: %s", err)
+	}
+	isInterruptedSession := map[string]bool{}
+	for _, journal := range journals {
+		isInterruptedSession[filepath.Clean(journal.WorkspaceRootPath)] = true
+	}
+
+	candidates := []pruneCandidate{}
+	isCandidateProject := map[string]bool{}
+	addCandidate := func(candidate pruneCandidate) {
+		if isInterruptedSession[filepath.Clean(candidate.WorkspaceRootPath)] {
+			log.Warningf("Workspace (%s) has an interrupted copy mode session, run %s in the project first - skipping it", candidate.WorkspaceRootPath, colorstring.Green("gows recover"))
+			return
+		}
+		if candidate.ProjectPath != "" {
+			isCandidateProject[candidate.ProjectPath] = true
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	projectPaths := []string{}
+	for projectPath := range gowsConfig.Workspaces {
+		projectPaths = append(projectPaths, projectPath)
+	}
+	sort.Strings(projectPaths)
+
+	for _, projectPath := range projectPaths {
+		wsRootPath := gowsConfig.Workspaces[projectPath].WorkspaceRootPath
+		lastUsedAt, err := workspaceLastUsedAt(gowsConfig.Workspaces[projectPath])
+		if err != nil {
+			return []pruneCandidate{}, err
+		}
+
+		if _, err := os.Stat(projectPath); os.IsNotExist(err) {
+			addCandidate(pruneCandidate{
+				ProjectPath:       projectPath,
+				WorkspaceRootPath: wsRootPath,
+				Reason:            pruneReasonProjectNotFound,
+				LastUsedAt:        lastUsedAt,
+			})
+		} else if err != nil {
+			return []pruneCandidate{}, fmt.Errorf("Failed to check project (%s), error: %s", projectPath, err)
+		} else if olderThan > 0 && !lastUsedAt.IsZero() && now.Sub(lastUsedAt) > olderThan {
+			addCandidate(pruneCandidate{
+				ProjectPath:       projectPath,
+				WorkspaceRootPath: wsRootPath,
+				Reason:            pruneReasonUnused,
+				LastUsedAt:        lastUsedAt,
+			})
+		}
+	}
+
+	// the workspace directory is only removed if every project it's registered for is pruned
+	for idx, candidate := range candidates {
+		isUsedByOthers := false
+		for _, projectPath := range gowsConfig.ProjectsOfWorkspace(candidate.WorkspaceRootPath) {
+			if !isCandidateProject[projectPath] {
+				isUsedByOthers = true
+			}
+		}
+		candidates[idx].IsRemoveDir = !isUsedByOthers && isPathInDir(candidate.WorkspaceRootPath, workspacesRootDir)
+	}
+
+	fileInfos, err := ioutil.ReadDir(workspacesRootDir)
+	if err != nil && !os.IsNotExist(err) {
+		return []pruneCandidate{}, fmt.Errorf("Failed to list the workspaces (%s), error: %s", workspacesRootDir, err)
+	}
+	for _, fileInfo := range fileInfos {
+		if !fileInfo.IsDir() {
+			// not a workspace
+			continue
+		}
+		wsRootPath := filepath.Join(workspacesRootDir, fileInfo.Name())
+		if len(gowsConfig.ProjectsOfWorkspace(wsRootPath)) > 0 {
+			continue
+		}
+		addCandidate(pruneCandidate{
+			WorkspaceRootPath: wsRootPath,
+			Reason:            pruneReasonNotRegistered,
+			LastUsedAt:        fileInfo.ModTime(),
+			IsRemoveDir:       true,
+		})
+	}
+
+	for idx, candidate := range candidates {
+		if !candidate.IsRemoveDir {
+			continue
+		}
+		size, err := dirSize(candidate.WorkspaceRootPath)
+		if err != nil {
+			return []pruneCandidate{}, err
+		}
+		candidates[idx].Size = size
+	}
+
+	return candidates, nil
+}
+
+// pruneWorkspace removes the candidate's registry entry and workspace directory,
+// unless the workspace is in use, or it changed since the candidates were collected
+func pruneWorkspace(gowsConfig *config.GOWSConfigModel, workspacesRootDir string, candidate pruneCandidate) error {
+	if candidate.ProjectPath != "" {
+		wsConfig, isFound := gowsConfig.Workspaces[candidate.ProjectPath]
+		if !isFound || filepath.Clean(wsConfig.WorkspaceRootPath) != filepath.Clean(candidate.WorkspaceRootPath) {
+			return errors.New("the registry entry changed")
+		}
+	} else if len(gowsConfig.ProjectsOfWorkspace(candidate.WorkspaceRootPath)) > 0 {
+		// e.g. while the user was asked for confirmation
+		return errors.New("the workspace was registered in the meantime")
+	}
+
+	runLockFileAbsPath, err := config.RunLockFileAbsPath(candidate.WorkspaceRootPath)
+	if err != nil {
+		return err
+	}
+	lock, isLocked, err := config.TryLockFile(runLockFileAbsPath, false)
+	if err != nil {
+		return err
+	}
+	if !isLocked {
+		return errors.New("the workspace is in use by a gows command")
+	}
+	defer func() {
+		if err := lock.Unlock(); err != nil {
+			log.Warningf(" [!] %s", err)
+		}
+	}()
+
+	if candidate.ProjectPath != "" {
+		delete(gowsConfig.Workspaces, candidate.ProjectPath)
+	}
+	if candidate.IsRemoveDir && isPathInDir(candidate.WorkspaceRootPath, workspacesRootDir) {
+		if err := os.RemoveAll(candidate.WorkspaceRootPath); err != nil {
+			return fmt.Errorf("Failed to remove workspace (%s), error: %s", candidate.WorkspaceRootPath, err)
+		}
+	}
+	return nil
+}
+
+// formatPruneCandidates lists the candidates, with their size and the reason to remove them
+func formatPruneCandidates(candidates []pruneCandidate) string {
+	lines := []string{"Workspaces to remove:"}
+	totalSize := int64(0)
+	for _, candidate := range candidates {
+		details := ""
+		switch candidate.Reason {
+		case pruneReasonProjectNotFound:
+			details = fmt.Sprintf("project not found: %s", candidate.ProjectPath)
+		case pruneReasonNotRegistered:
+			details = "not registered for any project"
+		case pruneReasonUnused:
+			details = fmt.Sprintf("not used since %s, project: %s", candidate.LastUsedAt.Format("2006-01-02"), candidate.ProjectPath)
+		}
+
+		if candidate.IsRemoveDir {
+			lines = append(lines, fmt.Sprintf(" * %s (%s) - %s", candidate.WorkspaceRootPath, formatSize(candidate.Size), details))
+			totalSize += candidate.Size
+		} else {
+			lines = append(lines, fmt.Sprintf(" * %s (registry entry only) - %s", candidate.WorkspaceRootPath, details))
+		}
+	}
+	lines = append(lines, fmt.Sprintf("Total: %s", formatSize(totalSize)))
+	return strings.Join(lines, "\n")
+}
+
+// workspaceLastUsedAt returns the time the workspace was last used: the last use recorded in the gows config,
+// or the modification time of the workspace root directory (updated by gows env, and by earlier gows versions),
+// whichever is later. Returns zero time if none of these are available.
+func workspaceLastUsedAt(wsConfig config.WorkspaceConfigModel) (time.Time, error) {
+	lastUsedAt := wsConfig.LastUsedAt
+
+	fileInfo, err := os.Stat(wsConfig.WorkspaceRootPath)
+	if err != nil && !os.IsNotExist(err) {
+		return time.Time{}, fmt.Errorf("Failed to check workspace (%s), error: %s", wsConfig.WorkspaceRootPath, err)
+	} else if err == nil && fileInfo.ModTime().After(lastUsedAt) {
+		lastUsedAt = fileInfo.ModTime()
+	}
+	return lastUsedAt, nil
+}
+
+// dirSize returns the total size of the files in the directory (symlinks are not followed),
+// or 0 if the directory does not exist
+func dirSize(pth string) (int64, error) {
+	size := int64(0)
+	err := filepath.Walk(pth, func(walkPth string, fileInfo os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if fileInfo.Mode().IsRegular() {
+			size += fileInfo.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("Failed to calculate the size of (%s), error: %s", pth, err)
+	}
+	return size, nil
+}
+
+// formatSize formats the size (in bytes) in a human readable form
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// parseAge parses a duration, in Go's duration format (e.g. 720h), or in days (e.g. 30d)
+func parseAge(age string) (time.Duration, error) {
+	if strings.HasSuffix(age, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(age, "d"))
+		if err == nil && days > 0 {
+			return time.Duration(days) * 24 * time.Hour, nil
+		}
+	} else if duration, err := time.ParseDuration(age); err == nil && duration > 0 {
+		return duration, nil
+	}
+	return 0, fmt.Errorf("Invalid duration (%s), should be a positive duration like 720h or 30d", age)
+}
+
+// isPathInDir returns true if pth is inside dir (not dir itself)
+func isPathInDir(pth, dir string) bool {
+	relPth, err := filepath.Rel(dir, pth)
+	if err != nil {
+		return false
+	}
+	return relPth != "." && relPth != ".." && !strings.HasPrefix(relPth, ".."+string(filepath.Separator)) && !filepath.IsAbs(relPth)
+}
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/gows/config"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_findPruneCandidates(t *testing.T) {
+	homeDir, err := ioutil.TempDir("", "gows-prune-home-")
+	require.NoError(t, err)
+	homeDir, err = filepath.EvalSymlinks(homeDir)
+	require.NoError(t, err)
+	origHome := os.Getenv("HOME")
+	require.NoError(t, os.Setenv("HOME", homeDir))
+	defer func() {
+		require.NoError(t, os.Setenv("HOME", origHome))
+		require.NoError(t, os.RemoveAll(homeDir))
+	}()
+
+	wsDirs := filepath.Join(homeDir, ".bitrise-gows", "wsdirs")
+	now := time.Now()
+	createWorkspace := func(name string, lastUsedAt time.Time) string {
+		wsRootPath := filepath.Join(wsDirs, name)
+		require.NoError(t, os.MkdirAll(filepath.Join(wsRootPath, "src"), 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(wsRootPath, "src", "file"), []byte("12345"), 0644))
+		require.NoError(t, os.Chtimes(wsRootPath, lastUsedAt, lastUsedAt))
+		return wsRootPath
+	}
+	createProject := func(name string) string {
+		projectPath := filepath.Join(homeDir, "projects", name)
+		require.NoError(t, os.MkdirAll(projectPath, 0755))
+		return projectPath
+	}
+
+	activeProject := createProject("active")
+	activeWs := createWorkspace("active-1", now)
+	oldProject := createProject("old")
+	oldWs := createWorkspace("old-1", now.Add(-40*24*time.Hour))
+	deletedProject := filepath.Join(homeDir, "projects", "deleted")
+	deletedWs := createWorkspace("deleted-1", now)
+	sharedWs := createWorkspace("shared-1", now)
+	sharedProject := createProject("shared")
+	deletedSharedProject := filepath.Join(homeDir, "projects", "deleted-shared")
+	orphanWs := createWorkspace("orphan-1", now)
+	interruptedWs := createWorkspace("interrupted-1", now)
+	// not a workspace
+	require.NoError(t, ioutil.WriteFile(filepath.Join(wsDirs, ".DS_Store"), []byte("stray"), 0644))
+
+	gowsConfig := config.GOWSConfigModel{
+		Workspaces: map[string]config.WorkspaceConfigModel{
+			activeProject:        {WorkspaceRootPath: activeWs},
+			oldProject:           {WorkspaceRootPath: oldWs},
+			deletedProject:       {WorkspaceRootPath: deletedWs},
+			sharedProject:        {WorkspaceRootPath: sharedWs},
+			deletedSharedProject: {WorkspaceRootPath: sharedWs},
+		},
+	}
+	require.NoError(t, config.SaveGOWSConfigToFile(gowsConfig))
+	require.NoError(t, config.SaveCopySessionJournal(config.CopySessionJournalModel{
+		ProjectPath:       filepath.Join(homeDir, "projects", "interrupted"),
+		WorkspaceRootPath: interruptedWs,
+	}))
+
+	t.Log("Missing projects and not registered workspaces")
+	{
+		candidates, err := findPruneCandidates(gowsConfig, wsDirs, 0, now)
+		require.NoError(t, err)
+		require.Equal(t, 3, len(candidates))
+
+		require.Equal(t, deletedProject, candidates[0].ProjectPath)
+		require.Equal(t, pruneReasonProjectNotFound, candidates[0].Reason)
+		require.Equal(t, true, candidates[0].IsRemoveDir)
+		require.Equal(t, int64(5), candidates[0].Size)
+
+		// the workspace is still used by another project
+		require.Equal(t, deletedSharedProject, candidates[1].ProjectPath)
+		require.Equal(t, false, candidates[1].IsRemoveDir)
+		require.Equal(t, int64(0), candidates[1].Size)
+
+		require.Equal(t, "", candidates[2].ProjectPath)
+		require.Equal(t, orphanWs, candidates[2].WorkspaceRootPath)
+		require.Equal(t, pruneReasonNotRegistered, candidates[2].Reason)
+	}
+
+	t.Log("Unused workspaces")
+	{
+		candidates, err := findPruneCandidates(gowsConfig, wsDirs, 30*24*time.Hour, now)
+		require.NoError(t, err)
+		require.Equal(t, 4, len(candidates))
+		require.Equal(t, oldProject, candidates[2].ProjectPath)
+		require.Equal(t, pruneReasonUnused, candidates[2].Reason)
+		require.Contains(t, formatPruneCandidates(candidates), "not used since "+now.Add(-40*24*time.Hour).Format("2006-01-02"))
+	}
+
+	t.Log("Prune")
+	{
+		candidates, err := findPruneCandidates(gowsConfig, wsDirs, 0, now)
+		require.NoError(t, err)
+
+		// the orphan workspace is in use
+		lockPth, err := config.RunLockFileAbsPath(orphanWs)
+		require.NoError(t, err)
+		lock, err := config.LockFile(lockPth)
+		require.NoError(t, err)
+
+		require.NoError(t, config.UpdateGOWSConfig(func(gowsConfig *config.GOWSConfigModel) error {
+			// registered since the candidates were collected
+			registeredConfig := *gowsConfig
+			registeredConfig.Workspaces = map[string]config.WorkspaceConfigModel{filepath.Join(homeDir, "projects", "new"): {WorkspaceRootPath: orphanWs}}
+			require.EqualError(t, pruneWorkspace(&registeredConfig, wsDirs, candidates[2]), "the workspace was registered in the meantime")
+
+			require.NoError(t, pruneWorkspace(gowsConfig, wsDirs, candidates[0]))
+			require.NoError(t, pruneWorkspace(gowsConfig, wsDirs, candidates[1]))
+			require.EqualError(t, pruneWorkspace(gowsConfig, wsDirs, candidates[2]), "the workspace is in use by a gows command")
+			return nil
+		}))
+		require.NoError(t, lock.Unlock())
+
+		gowsConfig, err := config.LoadGOWSConfigFromFile()
+		require.NoError(t, err)
+		require.Equal(t, 3, len(gowsConfig.Workspaces))
+		_, isFound := gowsConfig.Workspaces[deletedProject]
+		require.Equal(t, false, isFound)
+
+		for _, removedPth := range []string{deletedWs} {
+			_, err := os.Stat(removedPth)
+			require.True(t, os.IsNotExist(err), removedPth)
+		}
+		for _, keptPth := range []string{activeWs, oldWs, sharedWs, orphanWs, interruptedWs} {
+			require.DirExists(t, keptPth)
+		}
+	}
+}
+
+func Test_parseAge(t *testing.T) {
+	age, err := parseAge("30d")
+	require.NoError(t, err)
+	require.Equal(t, 30*24*time.Hour, age)
+
+	age, err = parseAge("90m")
+	require.NoError(t, err)
+	require.Equal(t, 90*time.Minute, age)
+
+	for _, invalid := range []string{"", "d", "-1d", "0h", "30 days"} {
+		_, err := parseAge(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func Test_formatSize(t *testing.T) {
+	require.Equal(t, "0 B", formatSize(0))
+	require.Equal(t, "1023 B", formatSize(1023))
+	require.Equal(t, "1.0 KB", formatSize(1024))
+	require.Equal(t, "1.5 MB", formatSize(1024*1024*3/2))
+	require.Equal(t, "2.0 GB", formatSize(2*1024*1024*1024))
+}
+
+func Test_isPathInDir(t *testing.T) {
+	require.Equal(t, true, isPathInDir("/ws/dirs/a", "/ws/dirs"))
+	require.Equal(t, true, isPathInDir("/ws/dirs/a/b", "/ws/dirs/"))
+	require.Equal(t, false, isPathInDir("/ws/dirs", "/ws/dirs"))
+	require.Equal(t, false, isPathInDir("/ws/other", "/ws/dirs"))
+	require.Equal(t, false, isPathInDir("/ws/dirs/../other", "/ws/dirs"))
+}
@@ -0,0 +1,280 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/colorstring"
+	"github.com/bitrise-io/gows/config"
+	"github.com/bitrise-io/gows/dirsync"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/viktorbenei/cobra.v0"
+)
+
+const (
+	recoverOptionSyncBack = "sync-back"
+	recoverOptionDiscard  = "discard"
+	recoverOptionDiff     = "diff"
+	recoverOptionAbort    = "abort"
+)
+
+var (
+	isRecoverSyncBack         = false
+	isRecoverDiscard          = false
+	isRecoverDiff             = false
+	recoverSyncConflictPolicy = ""
+)
+
+// recoverCmd represents the recover command
+var recoverCmd = &cobra.Command{
+	Use:   "recover",
+	Short: "Recover an interrupted copy mode session of the project",
+	Long: `Recover an interrupted copy mode session of the project.
+
+If gows is killed while running a command in copy mode the changes done inside
+the workspace are not synced back into the project.
+You can sync back these changes (--sync-back), discard them (--discard),
+or list the changes (--diff). If no option is specified gows will ask what to do.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		projectDir, _, err := currentProjectDir()
+		if err != nil {
+			return err
+		}
+
+		journal, isFound, err := config.LoadCopySessionJournalForProject(projectDir)
+		if err != nil {
+			return fmt.Errorf("Failed to read copy session journal: %s", err)
+		}
+		if !isFound {
+			log.Info("No interrupted copy mode session found for this project")
+			return nil
+		}
+		if isCopySessionRunning(journal) {
+			return fmt.Errorf("The copy mode session of this project is still in progress (pid: %d, command: %s)", journal.PID, strings.Join(journal.Command, " "))
+		}
+
+		userConfig := loadUserConfig(projectDir)
+		if recoverSyncConflictPolicy != "" {
+			userConfig.SyncConflictPolicy = recoverSyncConflictPolicy
+		}
+
+		option := ""
+		switch {
+		case isRecoverSyncBack:
+			option = recoverOptionSyncBack
+		case isRecoverDiscard:
+			option = recoverOptionDiscard
+		case isRecoverDiff:
+			option = recoverOptionDiff
+		}
+
+		if option == "" {
+			return recoverInterruptedCopySession(userConfig, journal)
+		}
+		return doRecoverOption(userConfig, journal, option)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(recoverCmd)
+	recoverCmd.Flags().BoolVarP(&isRecoverSyncBack, "sync-back", "", false, "Sync back the changes from the workspace into the project")
+	recoverCmd.Flags().BoolVarP(&isRecoverDiscard, "discard", "", false, "Discard the changes done inside the workspace")
+	recoverCmd.Flags().BoolVarP(&isRecoverDiff, "diff", "", false, "List the changes a sync back would do in the project")
+	recoverCmd.Flags().StringVarP(&recoverSyncConflictPolicy, "conflict-policy", "", "", "Override the sync conflict policy (options: workspace-wins, project-wins, abort)")
+}
+
+// checkInterruptedCopySession checks whether the previous copy mode session of the project
+// was interrupted, and if so offers to recover it.
+// lockedWorkspaceRootPath is the workspace whose run lock is held by the caller.
+// Only a copy mode command is blocked by an interrupted session, in the other Sync Modes it's only a warning.
+func checkInterruptedCopySession(userConfig config.UserConfigModel, projectPath, lockedWorkspaceRootPath string) error {
+	journal, isFound, err := config.LoadCopySessionJournalForProject(projectPath)
+	if err != nil {
+		return fmt.Errorf("Failed to read copy session journal: %s", err)
+	}
+	if !isFound {
+		return nil
+	}
+	// no copy mode session can run in the workspace while its run lock is held by the caller
+	if journal.WorkspaceRootPath != lockedWorkspaceRootPath && isCopySessionRunning(journal) {
+		return fmt.Errorf("A copy mode session is in progress for this project (pid: %d, command: %s)", journal.PID, strings.Join(journal.Command, " "))
+	}
+
+	syncMode := userConfig.SyncMode
+	if syncMode == "" {
+		syncMode = config.DefaultSyncMode
+	}
+	if syncMode != config.SyncModeCopy {
+		log.Warningf("The previous copy mode session of this project was interrupted (command: %s, phase: %s), its changes are only in the workspace (%s)",
+			strings.Join(journal.Command, " "), journal.Phase, journal.WorkspacePackagePath)
+		log.Info("Run " + colorstring.Green("gows recover") + " to sync back or discard the changes of the interrupted session")
+		return nil
+	}
+
+	if !isInteractive() {
+		log.Warningf("The previous copy mode session of this project was interrupted (command: %s, phase: %s)", strings.Join(journal.Command, " "), journal.Phase)
+		log.Info("Run " + colorstring.Green("gows recover") + " to sync back or discard the changes of the interrupted session")
+		return errors.New("Interrupted copy mode session found")
+	}
+
+	return recoverInterruptedCopySession(userConfig, journal)
+}
+
+// isCopySessionRunning returns true if the copy mode session is still in progress.
+// The journal's PID alone is not trusted, it might belong to another process by now (if gows was killed):
+// a copy mode session holds the exclusive run lock of its workspace until it finishes,
+// so the session is only running if its workspace's run lock is held, and its process is running.
+func isCopySessionRunning(journal config.CopySessionJournalModel) bool {
+	runLockFileAbsPath, err := config.RunLockFileAbsPath(journal.WorkspaceRootPath)
+	if err != nil {
+		log.Debugf("Failed to get absolute path of run lock: %s", err)
+		return isProcessRunning(journal.PID)
+	}
+	lock, isLocked, err := config.TryLockFile(runLockFileAbsPath, true)
+	if err != nil {
+		log.Debugf("Failed to check the run lock of the workspace (%s): %s", journal.WorkspaceRootPath, err)
+		return isProcessRunning(journal.PID)
+	}
+	if isLocked {
+		if err := lock.Unlock(); err != nil {
+			log.Debugf("Failed to release the run lock of the workspace (%s): %s", journal.WorkspaceRootPath, err)
+		}
+		return false
+	}
+	return isProcessRunning(journal.PID)
+}
+
+// recoverInterruptedCopySession asks the user how to recover the interrupted session
+func recoverInterruptedCopySession(userConfig config.UserConfigModel, journal config.CopySessionJournalModel) error {
+	log.Warningf("The previous copy mode session of this project was interrupted")
+	log.Warningf(" command: %s", strings.Join(journal.Command, " "))
+	log.Warningf(" started at: %s", journal.StartedAt)
+	log.Warningf(" phase: %s", journal.Phase)
+	log.Warningf(" workspace: %s", journal.WorkspacePackagePath)
+
+	options := []string{recoverOptionSyncBack, recoverOptionDiscard, recoverOptionDiff, recoverOptionAbort}
+	if journal.Phase == config.CopySessionPhaseSyncIn {
+		log.Warning("The session was interrupted while syncing the project into the workspace, the workspace is incomplete - it can only be discarded")
+		options = []string{recoverOptionDiscard, recoverOptionDiff, recoverOptionAbort}
+	}
+
+	for {
+		option, err := askForOption("What would you like to do with the changes done inside the workspace?", options)
+		if err != nil {
+			return err
+		}
+		if option == recoverOptionAbort {
+			return errors.New("Recovery of the interrupted copy mode session aborted")
+		}
+		if err := doRecoverOption(userConfig, journal, option); err != nil {
+			return err
+		}
+		if option != recoverOptionDiff {
+			return nil
+		}
+	}
+}
+
+func doRecoverOption(userConfig config.UserConfigModel, journal config.CopySessionJournalModel, option string) error {
+	switch option {
+	case recoverOptionSyncBack:
+		return recoverCopySessionSyncBack(userConfig, journal)
+	case recoverOptionDiscard:
+		return recoverCopySessionDiscard(journal)
+	case recoverOptionDiff:
+		return printCopySessionDiff(userConfig, journal)
+	default:
+		return fmt.Errorf("Unsupported recover option: %s", option)
+	}
+}
+
+func recoverCopySessionSyncBack(userConfig config.UserConfigModel, journal config.CopySessionJournalModel) error {
+	if journal.Phase == config.CopySessionPhaseSyncIn {
+		return errors.New("The session was interrupted while syncing the project into the workspace, the workspace is incomplete and can't be synced back")
+	}
+
+	projectConfig, err := config.LoadProjectConfigFromDir(journal.ProjectPath)
+	if err != nil {
+		return fmt.Errorf("Failed to read Project Config: %s", err)
+	}
+	syncExcludeMatcher, err := createSyncExcludeMatcher(journal.ProjectPath, projectConfig, userConfig)
+	if err != nil {
+		return fmt.Errorf("Failed to read the sync exclude patterns, error: %s", err)
+	}
+
+	log.Infof("=> Sync workspace content into project: (%s) -> (%s)", journal.WorkspacePackagePath, journal.ProjectPath)
+	if err := syncBackCopySession(userConfig, syncExcludeMatcher, journal); err != nil {
+		return fmt.Errorf("Failed to sync back the project content from the Workspace, error: %s", err)
+	}
+
+	if err := finishCopySession(journal); err != nil {
+		return err
+	}
+	log.Info(colorstring.Green("Changes synced back into the project"))
+	return nil
+}
+
+func recoverCopySessionDiscard(journal config.CopySessionJournalModel) error {
+	if err := finishCopySession(journal); err != nil {
+		return err
+	}
+	log.Info(colorstring.Yellow("Changes of the interrupted session discarded") + " - the workspace will be overwritten by the next sync")
+	return nil
+}
+
+func printCopySessionDiff(userConfig config.UserConfigModel, journal config.CopySessionJournalModel) error {
+	projectConfig, err := config.LoadProjectConfigFromDir(journal.ProjectPath)
+	if err != nil {
+		return fmt.Errorf("Failed to read Project Config: %s", err)
+	}
+	syncExcludeMatcher, err := createSyncExcludeMatcher(journal.ProjectPath, projectConfig, userConfig)
+	if err != nil {
+		return fmt.Errorf("Failed to read the sync exclude patterns, error: %s", err)
+	}
+
+	changes, err := dirsync.Diff(journal.WorkspacePackagePath, journal.ProjectPath, dirsync.Options{
+		Delete:         true,
+		CompareContent: userConfig.SyncChecksum,
+		Exclude:        syncExcludeMatcher,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to compare the workspace with the project, error: %s", err)
+	}
+
+	fmt.Println()
+	fmt.Println("=== Changes a sync back would do in the project ===")
+	for _, change := range changes {
+		if change.Path == gowsCopyModeActiveFileName {
+			continue
+		}
+		switch change.Type {
+		case dirsync.ChangeAdded:
+			fmt.Println(colorstring.Greenf(" + %s", change.Path))
+		case dirsync.ChangeDeleted:
+			fmt.Println(colorstring.Redf(" - %s", change.Path))
+		default:
+			fmt.Println(colorstring.Yellowf(" ~ %s", change.Path))
+		}
+	}
+	fmt.Println("===================================================")
+	fmt.Println()
+
+	return nil
+}
+
+// finishCopySession removes the copy mode active file and the journal of the session
+func finishCopySession(journal config.CopySessionJournalModel) error {
+	if journal.ActiveFilePath != "" {
+		if err := os.Remove(journal.ActiveFilePath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("Failed to remove gows-copy-mode-active file (%s), error: %s", journal.ActiveFilePath, err)
+		}
+	}
+	if err := config.RemoveCopySessionJournal(journal.ProjectPath); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/gows/config"
+	"github.com/bitrise-io/gows/dirsync"
+	"github.com/stretchr/testify/require"
+)
+
+// Test_CopySessionHelperProcess is not a real test, it's the gows process
+// which is killed in the middle of a copy mode session by Test_RecoverInterruptedCopySession
+func Test_CopySessionHelperProcess(t *testing.T) {
+	if os.Getenv("GOWS_TEST_COPY_SESSION_HELPER") != "1" {
+		return
+	}
+
+	userConfig := config.UserConfigModel{SyncMode: config.SyncModeCopy}
+	exitCode, err := PrepareEnvironmentAndRunCommand(userConfig, "sh", "-c", `echo $$ > "$GOWS_TEST_COMMAND_PID_FILE" && echo 'changed in the workspace' > changed.txt && exec sleep 60`)
+	if err != nil {
+		os.Exit(1)
+	}
+	os.Exit(exitCode)
+}
+
+// startAndKillCopySession starts a copy mode session in a gows (helper) process,
+// and kills it while the command is running in the workspace
+func startAndKillCopySession(t *testing.T, homeDir, projectDir string) config.CopySessionJournalModel {
+	cmd := exec.Command(os.Args[0], "-test.run=Test_CopySessionHelperProcess")
+	cmd.Dir = projectDir
+	commandPIDFile := filepath.Join(homeDir, "command.pid")
+	cmd.Env = append(os.Environ(),
+		"GOWS_TEST_COPY_SESSION_HELPER=1",
+		"GOWS_TEST_COMMAND_PID_FILE="+commandPIDFile,
+		"GOPATH="+filepath.Join(homeDir, "go"),
+	)
+	require.NoError(t, cmd.Start())
+
+	journal := config.CopySessionJournalModel{}
+	for i := 0; i < 200; i++ {
+		time.Sleep(50 * time.Millisecond)
+
+		j, isFound, err := config.LoadCopySessionJournalForProject(projectDir)
+		require.NoError(t, err)
+		if !isFound || j.Phase != config.CopySessionPhaseRunning {
+			continue
+		}
+		journal = j
+		if isExists, _ := pathExists(filepath.Join(journal.WorkspacePackagePath, "changed.txt")); isExists {
+			break
+		}
+	}
+	require.Equal(t, config.CopySessionPhaseRunning, journal.Phase)
+
+	// kill -9 gows and the command it started
+	require.NoError(t, cmd.Process.Kill())
+	require.Error(t, cmd.Wait())
+	commandPID, err := ioutil.ReadFile(commandPIDFile)
+	require.NoError(t, err)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(commandPID)))
+	require.NoError(t, err)
+	commandProcess, err := os.FindProcess(pid)
+	require.NoError(t, err)
+	require.NoError(t, commandProcess.Kill())
+
+	require.Equal(t, false, isProcessRunning(journal.PID))
+
+	return journal
+}
+
+func Test_isCopySessionRunning(t *testing.T) {
+	homeDir, err := ioutil.TempDir("", "gows-session-home-")
+	require.NoError(t, err)
+	origHome := os.Getenv("HOME")
+	require.NoError(t, os.Setenv("HOME", homeDir))
+	defer func() {
+		require.NoError(t, os.Setenv("HOME", origHome))
+		require.NoError(t, os.RemoveAll(homeDir))
+	}()
+
+	// the PID of a running process
+	journal := config.CopySessionJournalModel{WorkspaceRootPath: filepath.Join(homeDir, "ws"), PID: os.Getpid()}
+
+	t.Log("The run lock of the workspace is not held - the PID is reused by another process")
+	{
+		require.Equal(t, false, isCopySessionRunning(journal))
+	}
+
+	t.Log("The run lock of the workspace is held")
+	{
+		lock, err := acquireRunLock(config.UserConfigModel{SyncMode: config.SyncModeCopy}, journal.WorkspaceRootPath, "/proj", []string{"go", "build"})
+		require.NoError(t, err)
+		defer lock.release()
+		require.Equal(t, true, isCopySessionRunning(journal))
+	}
+}
+
+func pathExists(pth string) (bool, error) {
+	_, err := os.Lstat(pth)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func Test_RecoverInterruptedCopySession(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the kill simulation in short mode")
+	}
+
+	origHome := os.Getenv("HOME")
+	origWorkDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.Setenv("HOME", origHome))
+		require.NoError(t, os.Chdir(origWorkDir))
+	}()
+
+	setup := func() (string, string) {
+		homeDir, err := ioutil.TempDir("", "gows-recover-home-")
+		require.NoError(t, err)
+		projectDir, err := ioutil.TempDir("", "gows-recover-project-")
+		require.NoError(t, err)
+		projectDir, err = filepath.EvalSymlinks(projectDir)
+		require.NoError(t, err)
+
+		require.NoError(t, ioutil.WriteFile(filepath.Join(projectDir, "gows.yml"), []byte("package_name: github.com/bitrise-io/recover-test\n"), 0644))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(projectDir, "main.go"), []byte("package main\n"), 0644))
+		return homeDir, projectDir
+	}
+
+	t.Log("Killed while running the command - sync back the changes")
+	{
+		homeDir, projectDir := setup()
+		defer func() {
+			require.NoError(t, os.RemoveAll(homeDir))
+			require.NoError(t, os.RemoveAll(projectDir))
+		}()
+
+		require.NoError(t, os.Setenv("HOME", homeDir))
+		require.NoError(t, os.Chdir(projectDir))
+		journal := startAndKillCopySession(t, homeDir, projectDir)
+
+		// the change is stranded in the workspace, the copy mode active file is left behind
+		isExists, err := pathExists(filepath.Join(projectDir, "changed.txt"))
+		require.NoError(t, err)
+		require.Equal(t, false, isExists)
+		isExists, err = pathExists(filepath.Join(projectDir, gowsCopyModeActiveFileName))
+		require.NoError(t, err)
+		require.Equal(t, true, isExists)
+
+		// the next gows invocation detects the interrupted session,
+		// the killed session is not running, even though its PID might have been reused
+		require.Equal(t, false, isCopySessionRunning(journal))
+		if !isInteractive() {
+			require.Error(t, checkInterruptedCopySession(config.UserConfigModel{SyncMode: config.SyncModeCopy}, projectDir, journal.WorkspaceRootPath))
+		}
+		// only a warning in the other sync modes
+		require.NoError(t, checkInterruptedCopySession(config.UserConfigModel{SyncMode: config.SyncModeSymlink}, projectDir, journal.WorkspaceRootPath))
+
+		changes, err := dirsync.Diff(journal.WorkspacePackagePath, projectDir, dirsync.Options{Delete: true})
+		require.NoError(t, err)
+		require.Contains(t, changes, dirsync.Change{Path: "changed.txt", Type: dirsync.ChangeAdded})
+
+		require.NoError(t, doRecoverOption(config.UserConfigModel{}, journal, recoverOptionSyncBack))
+
+		content, err := ioutil.ReadFile(filepath.Join(projectDir, "changed.txt"))
+		require.NoError(t, err)
+		require.Equal(t, "changed in the workspace\n", string(content))
+		isExists, err = pathExists(filepath.Join(projectDir, gowsCopyModeActiveFileName))
+		require.NoError(t, err)
+		require.Equal(t, false, isExists)
+
+		_, isFound, err := config.LoadCopySessionJournalForProject(projectDir)
+		require.NoError(t, err)
+		require.Equal(t, false, isFound)
+		require.NoError(t, checkInterruptedCopySession(config.UserConfigModel{SyncMode: config.SyncModeCopy}, projectDir, journal.WorkspaceRootPath))
+	}
+
+	t.Log("Killed while running the command - discard the changes")
+	{
+		homeDir, projectDir := setup()
+		defer func() {
+			require.NoError(t, os.RemoveAll(homeDir))
+			require.NoError(t, os.RemoveAll(projectDir))
+		}()
+
+		require.NoError(t, os.Setenv("HOME", homeDir))
+		require.NoError(t, os.Chdir(projectDir))
+		journal := startAndKillCopySession(t, homeDir, projectDir)
+
+		require.NoError(t, doRecoverOption(config.UserConfigModel{}, journal, recoverOptionDiscard))
+
+		isExists, err := pathExists(filepath.Join(projectDir, "changed.txt"))
+		require.NoError(t, err)
+		require.Equal(t, false, isExists)
+		isExists, err = pathExists(filepath.Join(projectDir, gowsCopyModeActiveFileName))
+		require.NoError(t, err)
+		require.Equal(t, false, isExists)
+
+		_, isFound, err := config.LoadCopySessionJournalForProject(projectDir)
+		require.NoError(t, err)
+		require.Equal(t, false, isFound)
+	}
+}
@@ -0,0 +1,247 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/colorstring"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-io/gows/config"
+	"github.com/bitrise-io/gows/gows"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/viktorbenei/cobra.v0"
+)
+
+var (
+	isRelinkForce = false
+)
+
+// relinkCmd represents the relink command
+var relinkCmd = &cobra.Command{
+	Use:   "relink [old-path]",
+	Short: "Link the workspace of a moved (or renamed) project to its new path",
+	Long: `Link the workspace of a moved (or renamed) project to its new path.
+
+Workspaces are registered for the project's path. Every project gets an ID
+(stored as project_id in its .gows.user.yml), and if a project shows up at a new path
+its workspace is relinked automatically. If the project has no ID yet
+(e.g. it was moved before gows generated one) specify the project's old path.
+
+The registry entry of the old path is moved to the current project, and the
+project's symlink inside the workspace is pointed to the new path.
+If the project still exists at the old path (e.g. it was copied, not moved)
+the workspace is only relinked with --force.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 1 {
+			return errors.New("More than one old path specified")
+		}
+
+		projectDir, _, err := currentProjectDir()
+		if err != nil {
+			return err
+		}
+		projectID, err := ensureProjectID(projectDir)
+		if err != nil {
+			return err
+		}
+
+		oldProjectPath := ""
+		if len(args) == 1 {
+			oldProjectPath, err = config.CanonicalProjectPath(args[0])
+			if err != nil {
+				return fmt.Errorf("Failed to get canonical path of the project (%s), error: %s", args[0], err)
+			}
+		} else {
+			gowsConfig, err := config.LoadGOWSConfigFromFile()
+			if err != nil {
+				return fmt.Errorf("Failed to load gows config: %s", err)
+			}
+			oldProjectPaths := []string{}
+			for _, aProjectPath := range gowsConfig.ProjectPathsForID(projectID) {
+				if aProjectPath != projectDir {
+					oldProjectPaths = append(oldProjectPaths, aProjectPath)
+				}
+			}
+			if len(oldProjectPaths) == 0 {
+				return errors.New("No workspace registered for the project's ID at another path, specify the project's old path (gows relink OLD-PATH)")
+			}
+			if len(oldProjectPaths) > 1 {
+				return fmt.Errorf("The project's ID is registered for more than one path (%s), specify the project's old path (gows relink OLD-PATH)", strings.Join(oldProjectPaths, ", "))
+			}
+			oldProjectPath = oldProjectPaths[0]
+		}
+
+		if oldProjectPath == projectDir {
+			return fmt.Errorf("The old path (%s) is the current project's path", oldProjectPath)
+		}
+		if isExists, err := pathutil.IsPathExists(oldProjectPath); err != nil {
+			return fmt.Errorf("Failed to check the old path (%s), error: %s", oldProjectPath, err)
+		} else if isExists && !isRelinkForce {
+			return fmt.Errorf("The project still exists at the old path (%s), use --force to link its workspace to the current project anyway", oldProjectPath)
+		}
+
+		var wsConfig config.WorkspaceConfigModel
+		err = config.UpdateGOWSConfig(func(gowsConfig *config.GOWSConfigModel) error {
+			wsConfig, err = relinkProjectWorkspace(gowsConfig, oldProjectPath, projectDir, projectID)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("Failed to relink the workspace: %s", err)
+		}
+		if err := linkProjectIntoRelinkedWorkspace(projectDir, wsConfig.WorkspaceRootPath); err != nil {
+			return err
+		}
+
+		log.Infof("Workspace (%s) relinked: %s -> %s", wsConfig.WorkspaceRootPath, oldProjectPath, colorstring.Green(projectDir))
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(relinkCmd)
+	relinkCmd.Flags().BoolVarP(&isRelinkForce, "force", "", false, "Relink even if the project still exists at the old path")
+}
+
+// ensureProjectID returns the ID of the project, a new one is generated (and saved into the project's .gows.user.yml)
+// if the project does not have one yet
+func ensureProjectID(projectDir string) (string, error) {
+	userConfigFilePath := filepath.Join(projectDir, config.UserConfigFileName)
+	if isExists, err := pathutil.IsPathExists(userConfigFilePath); err != nil {
+		return "", fmt.Errorf("Failed to check user config (%s), error: %s", userConfigFilePath, err)
+	} else if isExists {
+		userConfig, err := config.LoadUserConfigFromDir(projectDir)
+		if err != nil {
+			return "", err
+		}
+		if userConfig.ProjectID != "" {
+			return userConfig.ProjectID, nil
+		}
+	}
+
+	projectID, err := config.NewProjectID()
+	if err != nil {
+		return "", err
+	}
+	if err := config.SaveProjectIDToDir(projectDir, projectID); err != nil {
+		return "", fmt.Errorf("Failed to save the project's ID: %s", err)
+	}
+	log.Debugf("Project ID generated: %s", projectID)
+	return projectID, nil
+}
+
+// relinkProjectWorkspace moves the registry entry of the old project path to projectDir.
+// A workspace registered for projectDir is replaced (it is removed by gows prune).
+func relinkProjectWorkspace(gowsConfig *config.GOWSConfigModel, oldProjectPath, projectDir, projectID string) (config.WorkspaceConfigModel, error) {
+	wsConfig, isFound := gowsConfig.Workspaces[oldProjectPath]
+	if !isFound {
+		return config.WorkspaceConfigModel{}, fmt.Errorf("No workspace registered for the project path: %s", oldProjectPath)
+	}
+	if currWsConfig, isFound := gowsConfig.WorkspaceForProjectLocation(projectDir); isFound && currWsConfig.WorkspaceRootPath != wsConfig.WorkspaceRootPath {
+		log.Warningf("The current workspace of the project (%s) is replaced, run %s to remove it", currWsConfig.WorkspaceRootPath, colorstring.Green("gows prune"))
+	}
+
+	delete(gowsConfig.Workspaces, oldProjectPath)
+	wsConfig.ProjectID = projectID
+	if err := gowsConfig.SetWorkspaceForProjectLocation(projectDir, wsConfig); err != nil {
+		return config.WorkspaceConfigModel{}, err
+	}
+	return wsConfig, nil
+}
+
+// linkProjectIntoRelinkedWorkspace points the project's symlink inside the workspace (of the symlink Sync Mode)
+// to the project's new path - in the other Sync Modes the next gows command updates the workspace
+func linkProjectIntoRelinkedWorkspace(projectDir, workspaceRootPath string) error {
+	projectConfig, err := config.LoadProjectConfigFromDir(projectDir)
+	if err != nil || projectConfig.PackageName == "" {
+		log.Debugf("No package name found for the project (%s), the next gows command links it into the workspace", projectDir)
+		return nil
+	}
+
+	fullPackageWorkspacePath := filepath.Join(workspaceRootPath, "src", projectConfig.PackageName)
+	fileInfo, isExists, err := pathutil.PathCheckAndInfos(fullPackageWorkspacePath)
+	if err != nil {
+		return fmt.Errorf("Failed to check Symlink status (at: %s), error: %s", fullPackageWorkspacePath, err)
+	}
+	if !isExists || fileInfo.Mode()&os.ModeSymlink == 0 {
+		return nil
+	}
+
+	// the symlink points to the old path
+	if err := os.Remove(fullPackageWorkspacePath); err != nil {
+		return fmt.Errorf("Failed to remove Symlink (at: %s), error: %s", fullPackageWorkspacePath, err)
+	}
+	if err := gows.CreateOrUpdateSymlink(projectDir, fullPackageWorkspacePath); err != nil {
+		return fmt.Errorf("Failed to create Project->Workspace symlink, error: %s", err)
+	}
+	return nil
+}
+
+// relinkMovedProject relinks the workspace registered for the project's ID at another path,
+// if the project no longer exists at that path (it was moved).
+// If it still exists (the project was copied) the copy gets a new ID.
+// Returns true if the workspace was relinked.
+func relinkMovedProject(projectDir string) (bool, error) {
+	userConfig, err := config.LoadUserConfigFromDir(projectDir)
+	if err != nil || userConfig.ProjectID == "" {
+		return false, nil
+	}
+	projectID := userConfig.ProjectID
+
+	oldProjectPath := ""
+	isCopied := false
+	var wsConfig config.WorkspaceConfigModel
+	err = config.UpdateGOWSConfig(func(gowsConfig *config.GOWSConfigModel) error {
+		if _, isFound := gowsConfig.WorkspaceForProjectLocation(projectDir); isFound {
+			// registered in the meantime
+			return nil
+		}
+		oldProjectPaths := gowsConfig.ProjectPathsForID(projectID)
+		if len(oldProjectPaths) == 0 {
+			return nil
+		}
+
+		oldProjectPath = oldProjectPaths[0]
+		for _, aProjectPath := range oldProjectPaths {
+			if isExists, err := pathutil.IsPathExists(aProjectPath); err != nil {
+				return fmt.Errorf("Failed to check project (%s), error: %s", aProjectPath, err)
+			} else if isExists {
+				oldProjectPath = aProjectPath
+				isCopied = true
+				return nil
+			}
+		}
+
+		wsConfig, err = relinkProjectWorkspace(gowsConfig, oldProjectPath, projectDir, projectID)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if isCopied {
+		log.Warningf("The project's ID is registered for another project (%s) too, which still exists - is this a copy of that project?", oldProjectPath)
+		log.Warningf("A new workspace is created for this project, run %s if you want to use the workspace of %s", colorstring.Green("gows relink --force "+oldProjectPath), oldProjectPath)
+		newProjectID, err := config.NewProjectID()
+		if err != nil {
+			return false, err
+		}
+		if err := config.SaveProjectIDToDir(projectDir, newProjectID); err != nil {
+			return false, fmt.Errorf("Failed to save the project's ID: %s", err)
+		}
+		return false, nil
+	}
+	if wsConfig.WorkspaceRootPath == "" {
+		return false, nil
+	}
+
+	log.Infof("The project was moved (from: %s), its workspace (%s) is relinked", oldProjectPath, wsConfig.WorkspaceRootPath)
+	if err := linkProjectIntoRelinkedWorkspace(projectDir, wsConfig.WorkspaceRootPath); err != nil {
+		return false, err
+	}
+	return true, nil
+}
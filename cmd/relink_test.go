@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/gows/config"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_relinkMovedProject(t *testing.T) {
+	homeDir, err := ioutil.TempDir("", "gows-relink-home-")
+	require.NoError(t, err)
+	homeDir, err = filepath.EvalSymlinks(homeDir)
+	require.NoError(t, err)
+	origHome := os.Getenv("HOME")
+	require.NoError(t, os.Setenv("HOME", homeDir))
+	defer func() {
+		require.NoError(t, os.Setenv("HOME", origHome))
+		require.NoError(t, os.RemoveAll(homeDir))
+	}()
+
+	oldProjectPath := filepath.Join(homeDir, "dev", "foo")
+	projectPath := filepath.Join(homeDir, "work", "foo")
+	require.NoError(t, os.MkdirAll(oldProjectPath, 0755))
+	require.NoError(t, config.SaveProjectConfigToDir(oldProjectPath, config.ProjectConfigModel{PackageName: "github.com/org/foo"}))
+
+	t.Log("The project gets an ID")
+	{
+		projectID, err := ensureProjectID(oldProjectPath)
+		require.NoError(t, err)
+		require.NotEqual(t, "", projectID)
+
+		sameProjectID, err := ensureProjectID(oldProjectPath)
+		require.NoError(t, err)
+		require.Equal(t, projectID, sameProjectID)
+	}
+
+	require.NoError(t, initWorkspaceForProjectPath(oldProjectPath, false))
+	gowsConfig, err := config.LoadGOWSConfigFromFile()
+	require.NoError(t, err)
+	wsConfig, isFound := gowsConfig.WorkspaceForProjectLocation(oldProjectPath)
+	require.Equal(t, true, isFound)
+	userConfig, err := config.LoadUserConfigFromDir(oldProjectPath)
+	require.NoError(t, err)
+	require.Equal(t, userConfig.ProjectID, wsConfig.ProjectID)
+
+	fullPackageWorkspacePath := filepath.Join(wsConfig.WorkspaceRootPath, "src", "github.com", "org", "foo")
+	require.NoError(t, linkProjectIntoWorkspace(oldProjectPath, fullPackageWorkspacePath))
+
+	t.Log("Copied project - gets a new ID and no workspace")
+	{
+		copyProjectPath := filepath.Join(homeDir, "copy", "foo")
+		require.NoError(t, os.MkdirAll(copyProjectPath, 0755))
+		require.NoError(t, config.SaveUserConfigToDir(copyProjectPath, userConfig))
+
+		isRelinked, err := relinkMovedProject(copyProjectPath)
+		require.NoError(t, err)
+		require.Equal(t, false, isRelinked)
+
+		copyUserConfig, err := config.LoadUserConfigFromDir(copyProjectPath)
+		require.NoError(t, err)
+		require.NotEqual(t, "", copyUserConfig.ProjectID)
+		require.NotEqual(t, userConfig.ProjectID, copyUserConfig.ProjectID)
+	}
+
+	t.Log("Moved project - the workspace is relinked")
+	{
+		require.NoError(t, os.MkdirAll(filepath.Dir(projectPath), 0755))
+		require.NoError(t, os.Rename(oldProjectPath, projectPath))
+
+		isRelinked, err := relinkMovedProject(projectPath)
+		require.NoError(t, err)
+		require.Equal(t, true, isRelinked)
+
+		gowsConfig, err := config.LoadGOWSConfigFromFile()
+		require.NoError(t, err)
+		_, isFound := gowsConfig.WorkspaceForProjectLocation(oldProjectPath)
+		require.Equal(t, false, isFound)
+		relinkedWsConfig, isFound := gowsConfig.WorkspaceForProjectLocation(projectPath)
+		require.Equal(t, true, isFound)
+		require.Equal(t, wsConfig.WorkspaceRootPath, relinkedWsConfig.WorkspaceRootPath)
+		require.Equal(t, wsConfig.CreatedAt.Unix(), relinkedWsConfig.CreatedAt.Unix())
+
+		target, err := os.Readlink(fullPackageWorkspacePath)
+		require.NoError(t, err)
+		require.Equal(t, projectPath, target)
+
+		// already linked
+		isRelinked, err = relinkMovedProject(projectPath)
+		require.NoError(t, err)
+		require.Equal(t, false, isRelinked)
+	}
+
+	t.Log("Relink by the old path")
+	{
+		gowsConfig := config.GOWSConfigModel{
+			Workspaces: map[string]config.WorkspaceConfigModel{
+				"/old/bar": {WorkspaceRootPath: "/ws/bar"},
+				"/new/bar": {WorkspaceRootPath: "/ws/bar-new"},
+			},
+		}
+		wsConfig, err := relinkProjectWorkspace(&gowsConfig, "/old/bar", "/new/bar", "id-1")
+		require.NoError(t, err)
+		require.Equal(t, "/ws/bar", wsConfig.WorkspaceRootPath)
+		require.Equal(t, map[string]config.WorkspaceConfigModel{
+			"/new/bar": {WorkspaceRootPath: "/ws/bar", ProjectID: "id-1"},
+		}, gowsConfig.Workspaces)
+
+		_, err = relinkProjectWorkspace(&gowsConfig, "/old/baz", "/new/bar", "id-1")
+		require.EqualError(t, err, "No workspace registered for the project path: /old/baz")
+	}
+}
@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/colorstring"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-io/gows/config"
+	"github.com/bitrise-io/gows/vcs"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/viktorbenei/cobra.v0"
+)
+
+var (
+	restoreMirrors = []string{}
+	isRestoreClean = false
+)
+
+// restoreCmd represents the restore command
+var restoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Recreate the dependencies recorded in gows.lock.yml in the workspace",
+	Long: `Recreate the dependencies recorded in gows.lock.yml (see: gows freeze) in the workspace.
+
+Every dependency is checked out at the recorded revision, into the src directory
+of the project's workspace. A missing checkout is cloned from the first local mirror
+(--mirror DIR, with the checkouts at DIR/<import path> or DIR/<import path>.git)
+which has the revision, or from the recorded remote.
+Checkouts with uncommitted changes are not touched.
+
+With --clean the checkouts not recorded in gows.lock.yml are removed from the workspace.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		projectDir, _, err := currentProjectDir()
+		if err != nil {
+			return err
+		}
+		projectConfig, err := config.LoadProjectConfigFromDir(projectDir)
+		if err != nil {
+			return fmt.Errorf("Failed to read Project Config: %s", err)
+		}
+		depsLock, err := config.LoadDependenciesLockFromDir(projectDir)
+		if err != nil {
+			log.Info("Run " + colorstring.Green("gows freeze") + " to record the dependencies of the workspace")
+			return err
+		}
+		wsConfig, err := workspaceForProject(projectDir)
+		if err != nil {
+			return err
+		}
+
+		mirrors := []string{}
+		for _, mirror := range restoreMirrors {
+			mirrorAbsPath, err := pathutil.AbsPath(mirror)
+			if err != nil {
+				return fmt.Errorf("Failed to get absolute path of mirror (%s), error: %s", mirror, err)
+			}
+			mirrors = append(mirrors, mirrorAbsPath)
+		}
+
+		// restore changes the workspace, it can't share the workspace with the running commands
+		userConfig := loadUserConfig(projectDir)
+		if userConfig.RunLock == "" || userConfig.RunLock == config.RunLockShared {
+			userConfig.RunLock = config.RunLockWait
+		}
+		runLock, err := acquireRunLock(userConfig, wsConfig.WorkspaceRootPath, projectDir, []string{"gows", "restore"})
+		if err != nil {
+			return err
+		}
+		defer runLock.release()
+
+		srcDir := filepath.Join(wsConfig.WorkspaceRootPath, "src")
+		if err := restoreDependencies(srcDir, depsLock, mirrors); err != nil {
+			return err
+		}
+		if isRestoreClean {
+			if err := cleanDependencies(srcDir, depsLock, projectImportPaths(projectConfig)); err != nil {
+				return err
+			}
+		}
+
+		log.Infof("%d dependencies restored", len(depsLock.Dependencies))
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(restoreCmd)
+	restoreCmd.Flags().StringSliceVarP(&restoreMirrors, "mirror", "", []string{}, "Directory of local mirrors (DIR/<import path> or DIR/<import path>.git) to clone from, before the remotes (can be specified more than once)")
+	restoreCmd.Flags().BoolVarP(&isRestoreClean, "clean", "", false, "Remove the checkouts not recorded in gows.lock.yml from the workspace")
+}
+
+// validateDependencyImportPath checks that the dependency's import path stays inside the workspace's src directory
+func validateDependencyImportPath(importPath string) error {
+	if importPath == "" || path.IsAbs(importPath) || path.Clean(importPath) != importPath || importPath == ".." || strings.HasPrefix(importPath, "../") {
+		return fmt.Errorf("Invalid import path: %s", importPath)
+	}
+	return nil
+}
+
+// validateDependencyLock checks the dependency's lock entry before its values are passed to git / hg:
+// the import path has to stay inside the workspace's src directory, and the remote and the revision
+// can't be options (e.g. --upload-pack=CMD or --config=hooks.CMD, in a malicious lock file)
+func validateDependencyLock(dep config.DependencyLockModel) error {
+	if err := validateDependencyImportPath(dep.ImportPath); err != nil {
+		return err
+	}
+	if strings.HasPrefix(dep.Remote, "-") || strings.ContainsAny(dep.Remote, "\r\n") {
+		return fmt.Errorf("Invalid remote of %s: %s", dep.ImportPath, dep.Remote)
+	}
+	if dep.Revision == "" || strings.HasPrefix(dep.Revision, "-") || strings.ContainsAny(dep.Revision, "\r\n") {
+		return fmt.Errorf("Invalid revision of %s: %s", dep.ImportPath, dep.Revision)
+	}
+	return nil
+}
+
+// dependencySources returns the mirrors and the remote to restore the dependency from, in this order
+func dependencySources(dep config.DependencyLockModel, mirrors []string) []string {
+	sources := []string{}
+	for _, mirror := range mirrors {
+		for _, mirrorPath := range []string{filepath.Join(mirror, filepath.FromSlash(dep.ImportPath)), filepath.Join(mirror, filepath.FromSlash(dep.ImportPath)+".git")} {
+			if isExists, err := pathutil.IsDirExists(mirrorPath); err == nil && isExists {
+				sources = append(sources, mirrorPath)
+			}
+		}
+	}
+	if dep.Remote != "" {
+		sources = append(sources, dep.Remote)
+	}
+	return sources
+}
+
+// restoreDependencies checks out the recorded revision of every dependency into the workspace's src directory.
+// Every dependency is tried, the failed ones are returned in the error.
+func restoreDependencies(srcDir string, depsLock config.DependenciesLockModel, mirrors []string) error {
+	failedImportPaths := []string{}
+	for _, dep := range depsLock.Dependencies {
+		if err := validateDependencyLock(dep); err != nil {
+			log.Errorf("%s", err)
+			failedImportPaths = append(failedImportPaths, dep.ImportPath)
+			continue
+		}
+
+		sources := dependencySources(dep, mirrors)
+		if len(sources) == 0 {
+			log.Errorf("%s has no remote, and it's not found in the mirrors", dep.ImportPath)
+			failedImportPaths = append(failedImportPaths, dep.ImportPath)
+			continue
+		}
+
+		log.Infof(" * %s (%s) @ %s", dep.ImportPath, dep.VCS, shortRevision(dep.Revision))
+		if err := vcs.Restore(dep.VCS, filepath.Join(srcDir, filepath.FromSlash(dep.ImportPath)), dep.Remote, dep.Revision, sources); err != nil {
+			log.Errorf("Failed to restore %s: %s", dep.ImportPath, err)
+			failedImportPaths = append(failedImportPaths, dep.ImportPath)
+		}
+	}
+
+	if len(failedImportPaths) > 0 {
+		return fmt.Errorf("Failed to restore %d dependencies: %s", len(failedImportPaths), strings.Join(failedImportPaths, ", "))
+	}
+	return nil
+}
+
+// cleanDependencies removes the checkouts of the workspace's src directory which are not recorded in the lock,
+// except the ones at skipImportPaths, and the ones with uncommitted changes
+func cleanDependencies(srcDir string, depsLock config.DependenciesLockModel, skipImportPaths []string) error {
+	lockedImportPaths := map[string]bool{}
+	for _, dep := range depsLock.Dependencies {
+		lockedImportPaths[dep.ImportPath] = true
+	}
+
+	checkouts, err := vcs.FindCheckouts(srcDir, skipImportPaths)
+	if err != nil {
+		return err
+	}
+	for _, checkout := range checkouts {
+		if lockedImportPaths[checkout.ImportPath] {
+			continue
+		}
+		if !isPathInDir(checkout.Dir, srcDir) {
+			return errors.New("Checkout found outside of the workspace: " + checkout.Dir)
+		}
+		if isDirty, err := checkout.IsDirty(); err != nil || isDirty {
+			log.Warningf("%s is not recorded in %s, but it has uncommitted changes - not removing it", checkout.ImportPath, config.DependenciesLockFileName)
+			continue
+		}
+		log.Infof(" - %s (not recorded in %s)", checkout.ImportPath, config.DependenciesLockFileName)
+		if err := os.RemoveAll(checkout.Dir); err != nil {
+			return fmt.Errorf("Failed to remove (%s), error: %s", checkout.Dir, err)
+		}
+	}
+	return nil
+}
@@ -7,12 +7,12 @@ import (
 	"github.com/pkg/errors"
 
 	log "github.com/sirupsen/logrus"
-	"github.com/bitrise-io/gows/config"
 	"gopkg.in/viktorbenei/cobra.v0"
 )
 
 var (
-	loglevelFlag string
+	loglevelFlag   string
+	isHermeticFlag bool
 )
 
 // RootCmd represents the base command when called without any subcommands
@@ -31,8 +31,12 @@ the (per-project isolated) workspace directory structure, no matter where your p
 gows works perfectly with other Go tools, all it does is it ensures that every project
 gets it's own, isolated Go workspace and sets $GOPATH accordingly.
 
-Sync Mode can be set in the .gows.user.yml config file,
-or through the $GOWS_SYNC_MODE environment variable.`,
+Sync Mode (symlink, copy or - on Linux - mount) can be set in the .gows.user.yml config file,
+or through the $GOWS_SYNC_MODE environment variable.
+
+In copy Sync Mode gows uses its built in sync engine by default,
+rsync can be selected with sync_engine: rsync in .gows.user.yml
+or through the $GOWS_SYNC_ENGINE environment variable.`,
 
 	DisableFlagParsing: true,
 
@@ -78,6 +82,8 @@ func Execute() {
 
 func init() {
 	RootCmd.PersistentFlags().StringVarP(&loglevelFlag, "loglevel", "l", "", `Log level (options: debug, info, warn, error, fatal, panic). [$GOWS_LOGLEVEL]`)
+	RootCmd.Flags().BoolVarP(&isHermeticFlag, "hermetic", "", false, "Run the command in hermetic mode (only the allowlisted environment variables are kept), even if it's not enabled in gows.yml")
+	RootCmd.Flags().BoolVarP(&isNoHooksFlag, "no-hooks", "", false, "Don't run the hooks specified in gows.yml and .gows.user.yml")
 	RootCmd.PreRunE = func(cmd *cobra.Command, args []string) error {
 		if len(args) < 1 {
 			return errors.New("No command specified")
@@ -87,6 +93,24 @@ func init() {
 		return nil
 	}
 	RootCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		// flag parsing is disabled for the root command (every argument belongs to the command to run),
+		// gows's own flags have to precede the command
+	flagsLoop:
+		for len(args) > 0 {
+			switch args[0] {
+			case "--hermetic":
+				isHermeticFlag = true
+			case "--no-hooks":
+				isNoHooksFlag = true
+			default:
+				break flagsLoop
+			}
+			args = args[1:]
+		}
+		if len(args) < 1 {
+			return errors.New("No command specified")
+		}
+
 		cmdName := args[0]
 		if cmdName == "-h" || cmdName == "--help" {
 			if err := RootCmd.Help(); err != nil {
@@ -100,16 +124,11 @@ func init() {
 			cmdArgs = args[1:]
 		}
 
-		userConfig, err := config.LoadUserConfigFromFile()
+		projectDir, _, err := currentProjectDir()
 		if err != nil {
-			log.Debug("No User Config found, using defaults")
-			userConfig = config.CreateDefaultUserConfig()
-		}
-		forceSyncMode := os.Getenv("GOWS_SYNC_MODE")
-		if forceSyncMode != "" {
-			log.Debugf(" (i) Sync Mode specified as a parameter, using it (%s)", forceSyncMode)
-			userConfig.SyncMode = forceSyncMode
+			log.Debugf("Failed to find the project's root directory: %s", err)
 		}
+		userConfig := loadUserConfig(projectDir)
 		log.Debugf("User Config: %#v", userConfig)
 
 		exitCode, err := PrepareEnvironmentAndRunCommand(userConfig, cmdName, cmdArgs...)
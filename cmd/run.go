@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/colorstring"
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/gows/config"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/viktorbenei/cobra.v0"
+)
+
+var (
+	isRunList = false
+)
+
+// runCmd represents the run command
+var runCmd = &cobra.Command{
+	Use:   "run <script> [-- extra args]",
+	Short: "Run a script of the project (defined in gows.yml)",
+	Long: `Run a script of the project, defined in the scripts section of gows.yml:
+
+  scripts:
+    generate:
+      command: go
+      args: [generate, ./...]
+    test:
+      description: Run the tests with the race detector
+      command: go
+      args: [test, -race, ./...]
+      env:
+        CGO_ENABLED: "1"
+      workdir: pkg
+      deps: [generate]
+
+The scripts are run in the project's workspace, the same way as any other gows command.
+The dependencies (deps) of the script run first, in order - the first failing script
+stops the run. Extra arguments (after --) are passed to the script, but not to its dependencies:
+
+  gows run test -- -run TestSomething
+
+List the scripts of the project with: gows run --list`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		scriptArgs := args
+		extraArgs := []string{}
+		if argsLenAtDash := cmd.ArgsLenAtDash(); argsLenAtDash >= 0 {
+			scriptArgs = args[:argsLenAtDash]
+			extraArgs = args[argsLenAtDash:]
+		}
+
+		projectDir, _, err := currentProjectDir()
+		if err != nil {
+			log.Info("Run " + colorstring.Green("gows init") + " to initialize a workspace & gows config for this project")
+			return err
+		}
+		projectConfig, err := config.LoadProjectConfigFromDir(projectDir)
+		if err != nil {
+			return fmt.Errorf("Failed to read Project Config: %s", err)
+		}
+
+		if isRunList {
+			fmt.Print(formatScriptList(projectConfig.Scripts))
+			return nil
+		}
+
+		if len(scriptArgs) < 1 {
+			return errors.New("No script specified (list the scripts of the project with: gows run --list)")
+		}
+		if len(scriptArgs) > 1 {
+			return fmt.Errorf("More than one script specified (%s) - the extra arguments of the script should be separated with --", strings.Join(scriptArgs, ", "))
+		}
+		scriptName := scriptArgs[0]
+
+		runOrder, err := scriptRunOrder(projectConfig.Scripts, scriptName)
+		if err != nil {
+			return err
+		}
+
+		userConfig := loadUserConfig(projectDir)
+		log.Debugf("User Config: %#v", userConfig)
+
+		for _, name := range runOrder {
+			script := projectConfig.Scripts[name]
+			runOpts, err := scriptRunOptions(script)
+			if err != nil {
+				return fmt.Errorf("Invalid script (%s): %s", name, err)
+			}
+
+			cmdArgs := append([]string{}, script.Args...)
+			if name == scriptName {
+				cmdArgs = append(cmdArgs, extraArgs...)
+			}
+
+			log.Infof("[Run] %s: $ %s", colorstring.Green(name), command.PrintableCommandArgs(false, append([]string{script.Command}, cmdArgs...)))
+			exitCode, err := prepareEnvironmentAndRunCommand(userConfig, runOpts, script.Command, cmdArgs...)
+			if exitCode != 0 {
+				log.Errorf("[Run] %s failed (exit code: %d)", name, exitCode)
+				os.Exit(exitCode)
+			}
+			if err != nil {
+				return fmt.Errorf("Failed to run script (%s): %s", name, err)
+			}
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(runCmd)
+	runCmd.Flags().BoolVarP(&isRunList, "list", "", false, "List the scripts of the project")
+	runCmd.Flags().BoolVarP(&isHermeticFlag, "hermetic", "", false, "Run the scripts in hermetic mode, even if it's not enabled in gows.yml")
+	runCmd.Flags().BoolVarP(&isNoHooksFlag, "no-hooks", "", false, "Don't run the hooks specified in gows.yml and .gows.user.yml")
+}
+
+// scriptRunOrder returns the scripts to run (the script's dependencies first, then the script itself).
+// Every script is included only once, even if more scripts depend on it.
+func scriptRunOrder(scripts map[string]config.ScriptConfigModel, scriptName string) ([]string, error) {
+	runOrder := []string{}
+	isAdded := map[string]bool{}
+	isVisiting := map[string]bool{}
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		path = append(path, name)
+		if isAdded[name] {
+			return nil
+		}
+		if isVisiting[name] {
+			return fmt.Errorf("Dependency cycle between scripts: %s", strings.Join(path, " -> "))
+		}
+
+		script, isFound := scripts[name]
+		if !isFound {
+			if len(path) > 1 {
+				return fmt.Errorf("Script (%s) depends on an undefined script: %s", path[len(path)-2], name)
+			}
+			return fmt.Errorf("No script (%s) defined in %s (list the scripts of the project with: gows run --list)", name, config.ProjectConfigFileName)
+		}
+
+		isVisiting[name] = true
+		for _, dep := range script.Deps {
+			if err := visit(dep, path); err != nil {
+				return err
+			}
+		}
+		isVisiting[name] = false
+
+		isAdded[name] = true
+		runOrder = append(runOrder, name)
+		return nil
+	}
+
+	if err := visit(scriptName, []string{}); err != nil {
+		return []string{}, err
+	}
+	return runOrder, nil
+}
+
+// scriptRunOptions validates the script and returns its run options
+func scriptRunOptions(script config.ScriptConfigModel) (runOptions, error) {
+	if script.Command == "" {
+		return runOptions{}, errors.New("No command specified")
+	}
+
+	workDir := "."
+	if script.WorkDir != "" {
+		workDir = filepath.Clean(script.WorkDir)
+		if filepath.IsAbs(workDir) || workDir == ".." || strings.HasPrefix(workDir, ".."+string(filepath.Separator)) {
+			return runOptions{}, fmt.Errorf("The workdir (%s) should be a directory inside the project (relative to the project's directory)", script.WorkDir)
+		}
+	}
+
+	return runOptions{
+		WorkDir: workDir,
+		Env:     script.Env,
+	}, nil
+}
+
+// formatScriptList lists the scripts, in alphabetical order
+func formatScriptList(scripts map[string]config.ScriptConfigModel) string {
+	if len(scripts) == 0 {
+		return fmt.Sprintf("No scripts defined in %s\n", config.ProjectConfigFileName)
+	}
+
+	names := []string{}
+	for name := range scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := []string{}
+	for _, name := range names {
+		script := scripts[name]
+		lines = append(lines, name)
+		if script.Description != "" {
+			lines = append(lines, "  "+script.Description)
+		}
+		lines = append(lines, "  $ "+command.PrintableCommandArgs(false, append([]string{script.Command}, script.Args...)))
+		if len(script.Deps) > 0 {
+			lines = append(lines, "  deps: "+strings.Join(script.Deps, ", "))
+		}
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
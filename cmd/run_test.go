@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/bitrise-io/gows/config"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_scriptRunOrder(t *testing.T) {
+	scripts := map[string]config.ScriptConfigModel{
+		"generate": {Command: "go", Args: []string{"generate", "./..."}},
+		"vet":      {Command: "go", Args: []string{"vet", "./..."}, Deps: []string{"generate"}},
+		"test":     {Command: "go", Args: []string{"test", "./..."}, Deps: []string{"generate", "vet"}},
+		"ci":       {Command: "true", Deps: []string{"vet", "test"}},
+		"missing":  {Command: "true", Deps: []string{"undefined"}},
+		"cycle-a":  {Command: "true", Deps: []string{"cycle-b"}},
+		"cycle-b":  {Command: "true", Deps: []string{"cycle-a"}},
+	}
+
+	t.Log("No deps")
+	{
+		runOrder, err := scriptRunOrder(scripts, "generate")
+		require.NoError(t, err)
+		require.Equal(t, []string{"generate"}, runOrder)
+	}
+
+	t.Log("Deps run first, in order, only once")
+	{
+		runOrder, err := scriptRunOrder(scripts, "ci")
+		require.NoError(t, err)
+		require.Equal(t, []string{"generate", "vet", "test", "ci"}, runOrder)
+	}
+
+	t.Log("Undefined script")
+	{
+		_, err := scriptRunOrder(scripts, "lint")
+		require.EqualError(t, err, "No script (lint) defined in gows.yml (list the scripts of the project with: gows run --list)")
+	}
+
+	t.Log("Undefined dependency")
+	{
+		_, err := scriptRunOrder(scripts, "missing")
+		require.EqualError(t, err, "Script (missing) depends on an undefined script: undefined")
+	}
+
+	t.Log("Dependency cycle")
+	{
+		_, err := scriptRunOrder(scripts, "cycle-a")
+		require.EqualError(t, err, "Dependency cycle between scripts: cycle-a -> cycle-b -> cycle-a")
+	}
+}
+
+func Test_scriptRunOptions(t *testing.T) {
+	t.Log("Runs in the project's directory by default")
+	{
+		runOpts, err := scriptRunOptions(config.ScriptConfigModel{Command: "go", Env: map[string]string{"CGO_ENABLED": "1"}})
+		require.NoError(t, err)
+		require.Equal(t, runOptions{WorkDir: ".", Env: map[string]string{"CGO_ENABLED": "1"}}, runOpts)
+	}
+
+	t.Log("Sub directory")
+	{
+		runOpts, err := scriptRunOptions(config.ScriptConfigModel{Command: "go", WorkDir: "./pkg/tools/"})
+		require.NoError(t, err)
+		require.Equal(t, "pkg/tools", runOpts.WorkDir)
+	}
+
+	t.Log("Invalid")
+	{
+		_, err := scriptRunOptions(config.ScriptConfigModel{})
+		require.EqualError(t, err, "No command specified")
+
+		for _, workDir := range []string{"/tmp", "..", "../other", "pkg/../../other"} {
+			_, err := scriptRunOptions(config.ScriptConfigModel{Command: "go", WorkDir: workDir})
+			require.Error(t, err, workDir)
+		}
+	}
+}
+
+func Test_formatScriptList(t *testing.T) {
+	require.Equal(t, "No scripts defined in gows.yml\n", formatScriptList(nil))
+
+	scripts := map[string]config.ScriptConfigModel{
+		"test":     {Description: "Run the tests", Command: "go", Args: []string{"test", "-run", "Test Something"}, Deps: []string{"generate"}},
+		"generate": {Command: "go", Args: []string{"generate", "./..."}},
+	}
+	require.Equal(t, `generate
+  $ go "generate" "./..."
+test
+  Run the tests
+  $ go "test" "-run" "Test Something"
+  deps: generate
+`, formatScriptList(scripts))
+}
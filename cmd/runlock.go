@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/gows/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const runLockPollInterval = 200 * time.Millisecond
+
+// runLock serializes the gows commands using the same workspace:
+// in copy mode the workspace is synced in and back, which would
+// overwrite the changes of the other commands running in the workspace
+type runLock struct {
+	lock              *config.FileLock
+	workspaceRootPath string
+}
+
+// acquireRunLock acquires the run lock of the workspace, according to the run lock mode of the user config.
+// In symlink mode, with the shared run lock mode, the lock is shared with the other
+// symlink mode commands, and only excludes the copy and mount mode ones.
+func acquireRunLock(userConfig config.UserConfigModel, workspaceRootPath, projectPath string, command []string) (*runLock, error) {
+	mode := userConfig.RunLock
+	if mode == "" {
+		mode = config.DefaultRunLock
+	}
+	timeout := time.Duration(userConfig.RunLockTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = config.DefaultRunLockTimeout * time.Second
+	}
+	if mode != config.RunLockWait && mode != config.RunLockFailFast && mode != config.RunLockShared {
+		return nil, fmt.Errorf("Unsupported Run Lock mode: %s", mode)
+	}
+	syncMode := userConfig.SyncMode
+	if syncMode == "" {
+		syncMode = config.DefaultSyncMode
+	}
+	// the symlink mode commands don't change the workspace, except (re-)creating the same symlink
+	isShared := mode == config.RunLockShared && syncMode == config.SyncModeSymlink
+
+	runLockFileAbsPath, err := config.RunLockFileAbsPath(workspaceRootPath)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get absolute path of run lock: %s", err)
+	}
+
+	lock, isLocked, err := config.TryLockFile(runLockFileAbsPath, isShared)
+	if err != nil {
+		return nil, err
+	}
+	if !isLocked {
+		holdersDescription := describeRunLockHolders(workspaceRootPath)
+		if mode == config.RunLockFailFast {
+			return nil, fmt.Errorf("The workspace (%s) is in use by another gows command: %s", workspaceRootPath, holdersDescription)
+		}
+
+		log.Warningf("The workspace is in use by another gows command: %s", holdersDescription)
+		log.Warningf("Waiting for the workspace to be released (timeout: %s) ...", timeout)
+
+		deadline := time.Now().Add(timeout)
+		for !isLocked {
+			if time.Now().After(deadline) {
+				return nil, fmt.Errorf("Timed out waiting for the workspace (%s) to be released (timeout: %s), it's in use by another gows command: %s",
+					workspaceRootPath, timeout, describeRunLockHolders(workspaceRootPath))
+			}
+			time.Sleep(runLockPollInterval)
+
+			lock, isLocked, err = config.TryLockFile(runLockFileAbsPath, isShared)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	holder := config.RunLockHolderModel{
+		PID:         os.Getpid(),
+		Command:     command,
+		ProjectPath: projectPath,
+		SyncMode:    userConfig.SyncMode,
+		IsShared:    isShared,
+		AcquiredAt:  time.Now(),
+	}
+	if err := config.SaveRunLockHolder(workspaceRootPath, holder); err != nil {
+		log.Warningf("Failed to record the holder of the workspace's run lock: %s", err)
+	}
+
+	return &runLock{lock: lock, workspaceRootPath: workspaceRootPath}, nil
+}
+
+func (lock *runLock) release() {
+	if err := config.RemoveRunLockHolder(lock.workspaceRootPath, os.Getpid()); err != nil {
+		log.Warningf("Failed to remove the holder record of the workspace's run lock: %s", err)
+	}
+	if err := lock.lock.Unlock(); err != nil {
+		log.Errorf("Failed to release the run lock of the workspace: %s", err)
+	}
+}
+
+// describeRunLockHolders returns the PID and the command of the processes holding the workspace's run lock
+func describeRunLockHolders(workspaceRootPath string) string {
+	holders, err := config.LoadRunLockHolders(workspaceRootPath)
+	if err != nil {
+		log.Debugf("Failed to read the holders of the workspace's run lock: %s", err)
+	}
+
+	descriptions := []string{}
+	for _, holder := range holders {
+		if !isProcessRunning(holder.PID) {
+			continue
+		}
+		descriptions = append(descriptions, fmt.Sprintf("pid: %d, command: %s (%s mode, since %s)",
+			holder.PID, strings.Join(holder.Command, " "), holder.SyncMode, holder.AcquiredAt.Format(time.RFC3339)))
+	}
+	if len(descriptions) == 0 {
+		return "unknown process"
+	}
+	return strings.Join(descriptions, "; ")
+}
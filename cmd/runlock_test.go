@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/gows/config"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_acquireRunLock(t *testing.T) {
+	homeDir, err := ioutil.TempDir("", "gows-runlock-home-")
+	require.NoError(t, err)
+	origHome := os.Getenv("HOME")
+	require.NoError(t, os.Setenv("HOME", homeDir))
+	defer func() {
+		require.NoError(t, os.Setenv("HOME", origHome))
+		require.NoError(t, os.RemoveAll(homeDir))
+	}()
+
+	wsRootPath := "/ws/root"
+	copyModeConfig := config.UserConfigModel{SyncMode: config.SyncModeCopy, RunLock: config.RunLockFailFast}
+	symlinkModeConfig := config.UserConfigModel{SyncMode: config.SyncModeSymlink, RunLock: config.RunLockShared}
+
+	t.Log("Copy mode - fail fast, the error names the holder")
+	{
+		lock, err := acquireRunLock(copyModeConfig, wsRootPath, "/proj", []string{"go", "test"})
+		require.NoError(t, err)
+
+		_, err = acquireRunLock(copyModeConfig, wsRootPath, "/proj", []string{"go", "build"})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), fmt.Sprintf("pid: %d, command: go test", os.Getpid()))
+
+		t.Log("a symlink mode command can't share the workspace with a copy mode one")
+		{
+			_, err := acquireRunLock(config.UserConfigModel{SyncMode: config.SyncModeSymlink, RunLock: config.RunLockFailFast}, wsRootPath, "/proj", []string{"go", "build"})
+			require.Error(t, err)
+		}
+
+		t.Log("an other workspace can be used")
+		{
+			otherLock, err := acquireRunLock(copyModeConfig, "/ws/other", "/proj/other", []string{"go", "build"})
+			require.NoError(t, err)
+			otherLock.release()
+		}
+
+		lock.release()
+
+		lock, err = acquireRunLock(copyModeConfig, wsRootPath, "/proj", []string{"go", "build"})
+		require.NoError(t, err)
+		lock.release()
+	}
+
+	t.Log("Symlink mode - shared")
+	{
+		lock1, err := acquireRunLock(symlinkModeConfig, wsRootPath, "/proj", []string{"go", "test"})
+		require.NoError(t, err)
+		lock2, err := acquireRunLock(symlinkModeConfig, wsRootPath, "/proj", []string{"go", "build"})
+		require.NoError(t, err)
+
+		_, err = acquireRunLock(copyModeConfig, wsRootPath, "/proj", []string{"go", "build"})
+		require.Error(t, err)
+
+		lock1.release()
+		lock2.release()
+	}
+
+	t.Log("Wait - times out")
+	{
+		lock, err := acquireRunLock(copyModeConfig, wsRootPath, "/proj", []string{"go", "test"})
+		require.NoError(t, err)
+
+		startTime := time.Now()
+		_, err = acquireRunLock(config.UserConfigModel{SyncMode: config.SyncModeCopy, RunLock: config.RunLockWait, RunLockTimeout: 1}, wsRootPath, "/proj", []string{"go", "build"})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "Timed out")
+		require.True(t, time.Since(startTime) >= time.Second)
+
+		lock.release()
+	}
+
+	t.Log("Wait - acquired once released")
+	{
+		lock, err := acquireRunLock(copyModeConfig, wsRootPath, "/proj", []string{"go", "test"})
+		require.NoError(t, err)
+		go func() {
+			time.Sleep(500 * time.Millisecond)
+			lock.release()
+		}()
+
+		waitingLock, err := acquireRunLock(config.UserConfigModel{SyncMode: config.SyncModeCopy, RunLock: config.RunLockWait, RunLockTimeout: 10}, wsRootPath, "/proj", []string{"go", "build"})
+		require.NoError(t, err)
+		waitingLock.release()
+	}
+
+	t.Log("Unsupported run lock mode")
+	{
+		_, err := acquireRunLock(config.UserConfigModel{SyncMode: config.SyncModeCopy, RunLock: "invalid"}, wsRootPath, "/proj", []string{"go", "build"})
+		require.Error(t, err)
+	}
+}
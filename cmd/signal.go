@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var forwardedSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP}
+
+// signalTrap catches the termination signals, so that gows is not killed
+// before the command and the finishing phase (e.g. the copy mode sync back) finish,
+// and forwards them to the running command.
+type signalTrap struct {
+	sigCh chan os.Signal
+	done  chan struct{}
+
+	mu                sync.Mutex
+	process           *os.Process
+	isOwnProcessGroup bool
+	received          os.Signal
+}
+
+func trapSignals() *signalTrap {
+	trap := &signalTrap{
+		sigCh: make(chan os.Signal, 1),
+		done:  make(chan struct{}),
+	}
+	signal.Notify(trap.sigCh, forwardedSignals...)
+
+	go func() {
+		defer close(trap.done)
+		for sig := range trap.sigCh {
+			trap.mu.Lock()
+			if trap.received == nil {
+				trap.received = sig
+			}
+			if trap.process != nil {
+				log.Debugf("[signalTrap] Forwarding signal (%s) to the command", sig)
+				trap.forward(sig)
+			} else {
+				log.Warningf("Signal (%s) received, finishing before exit ...", sig)
+			}
+			trap.mu.Unlock()
+		}
+	}()
+
+	return trap
+}
+
+// setCommandProcess sets the process the signals should be forwarded to,
+// signals received before the process was started are forwarded immediately
+func (trap *signalTrap) setCommandProcess(process *os.Process, isOwnProcessGroup bool) {
+	trap.mu.Lock()
+	defer trap.mu.Unlock()
+
+	trap.process = process
+	trap.isOwnProcessGroup = isOwnProcessGroup
+	if process != nil && trap.received != nil {
+		trap.forward(trap.received)
+	}
+}
+
+// forward - the caller has to hold the lock
+func (trap *signalTrap) forward(sig os.Signal) {
+	sysSig, ok := sig.(syscall.Signal)
+	if !ok {
+		return
+	}
+
+	if trap.isOwnProcessGroup {
+		// forward to the command's whole process group
+		if err := signalProcessGroup(trap.process.Pid, sysSig); err != nil {
+			log.Debugf("[signalTrap] Failed to forward signal (%s), error: %s", sig, err)
+		}
+		return
+	}
+
+	// The command is in gows's (foreground) process group, signals generated
+	// by the terminal (e.g. Ctrl+C - SIGINT) are delivered to the command directly.
+	if sysSig == syscall.SIGINT {
+		return
+	}
+	if err := trap.process.Signal(sysSig); err != nil {
+		log.Debugf("[signalTrap] Failed to forward signal (%s), error: %s", sig, err)
+	}
+}
+
+// receivedSignal returns the first signal received
+func (trap *signalTrap) receivedSignal() (syscall.Signal, bool) {
+	trap.mu.Lock()
+	defer trap.mu.Unlock()
+
+	sysSig, ok := trap.received.(syscall.Signal)
+	return sysSig, ok
+}
+
+func (trap *signalTrap) stop() {
+	signal.Stop(trap.sigCh)
+	close(trap.sigCh)
+	<-trap.done
+}
+
+// signalExitCode returns the conventional exit code of a process terminated by a signal
+func signalExitCode(sig syscall.Signal) int {
+	return 128 + int(sig)
+}
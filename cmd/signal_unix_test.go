@@ -0,0 +1,49 @@
+//go:build !windows
+// +build !windows
+
+package cmd
+
+import (
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/gows/gows"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_exitCodeOfCommandError(t *testing.T) {
+	t.Log("Exit code")
+	{
+		exitCode, err := exitCodeOfCommandError(exec.Command("sh", "-c", "exit 3").Run())
+		require.Error(t, err)
+		require.Equal(t, 3, exitCode)
+	}
+
+	t.Log("Terminated by a signal")
+	{
+		exitCode, err := exitCodeOfCommandError(exec.Command("sh", "-c", "kill -TERM $$").Run())
+		require.Error(t, err)
+		require.Equal(t, 128+int(syscall.SIGTERM), exitCode)
+	}
+}
+
+func Test_runCommand_ForwardSignal(t *testing.T) {
+	signalTrap := trapSignals()
+	defer signalTrap.stop()
+
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		// gows (the test process) is terminated, the signal is caught and forwarded to the command
+		_ = syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+	}()
+
+	exitCode, err := runCommand(signalTrap, gows.CreateCommand(".", "", "sleep", "10"))
+	require.Error(t, err)
+	require.Equal(t, 128+int(syscall.SIGTERM), exitCode)
+
+	sig, isReceived := signalTrap.receivedSignal()
+	require.Equal(t, true, isReceived)
+	require.Equal(t, syscall.SIGTERM, sig)
+}
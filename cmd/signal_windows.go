@@ -0,0 +1,21 @@
+//go:build windows
+// +build windows
+
+package cmd
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// startInOwnProcessGroup configures the command to be started in its own process group,
+// returns false if process groups are not supported.
+// Windows process groups can't be signaled, the signals are forwarded to the command's process only.
+func startInOwnProcessGroup(cmd *exec.Cmd) bool {
+	return false
+}
+
+// signalProcessGroup sends the signal to every process of the process group
+func signalProcessGroup(pgid int, sig syscall.Signal) error {
+	return syscall.EWINDOWS
+}
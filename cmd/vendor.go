@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/colorstring"
+	"github.com/bitrise-io/gows/config"
+	"github.com/bitrise-io/gows/dirsync"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/viktorbenei/cobra.v0"
+)
+
+var (
+	isVendorCheckFix = false
+)
+
+// vendorCmd represents the vendor command
+var vendorCmd = &cobra.Command{
+	Use:   "vendor",
+	Short: "Manage the project's vendor/ directory",
+}
+
+// vendorCheckCmd represents the vendor check command
+var vendorCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Find the imports resolved from the workspace instead of vendor/",
+	Long: `Find the imports resolved from the workspace instead of vendor/.
+
+The project's (transitive) imports, including the imports of the tests, are listed
+with go list, run inside the prepared workspace. Every non standard library package
+which is resolved from the workspace's src directory (e.g. fetched with go get)
+instead of the project's vendor/ directory is reported, just like the imports
+which can't be found at all.
+Exits with an error if any is found, so it can be used on CI.
+
+With --fix the packages resolved from the workspace are copied into vendor/.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		projectDir, _, err := currentProjectDir()
+		if err != nil {
+			log.Info("Run " + colorstring.Green("gows init") + " to initialize a workspace & gows config for this project")
+			return err
+		}
+		projectConfig, err := config.LoadProjectConfigFromDir(projectDir)
+		if err != nil {
+			return fmt.Errorf("Failed to read Project Config: %s", err)
+		}
+		wsConfig, err := workspaceForProject(projectDir)
+		if err != nil {
+			return err
+		}
+
+		goListOutput := bytes.Buffer{}
+		runOpts := runOptions{
+			WorkDir: ".",
+			// vendor/ is only used in GOPATH mode
+			Env:    map[string]string{"GO111MODULE": "off"},
+			Stdout: &goListOutput,
+		}
+		exitCode, err := prepareEnvironmentAndRunCommand(loadUserConfig(projectDir), runOpts, "go", "list", "-e", "-deps", "-test", "-json", "./...")
+		if err != nil {
+			return fmt.Errorf("Failed to list the imports of the project, error: %s", err)
+		}
+		if exitCode != 0 {
+			return fmt.Errorf("Failed to list the imports of the project, go list exited with code: %d", exitCode)
+		}
+
+		packages, err := parseGoListPackages(&goListOutput)
+		if err != nil {
+			return err
+		}
+		// the project's package, and its aliases
+		projectWorkspacePaths := []string{}
+		for _, importPath := range projectImportPaths(projectConfig) {
+			projectWorkspacePaths = append(projectWorkspacePaths, filepath.Join(wsConfig.WorkspaceRootPath, "src", filepath.FromSlash(importPath)))
+		}
+		unvendored, notFound := findUnvendoredPackages(packages, filepath.Join(wsConfig.WorkspaceRootPath, "src"), projectWorkspacePaths)
+
+		if len(unvendored) == 0 && len(notFound) == 0 {
+			log.Info("Every import is resolved from vendor/ or from the standard library")
+			return nil
+		}
+
+		if len(unvendored) > 0 {
+			log.Warningf("%d packages are resolved from the workspace instead of vendor/:", len(unvendored))
+			for _, pkg := range unvendored {
+				fmt.Printf(" * %s (%s)\n", pkg.ImportPath, pkg.Dir)
+			}
+		}
+		if len(notFound) > 0 {
+			log.Warningf("%d packages are not found:", len(notFound))
+			for _, importPath := range notFound {
+				fmt.Printf(" * %s\n", importPath)
+			}
+		}
+
+		if isVendorCheckFix && len(unvendored) > 0 {
+			vendorDir := filepath.Join(projectDir, "vendor")
+			for _, pkg := range unvendored {
+				if err := copyPackageIntoVendor(pkg, vendorDir); err != nil {
+					return err
+				}
+				log.Infof(" + vendor/%s", pkg.ImportPath)
+			}
+			log.Infof("%d packages copied into vendor/", len(unvendored))
+			unvendored = []goListPackageModel{}
+		} else if len(unvendored) > 0 {
+			log.Info("Run " + colorstring.Green("gows vendor check --fix") + " to copy them into vendor/")
+		}
+
+		if len(unvendored) > 0 || len(notFound) > 0 {
+			return errors.New("Not every import is vendored")
+		}
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(vendorCmd)
+	vendorCmd.AddCommand(vendorCheckCmd)
+	vendorCheckCmd.Flags().BoolVarP(&isVendorCheckFix, "fix", "", false, "Copy the packages resolved from the workspace into vendor/")
+}
+
+// goListPackageModel - the fields of go list -json used by gows vendor check
+type goListPackageModel struct {
+	ImportPath string
+	Dir        string
+	Standard   bool
+	Error      *struct {
+		Err string
+	}
+}
+
+// parseGoListPackages parses the (concatenated JSON) output of go list -json
+func parseGoListPackages(goListOutput io.Reader) ([]goListPackageModel, error) {
+	packages := []goListPackageModel{}
+	decoder := json.NewDecoder(goListOutput)
+	for {
+		pkg := goListPackageModel{}
+		if err := decoder.Decode(&pkg); err == io.EOF {
+			break
+		} else if err != nil {
+			return []goListPackageModel{}, fmt.Errorf("Failed to parse the output of go list, error: %s", err)
+		}
+		packages = append(packages, pkg)
+	}
+	return packages, nil
+}
+
+// findUnvendoredPackages returns the packages resolved from the workspace's src directory,
+// outside of the project's paths (and their vendor/ directory), and the import paths which can't be found.
+// Both are sorted by import path, the test variants of the packages are listed only once.
+func findUnvendoredPackages(packages []goListPackageModel, srcDir string, projectWorkspacePaths []string) ([]goListPackageModel, []string) {
+	unvendoredByImportPath := map[string]goListPackageModel{}
+	notFoundImportPaths := map[string]bool{}
+	for _, pkg := range packages {
+		if pkg.Standard {
+			continue
+		}
+		// the test variant of a package, e.g.: github.com/org/lib [github.com/org/project.test]
+		importPath := strings.SplitN(pkg.ImportPath, " ", 2)[0]
+
+		if pkg.Dir == "" {
+			if pkg.Error != nil {
+				notFoundImportPaths[importPath] = true
+			}
+			continue
+		}
+		if isPathInAnyDir(pkg.Dir, projectWorkspacePaths) {
+			// the project's own package, or a vendored one
+			continue
+		}
+		if isPathInDir(pkg.Dir, srcDir) {
+			unvendoredByImportPath[importPath] = goListPackageModel{ImportPath: importPath, Dir: pkg.Dir}
+		}
+	}
+
+	unvendored := []goListPackageModel{}
+	for _, pkg := range unvendoredByImportPath {
+		unvendored = append(unvendored, pkg)
+	}
+	sort.Slice(unvendored, func(i, j int) bool {
+		return unvendored[i].ImportPath < unvendored[j].ImportPath
+	})
+	notFound := []string{}
+	for importPath := range notFoundImportPaths {
+		notFound = append(notFound, importPath)
+	}
+	sort.Strings(notFound)
+	return unvendored, notFound
+}
+
+// isPathInAnyDir returns true if the path is one of the dirs, or it is inside one of them
+func isPathInAnyDir(pth string, dirs []string) bool {
+	for _, dir := range dirs {
+		if pth == dir || isPathInDir(pth, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// copyPackageIntoVendor copies the files of the package (without its sub packages) into vendor/<import path>
+func copyPackageIntoVendor(pkg goListPackageModel, vendorDir string) error {
+	if err := validateDependencyImportPath(pkg.ImportPath); err != nil {
+		return err
+	}
+	fileInfos, err := ioutil.ReadDir(pkg.Dir)
+	if err != nil {
+		return fmt.Errorf("Failed to list the files of (%s), error: %s", pkg.Dir, err)
+	}
+	for _, fileInfo := range fileInfos {
+		if fileInfo.IsDir() {
+			continue
+		}
+		if err := dirsync.CopyPath(filepath.Join(pkg.Dir, fileInfo.Name()), filepath.Join(vendorDir, filepath.FromSlash(pkg.ImportPath), fileInfo.Name())); err != nil {
+			return fmt.Errorf("Failed to copy %s into vendor/, error: %s", pkg.ImportPath, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_findUnvendoredPackages(t *testing.T) {
+	goListOutput := `{
+	"Dir": "/usr/local/go/src/fmt",
+	"ImportPath": "fmt",
+	"Standard": true
+}
+{
+	"Dir": "/ws/src/github.com/org/project/vendor/github.com/org/vendored",
+	"ImportPath": "github.com/org/project/vendor/github.com/org/vendored"
+}
+{
+	"Dir": "/ws/src/github.com/org/lib",
+	"ImportPath": "github.com/org/lib"
+}
+{
+	"ImportPath": "github.com/org/missing",
+	"Error": {
+		"Err": "cannot find package \"github.com/org/missing\""
+	}
+}
+{
+	"Dir": "/ws/src/github.com/org/project",
+	"ImportPath": "github.com/org/project"
+}
+{
+	"Dir": "/ws/src/github.com/org/project/sub",
+	"ImportPath": "github.com/org/project/sub"
+}
+{
+	"Dir": "/ws/src/github.com/org/lib",
+	"ImportPath": "github.com/org/lib [github.com/org/project.test]"
+}
+{
+	"Dir": "/ws/src/github.com/a/testlib",
+	"ImportPath": "github.com/a/testlib"
+}
+{
+	"Dir": "/ws/src/github.com/org/project",
+	"ImportPath": "github.com/org/project.test"
+}
+{
+	"Dir": "/ws/src/github.com/upstream/project/internal",
+	"ImportPath": "github.com/upstream/project/internal"
+}
+`
+	packages, err := parseGoListPackages(strings.NewReader(goListOutput))
+	require.NoError(t, err)
+	require.Equal(t, 10, len(packages))
+
+	unvendored, notFound := findUnvendoredPackages(packages, "/ws/src", []string{"/ws/src/github.com/org/project", "/ws/src/github.com/upstream/project"})
+	require.Equal(t, []goListPackageModel{
+		{ImportPath: "github.com/a/testlib", Dir: "/ws/src/github.com/a/testlib"},
+		{ImportPath: "github.com/org/lib", Dir: "/ws/src/github.com/org/lib"},
+	}, unvendored)
+	require.Equal(t, []string{"github.com/org/missing"}, notFound)
+
+	_, err = parseGoListPackages(strings.NewReader(`{"ImportPath": `))
+	require.Error(t, err)
+}
+
+func Test_copyPackageIntoVendor(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gows-vendor-")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(tmpDir))
+	}()
+
+	pkgDir := filepath.Join(tmpDir, "src", "github.com", "org", "lib")
+	require.NoError(t, os.MkdirAll(filepath.Join(pkgDir, "sub"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(pkgDir, "lib.go"), []byte("package lib"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(pkgDir, "sub", "sub.go"), []byte("package sub"), 0644))
+	vendorDir := filepath.Join(tmpDir, "project", "vendor")
+
+	require.NoError(t, copyPackageIntoVendor(goListPackageModel{ImportPath: "github.com/org/lib", Dir: pkgDir}, vendorDir))
+
+	content, err := ioutil.ReadFile(filepath.Join(vendorDir, "github.com", "org", "lib", "lib.go"))
+	require.NoError(t, err)
+	require.Equal(t, "package lib", string(content))
+	// sub packages are vendored only if they are imported
+	isExists, err := pathExists(filepath.Join(vendorDir, "github.com", "org", "lib", "sub"))
+	require.NoError(t, err)
+	require.Equal(t, false, isExists)
+
+	require.EqualError(t, copyPackageIntoVendor(goListPackageModel{ImportPath: "../lib", Dir: pkgDir}, vendorDir), "Invalid import path: ../lib")
+}
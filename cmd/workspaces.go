@@ -1,44 +1,111 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/bitrise-io/go-utils/colorstring"
 	"github.com/bitrise-io/gows/config"
 	"gopkg.in/viktorbenei/cobra.v0"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	workspacesFormatList     = "list"
+	workspacesFormatTable    = "table"
+	workspacesFormatJSON     = "json"
+	workspacesFormatYAML     = "yaml"
+	workspacesFormatTemplate = "template"
+
+	workspaceHealthOK               = "ok"
+	workspaceHealthProjectMissing   = "project-missing"
+	workspaceHealthWorkspaceMissing = "workspace-missing"
+	workspaceHealthShared           = "shared"
+)
+
+var (
+	workspacesFormat    = workspacesFormatList
+	workspacesTemplate  = ""
+	isWorkspacesStale   = false
+	isWorkspacesCurrent = false
+	workspacesPackage   = ""
+	workspacesOlderThan = "30d"
 )
 
 // workspacesCmd represents the workspaces command
 var workspacesCmd = &cobra.Command{
-	Use:           "workspaces",
-	Short:         "List registered gows projects -> workspaces path pairs",
-	Long:          ``,
+	Use:   "workspaces",
+	Short: "List registered gows projects -> workspaces path pairs",
+	Long: `List registered gows projects -> workspaces path pairs, sorted by the project path.
+
+Output formats (--format):
+
+  list      the project -> workspace path pairs (default)
+  table     project, package, workspace, size, health and last used time
+  json      every detail, for tools
+  yaml      every detail, for tools
+  template  every workspace rendered with the Go text/template specified with --template, e.g.:
+            gows workspaces --format template --template '{{.ProjectPath}} {{size .Size}}'
+
+Health: ok, project-missing (the project's directory no longer exists),
+workspace-missing (the workspace's directory no longer exists) or shared
+(the workspace is registered for more than one project).`,
 	SilenceUsage:  true,
 	SilenceErrors: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if workspacesTemplate != "" && (workspacesFormat == workspacesFormatList || workspacesFormat == workspacesFormatTemplate) {
+			workspacesFormat = workspacesFormatTemplate
+		} else if workspacesFormat == workspacesFormatTemplate {
+			return errors.New("No template specified (with --template)")
+		}
+
+		staleAge, err := parseAge(workspacesOlderThan)
+		if err != nil {
+			return err
+		}
+
 		gowsConfig, err := config.LoadGOWSConfigFromFile()
 		if err != nil {
 			return fmt.Errorf("Failed to load gows config: %s", err)
 		}
 
-		currWorkDir, err := os.Getwd()
+		currProjectDir, _, err := currentProjectDir()
 		if err != nil {
-			log.Debugf("Failed to get current working directory: %s", err)
+			log.Debugf("Failed to find the project's root directory: %s", err)
+			if isWorkspacesCurrent {
+				return err
+			}
 		}
 
-		fmt.Println()
-		fmt.Println("=== Registered gows [project -> workspace] path list ===")
-		for projectPath, wsConfig := range gowsConfig.Workspaces {
-			if projectPath == currWorkDir {
-				fmt.Println(colorstring.Greenf(" * %s -> %s", projectPath, wsConfig.WorkspaceRootPath))
-			} else {
-				fmt.Printf(" * %s -> %s\n", projectPath, wsConfig.WorkspaceRootPath)
-			}
+		items, err := workspaceListItems(gowsConfig, currProjectDir, workspacesFormat != workspacesFormatList)
+		if err != nil {
+			return err
 		}
-		fmt.Println("========================================================")
-		fmt.Println()
+		items, err = filterWorkspaceListItems(items, workspaceListFilter{
+			IsCurrentOnly:  isWorkspacesCurrent,
+			IsStaleOnly:    isWorkspacesStale,
+			StaleAge:       staleAge,
+			PackagePattern: workspacesPackage,
+		}, time.Now())
+		if err != nil {
+			return err
+		}
+
+		out, err := formatWorkspaceListItems(items, workspacesFormat, workspacesTemplate)
+		if err != nil {
+			return err
+		}
+		fmt.Print(out)
 
 		return nil
 	},
@@ -46,4 +113,183 @@ var workspacesCmd = &cobra.Command{
 
 func init() {
 	RootCmd.AddCommand(workspacesCmd)
+	workspacesCmd.Flags().StringVarP(&workspacesFormat, "format", "", workspacesFormatList, "Output format (options: list, table, json, yaml, template)")
+	workspacesCmd.Flags().StringVarP(&workspacesTemplate, "template", "", "", "Go text/template to render every workspace with (for --format template)")
+	workspacesCmd.Flags().BoolVarP(&isWorkspacesStale, "stale", "", false, "Only list the workspaces which are not healthy, or not used for longer than --older-than")
+	workspacesCmd.Flags().StringVarP(&workspacesOlderThan, "older-than", "", "30d", "Workspaces not used for longer than this are stale (e.g. 720h or 30d)")
+	workspacesCmd.Flags().BoolVarP(&isWorkspacesCurrent, "current", "", false, "Only list the workspace of the current project")
+	workspacesCmd.Flags().StringVarP(&workspacesPackage, "package", "", "", "Only list the workspaces of the packages matching the glob pattern (e.g. 'github.com/org/*')")
+}
+
+// workspaceListItemModel - the details of a registered workspace
+type workspaceListItemModel struct {
+	ProjectPath       string     `json:"project_path" yaml:"project_path"`
+	WorkspaceRootPath string     `json:"workspace_root_path" yaml:"workspace_root_path"`
+	PackageName       string     `json:"package_name" yaml:"package_name"`
+	Size              int64      `json:"size" yaml:"size"`
+	Health            string     `json:"health" yaml:"health"`
+	IsCurrent         bool       `json:"is_current" yaml:"is_current"`
+	CreatedAt         *time.Time `json:"created_at,omitempty" yaml:"created_at,omitempty"`
+	LastUsedAt        *time.Time `json:"last_used_at,omitempty" yaml:"last_used_at,omitempty"`
+	SyncMode          string     `json:"sync_mode,omitempty" yaml:"sync_mode,omitempty"`
+	LastExitCode      *int       `json:"last_exit_code,omitempty" yaml:"last_exit_code,omitempty"`
+	RunCount          int        `json:"run_count" yaml:"run_count"`
+}
+
+// workspaceListItems collects the details of the registered workspaces, sorted by the project path.
+// The size of the workspaces is only calculated if isWithSize is true.
+func workspaceListItems(gowsConfig config.GOWSConfigModel, currProjectDir string, isWithSize bool) ([]workspaceListItemModel, error) {
+	projectPaths := []string{}
+	for projectPath := range gowsConfig.Workspaces {
+		projectPaths = append(projectPaths, projectPath)
+	}
+	sort.Strings(projectPaths)
+
+	items := []workspaceListItemModel{}
+	for _, projectPath := range projectPaths {
+		wsConfig := gowsConfig.Workspaces[projectPath]
+		item := workspaceListItemModel{
+			ProjectPath:       projectPath,
+			WorkspaceRootPath: wsConfig.WorkspaceRootPath,
+			PackageName:       wsConfig.PackageName,
+			Health:            workspaceHealthOK,
+			IsCurrent:         projectPath == currProjectDir,
+			SyncMode:          wsConfig.SyncMode,
+			LastExitCode:      wsConfig.LastExitCode,
+			RunCount:          wsConfig.RunCount,
+		}
+
+		// the package name in the project's config is the current one
+		if projectConfig, err := config.LoadProjectConfigFromDir(projectPath); err == nil && projectConfig.PackageName != "" {
+			item.PackageName = projectConfig.PackageName
+		}
+
+		if !wsConfig.CreatedAt.IsZero() {
+			createdAt := wsConfig.CreatedAt
+			item.CreatedAt = &createdAt
+		}
+		lastUsedAt, err := workspaceLastUsedAt(wsConfig)
+		if err != nil {
+			return []workspaceListItemModel{}, err
+		}
+		if !lastUsedAt.IsZero() {
+			item.LastUsedAt = &lastUsedAt
+		}
+
+		if _, err := os.Stat(projectPath); os.IsNotExist(err) {
+			item.Health = workspaceHealthProjectMissing
+		} else if _, err := os.Stat(wsConfig.WorkspaceRootPath); os.IsNotExist(err) {
+			item.Health = workspaceHealthWorkspaceMissing
+		} else if len(gowsConfig.ProjectsOfWorkspace(wsConfig.WorkspaceRootPath)) > 1 {
+			item.Health = workspaceHealthShared
+		}
+
+		if isWithSize {
+			size, err := dirSize(wsConfig.WorkspaceRootPath)
+			if err != nil {
+				return []workspaceListItemModel{}, err
+			}
+			item.Size = size
+		}
+
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// workspaceListFilter - the filters of gows workspaces
+type workspaceListFilter struct {
+	IsCurrentOnly bool
+	// IsStaleOnly - only the workspaces which are not healthy, or not used for longer than StaleAge
+	IsStaleOnly bool
+	StaleAge    time.Duration
+	// PackagePattern - path.Match pattern of the package names
+	PackagePattern string
+}
+
+func filterWorkspaceListItems(items []workspaceListItemModel, filter workspaceListFilter, now time.Time) ([]workspaceListItemModel, error) {
+	filteredItems := []workspaceListItemModel{}
+	for _, item := range items {
+		if filter.IsCurrentOnly && !item.IsCurrent {
+			continue
+		}
+		if filter.IsStaleOnly {
+			isUnused := item.LastUsedAt != nil && now.Sub(*item.LastUsedAt) > filter.StaleAge
+			if item.Health == workspaceHealthOK && !isUnused {
+				continue
+			}
+		}
+		if filter.PackagePattern != "" {
+			isMatch, err := path.Match(filter.PackagePattern, item.PackageName)
+			if err != nil {
+				return []workspaceListItemModel{}, fmt.Errorf("Invalid package pattern (%s), error: %s", filter.PackagePattern, err)
+			}
+			if !isMatch {
+				continue
+			}
+		}
+		filteredItems = append(filteredItems, item)
+	}
+	return filteredItems, nil
+}
+
+func formatWorkspaceListItems(items []workspaceListItemModel, format, templateStr string) (string, error) {
+	switch format {
+	case workspacesFormatList:
+		lines := []string{"", "=== Registered gows [project -> workspace] path list ==="}
+		for _, item := range items {
+			if item.IsCurrent {
+				lines = append(lines, colorstring.Greenf(" * %s -> %s", item.ProjectPath, item.WorkspaceRootPath))
+			} else {
+				lines = append(lines, fmt.Sprintf(" * %s -> %s", item.ProjectPath, item.WorkspaceRootPath))
+			}
+		}
+		lines = append(lines, "========================================================", "")
+		return strings.Join(lines, "\n") + "\n", nil
+	case workspacesFormatTable:
+		var buffer bytes.Buffer
+		writer := tabwriter.NewWriter(&buffer, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "PROJECT\tPACKAGE\tWORKSPACE\tSIZE\tHEALTH\tLAST USED")
+		for _, item := range items {
+			lastUsed := "-"
+			if item.LastUsedAt != nil {
+				lastUsed = item.LastUsedAt.Format("2006-01-02 15:04")
+			}
+			packageName := item.PackageName
+			if packageName == "" {
+				packageName = "-"
+			}
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n", item.ProjectPath, packageName, item.WorkspaceRootPath, formatSize(item.Size), item.Health, lastUsed)
+		}
+		if err := writer.Flush(); err != nil {
+			return "", err
+		}
+		return buffer.String(), nil
+	case workspacesFormatJSON:
+		bytes, err := json.MarshalIndent(items, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("Failed to generate JSON: %s", err)
+		}
+		return string(bytes) + "\n", nil
+	case workspacesFormatYAML:
+		bytes, err := yaml.Marshal(items)
+		if err != nil {
+			return "", fmt.Errorf("Failed to generate YML: %s", err)
+		}
+		return string(bytes), nil
+	case workspacesFormatTemplate:
+		tmpl, err := template.New("workspace").Funcs(template.FuncMap{"size": formatSize}).Parse(templateStr)
+		if err != nil {
+			return "", fmt.Errorf("Invalid template (%s), error: %s", templateStr, err)
+		}
+		var buffer bytes.Buffer
+		for _, item := range items {
+			if err := tmpl.Execute(&buffer, item); err != nil {
+				return "", fmt.Errorf("Failed to render template (%s), error: %s", templateStr, err)
+			}
+			buffer.WriteString("\n")
+		}
+		return buffer.String(), nil
+	}
+	return "", fmt.Errorf("Unsupported format: %s (options: %s, %s, %s, %s, %s)", format, workspacesFormatList, workspacesFormatTable, workspacesFormatJSON, workspacesFormatYAML, workspacesFormatTemplate)
 }
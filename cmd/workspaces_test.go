@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/gows/config"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_workspaceListItems(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gows-workspaces-")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(tmpDir))
+	}()
+	tmpDir, err = filepath.EvalSymlinks(tmpDir)
+	require.NoError(t, err)
+
+	createDir := func(pth string) string {
+		require.NoError(t, os.MkdirAll(pth, 0755))
+		return pth
+	}
+
+	now := time.Now()
+	projectA := createDir(filepath.Join(tmpDir, "projects", "a"))
+	require.NoError(t, config.SaveProjectConfigToDir(projectA, config.ProjectConfigModel{PackageName: "github.com/org/a"}))
+	wsA := createDir(filepath.Join(tmpDir, "wsdirs", "a"))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(wsA, "file"), []byte("12345"), 0644))
+	require.NoError(t, os.Chtimes(wsA, now, now))
+
+	projectB := createDir(filepath.Join(tmpDir, "projects", "b"))
+	wsB := createDir(filepath.Join(tmpDir, "wsdirs", "b"))
+	oldTime := now.Add(-40 * 24 * time.Hour)
+	require.NoError(t, os.Chtimes(wsB, oldTime, oldTime))
+
+	projectDeleted := filepath.Join(tmpDir, "projects", "deleted")
+	projectNoWs := createDir(filepath.Join(tmpDir, "projects", "no-ws"))
+
+	gowsConfig := config.GOWSConfigModel{
+		Workspaces: map[string]config.WorkspaceConfigModel{
+			projectNoWs:    {WorkspaceRootPath: filepath.Join(tmpDir, "wsdirs", "missing")},
+			projectDeleted: {WorkspaceRootPath: wsA, PackageName: "github.com/org/deleted"},
+			projectB:       {WorkspaceRootPath: wsB, PackageName: "github.com/other/b", RunCount: 2},
+			projectA:       {WorkspaceRootPath: wsA, PackageName: "github.com/org/old-a"},
+		},
+	}
+
+	items, err := workspaceListItems(gowsConfig, projectB, true)
+	require.NoError(t, err)
+
+	t.Log("Sorted by the project path, with the details")
+	{
+		require.Equal(t, 4, len(items))
+		require.Equal(t, []string{projectA, projectB, projectDeleted, projectNoWs},
+			[]string{items[0].ProjectPath, items[1].ProjectPath, items[2].ProjectPath, items[3].ProjectPath})
+
+		// the package name of the project's config overrides the registered one
+		require.Equal(t, "github.com/org/a", items[0].PackageName)
+		require.Equal(t, workspaceHealthShared, items[0].Health)
+		require.Equal(t, int64(5), items[0].Size)
+
+		require.Equal(t, true, items[1].IsCurrent)
+		require.Equal(t, workspaceHealthOK, items[1].Health)
+		require.Equal(t, 2, items[1].RunCount)
+		require.Equal(t, oldTime.Unix(), items[1].LastUsedAt.Unix())
+
+		require.Equal(t, workspaceHealthProjectMissing, items[2].Health)
+		require.Equal(t, workspaceHealthWorkspaceMissing, items[3].Health)
+		require.Nil(t, items[3].LastUsedAt)
+	}
+
+	t.Log("Filters")
+	{
+		filtered, err := filterWorkspaceListItems(items, workspaceListFilter{IsCurrentOnly: true}, now)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(filtered))
+		require.Equal(t, projectB, filtered[0].ProjectPath)
+
+		filtered, err = filterWorkspaceListItems(items, workspaceListFilter{PackagePattern: "github.com/org/*"}, now)
+		require.NoError(t, err)
+		require.Equal(t, 2, len(filtered))
+		require.Equal(t, projectA, filtered[0].ProjectPath)
+		require.Equal(t, projectDeleted, filtered[1].ProjectPath)
+
+		_, err = filterWorkspaceListItems(items, workspaceListFilter{PackagePattern: "github.com/["}, now)
+		require.Error(t, err)
+
+		filtered, err = filterWorkspaceListItems(items, workspaceListFilter{IsStaleOnly: true, StaleAge: 30 * 24 * time.Hour}, now)
+		require.NoError(t, err)
+		require.Equal(t, 4, len(filtered))
+
+		filtered, err = filterWorkspaceListItems(items[1:2], workspaceListFilter{IsStaleOnly: true, StaleAge: 60 * 24 * time.Hour}, now)
+		require.NoError(t, err)
+		require.Equal(t, 0, len(filtered))
+	}
+
+	t.Log("Formats")
+	{
+		out, err := formatWorkspaceListItems(items, workspacesFormatJSON, "")
+		require.NoError(t, err)
+		var decoded []workspaceListItemModel
+		require.NoError(t, json.Unmarshal([]byte(out), &decoded))
+		require.Equal(t, 4, len(decoded))
+		require.Equal(t, projectA, decoded[0].ProjectPath)
+
+		out, err = formatWorkspaceListItems(items, workspacesFormatYAML, "")
+		require.NoError(t, err)
+		require.Contains(t, out, "- project_path: "+projectA+"\n")
+
+		out, err = formatWorkspaceListItems(items[:2], workspacesFormatTemplate, "{{.PackageName}} {{size .Size}} {{.Health}}")
+		require.NoError(t, err)
+		require.Equal(t, "github.com/org/a 5 B shared\ngithub.com/other/b 0 B ok\n", out)
+
+		out, err = formatWorkspaceListItems(items[3:], workspacesFormatTable, "")
+		require.NoError(t, err)
+		require.Contains(t, out, "PROJECT")
+		require.Contains(t, out, projectNoWs+"  -")
+
+		_, err = formatWorkspaceListItems(items, "xml", "")
+		require.EqualError(t, err, "Unsupported format: xml (options: list, table, json, yaml, template)")
+	}
+}
@@ -2,9 +2,7 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 
-	log "github.com/sirupsen/logrus"
 	"github.com/bitrise-io/gows/config"
 	"gopkg.in/viktorbenei/cobra.v0"
 )
@@ -20,14 +18,14 @@ var wspathCmd = &cobra.Command{
 			return fmt.Errorf("Failed to load gows config: %s", err)
 		}
 
-		currWorkDir, err := os.Getwd()
+		projectDir, _, err := currentProjectDir()
 		if err != nil {
-			log.Debugf("Failed to get current working directory: %s", err)
+			return err
 		}
 
-		wsConfig, isFound := gowsConfig.WorkspaceForProjectLocation(currWorkDir)
+		wsConfig, isFound := gowsConfig.WorkspaceForProjectLocation(projectDir)
 		if !isFound {
-			return fmt.Errorf("No Workspace configuration found for the current project / working directory: %s", currWorkDir)
+			return fmt.Errorf("No Workspace configuration found for the current project / working directory: %s", projectDir)
 		}
 
 		fmt.Println(wsConfig.WorkspaceRootPath)
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// DependenciesLockFileName - written by gows freeze, next to gows.yml
+	DependenciesLockFileName = "gows.lock.yml"
+)
+
+// DependencyLockModel - a VCS checkout in the workspace's src directory
+type DependencyLockModel struct {
+	// ImportPath - the path of the checkout, relative to the workspace's src directory
+	ImportPath string `json:"import_path" yaml:"import_path"`
+	// VCS - git or hg
+	VCS      string `json:"vcs" yaml:"vcs"`
+	Remote   string `json:"remote" yaml:"remote"`
+	Revision string `json:"revision" yaml:"revision"`
+}
+
+// DependenciesLockModel - stored in ./gows.lock.yml
+type DependenciesLockModel struct {
+	Dependencies []DependencyLockModel `json:"dependencies" yaml:"dependencies"`
+}
+
+// LoadDependenciesLockFromDir loads the dependencies lock file (gows.lock.yml) of the project in projectDir
+func LoadDependenciesLockFromDir(projectDir string) (DependenciesLockModel, error) {
+	lockFilePath := filepath.Join(projectDir, DependenciesLockFileName)
+	bytes, err := ioutil.ReadFile(lockFilePath)
+	if err != nil {
+		return DependenciesLockModel{}, fmt.Errorf("Failed to read dependencies lock file (%s), error: %s", lockFilePath, err)
+	}
+	var depsLock DependenciesLockModel
+	if err := yaml.Unmarshal(bytes, &depsLock); err != nil {
+		return DependenciesLockModel{}, fmt.Errorf("Failed to parse dependencies lock file (should be valid YML, path: %s), error: %s", lockFilePath, err)
+	}
+	return depsLock, nil
+}
+
+// SaveDependenciesLockToDir saves the dependencies lock file (gows.lock.yml) of the project in projectDir
+func SaveDependenciesLockToDir(projectDir string, depsLock DependenciesLockModel) error {
+	bytes, err := yaml.Marshal(depsLock)
+	if err != nil {
+		return fmt.Errorf("Failed to serialize dependencies lock: %s", err)
+	}
+
+	lockFilePath := filepath.Join(projectDir, DependenciesLockFileName)
+	if err := fileutil.WriteBytesToFile(lockFilePath, bytes); err != nil {
+		return fmt.Errorf("Failed to write dependencies lock file (%s), error: %s", lockFilePath, err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EnvItem is an environment variable defined in a .env file
+type EnvItem struct {
+	Key   string
+	Value string
+	// IsLiteral - the value was single quoted, it should not be expanded
+	IsLiteral bool
+}
+
+// LoadEnvFile reads the environment variables defined in a .env file
+func LoadEnvFile(pth string) ([]EnvItem, error) {
+	file, err := os.Open(pth)
+	if err != nil {
+		return []EnvItem{}, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	items := []EnvItem{}
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		item, isItem, err := ParseEnvFileLine(scanner.Text())
+		if err != nil {
+			return []EnvItem{}, fmt.Errorf("Invalid env file (%s), line %d: %s", pth, lineNum, err)
+		}
+		if isItem {
+			items = append(items, item)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return []EnvItem{}, fmt.Errorf("Failed to read env file (%s), error: %s", pth, err)
+	}
+
+	return items, nil
+}
+
+// ParseEnvFileLine parses a line of a .env file: KEY=value (optionally prefixed with export).
+// The value can be single quoted (no escapes, not expanded) or double quoted (with \n, \", \\ escapes).
+// Returns false if the line is empty or a comment.
+func ParseEnvFileLine(line string) (EnvItem, bool, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return EnvItem{}, false, nil
+	}
+	line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+
+	split := strings.SplitN(line, "=", 2)
+	if len(split) != 2 {
+		return EnvItem{}, false, fmt.Errorf("missing = in line: %s", line)
+	}
+	key := strings.TrimSpace(split[0])
+	if key == "" || strings.ContainsAny(key, " \t") {
+		return EnvItem{}, false, fmt.Errorf("invalid key: %s", key)
+	}
+	value := strings.TrimSpace(split[1])
+
+	switch {
+	case strings.HasPrefix(value, "'"):
+		end := strings.Index(value[1:], "'")
+		if end < 0 {
+			return EnvItem{}, false, fmt.Errorf("unterminated single quoted value of %s", key)
+		}
+		return EnvItem{Key: key, Value: value[1 : end+1], IsLiteral: true}, true, nil
+	case strings.HasPrefix(value, `"`):
+		unquoted := ""
+		for i := 1; i < len(value); i++ {
+			switch value[i] {
+			case '\\':
+				if i+1 < len(value) {
+					i++
+					switch value[i] {
+					case 'n':
+						unquoted += "\n"
+					case 't':
+						unquoted += "\t"
+					default:
+						unquoted += string(value[i])
+					}
+				}
+			case '"':
+				return EnvItem{Key: key, Value: unquoted}, true, nil
+			default:
+				unquoted += string(value[i])
+			}
+		}
+		return EnvItem{}, false, fmt.Errorf("unterminated double quoted value of %s", key)
+	default:
+		// strip inline comment
+		if idx := strings.Index(value, " #"); idx >= 0 {
+			value = strings.TrimSpace(value[:idx])
+		}
+		return EnvItem{Key: key, Value: value}, true, nil
+	}
+}
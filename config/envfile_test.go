@@ -0,0 +1,72 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseEnvFileLine(t *testing.T) {
+	t.Log("Empty lines and comments")
+	{
+		for _, line := range []string{"", "  ", "# comment", "  # KEY=value"} {
+			_, isItem, err := ParseEnvFileLine(line)
+			require.NoError(t, err)
+			require.Equal(t, false, isItem, line)
+		}
+	}
+
+	t.Log("Values")
+	{
+		for line, expectedItem := range map[string]EnvItem{
+			"KEY=value":                     EnvItem{Key: "KEY", Value: "value"},
+			"export KEY=value":              EnvItem{Key: "KEY", Value: "value"},
+			" KEY = value # comment":        EnvItem{Key: "KEY", Value: "value"},
+			"KEY=":                          EnvItem{Key: "KEY", Value: ""},
+			"KEY=a=b":                       EnvItem{Key: "KEY", Value: "a=b"},
+			"KEY=$HOME/path":                EnvItem{Key: "KEY", Value: "$HOME/path"},
+			"KEY='$HOME # not a comment'":   EnvItem{Key: "KEY", Value: "$HOME # not a comment", IsLiteral: true},
+			`KEY="line1\nline2 \"quoted\""`: EnvItem{Key: "KEY", Value: "line1\nline2 \"quoted\""},
+			`KEY="value" # comment`:         EnvItem{Key: "KEY", Value: "value"},
+		} {
+			item, isItem, err := ParseEnvFileLine(line)
+			require.NoError(t, err, line)
+			require.Equal(t, true, isItem, line)
+			require.Equal(t, expectedItem, item, line)
+		}
+	}
+
+	t.Log("Invalid lines")
+	{
+		for _, line := range []string{"KEY", "=value", "MY KEY=value", "KEY='value", `KEY="value`} {
+			_, _, err := ParseEnvFileLine(line)
+			require.Error(t, err, line)
+		}
+	}
+}
+
+func Test_LoadEnvFile(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gows-envfile-")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(tmpDir))
+	}()
+
+	pth := filepath.Join(tmpDir, ".env")
+	require.NoError(t, ioutil.WriteFile(pth, []byte("# db\nDB_HOST=localhost\n\nDB_DSN=postgres://$DB_HOST/db\n"), 0644))
+
+	items, err := LoadEnvFile(pth)
+	require.NoError(t, err)
+	require.Equal(t, []EnvItem{
+		EnvItem{Key: "DB_HOST", Value: "localhost"},
+		EnvItem{Key: "DB_DSN", Value: "postgres://$DB_HOST/db"},
+	}, items)
+
+	require.NoError(t, ioutil.WriteFile(pth, []byte("DB_HOST=localhost\nINVALID\n"), 0644))
+	_, err = LoadEnvFile(pth)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "line 2")
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+// FileLock is an advisory lock on a file (flock on unix, LockFileEx on windows),
+// shared between the gows processes running in parallel
+type FileLock struct {
+	file *os.File
+}
+
+// LockFile acquires an exclusive lock on the file at pth (the file is created if it does not exist yet),
+// blocks until the lock is acquired
+func LockFile(pth string) (*FileLock, error) {
+	lock, _, err := lockFile(pth, false, true)
+	return lock, err
+}
+
+// TryLockFile tries to acquire a shared or an exclusive lock on the file at pth, without blocking.
+// Returns false if the file is locked by someone else.
+func TryLockFile(pth string, isShared bool) (*FileLock, bool, error) {
+	return lockFile(pth, isShared, false)
+}
+
+func lockFile(pth string, isShared, isBlocking bool) (*FileLock, bool, error) {
+	if err := pathutil.EnsureDirExist(filepath.Dir(pth)); err != nil {
+		return nil, false, fmt.Errorf("Failed to create directory of lock file (%s), error: %s", pth, err)
+	}
+
+	file, err := os.OpenFile(pth, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, false, fmt.Errorf("Failed to open lock file (%s), error: %s", pth, err)
+	}
+
+	if err := lockFileHandle(file, isShared, isBlocking); err != nil {
+		if closeErr := file.Close(); closeErr != nil {
+			return nil, false, fmt.Errorf("Failed to lock file (%s), error: %s (and failed to close it: %s)", pth, err, closeErr)
+		}
+		if isLockedByOtherError(err) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("Failed to lock file (%s), error: %s", pth, err)
+	}
+
+	return &FileLock{file: file}, true, nil
+}
+
+// Unlock releases the lock
+func (lock *FileLock) Unlock() error {
+	if err := unlockFileHandle(lock.file); err != nil {
+		return fmt.Errorf("Failed to unlock file (%s), error: %s", lock.file.Name(), err)
+	}
+	return lock.file.Close()
+}
@@ -0,0 +1,34 @@
+//go:build !windows
+// +build !windows
+
+package config
+
+import (
+	"os"
+	"syscall"
+)
+
+func lockFileHandle(file *os.File, isShared, isBlocking bool) error {
+	how := syscall.LOCK_EX
+	if isShared {
+		how = syscall.LOCK_SH
+	}
+	if !isBlocking {
+		how |= syscall.LOCK_NB
+	}
+
+	for {
+		err := syscall.Flock(int(file.Fd()), how)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
+
+func unlockFileHandle(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
+
+func isLockedByOtherError(err error) bool {
+	return err == syscall.EWOULDBLOCK
+}
@@ -0,0 +1,32 @@
+//go:build windows
+// +build windows
+
+package config
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// the whole file is locked
+const lockedBytes = ^uint32(0)
+
+func lockFileHandle(file *os.File, isShared, isBlocking bool) error {
+	flags := uint32(0)
+	if !isShared {
+		flags |= windows.LOCKFILE_EXCLUSIVE_LOCK
+	}
+	if !isBlocking {
+		flags |= windows.LOCKFILE_FAIL_IMMEDIATELY
+	}
+	return windows.LockFileEx(windows.Handle(file.Fd()), flags, 0, lockedBytes, lockedBytes, &windows.Overlapped{})
+}
+
+func unlockFileHandle(file *os.File) error {
+	return windows.UnlockFileEx(windows.Handle(file.Fd()), 0, lockedBytes, lockedBytes, &windows.Overlapped{})
+}
+
+func isLockedByOtherError(err error) bool {
+	return err == windows.ERROR_LOCK_VIOLATION
+}
@@ -3,9 +3,12 @@ package config
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
 
 	log "github.com/sirupsen/logrus"
-	"github.com/bitrise-io/go-utils/fileutil"
 	"github.com/bitrise-io/go-utils/pathutil"
 	"gopkg.in/yaml.v2"
 )
@@ -13,6 +16,11 @@ import (
 const (
 	gowsWorspacesRootDirPath = "$HOME/.bitrise-gows/wsdirs"
 	gowsConfigFilePath       = "$HOME/.bitrise-gows/workspaces.yml"
+
+	// GOWSConfigSchemaVersion - the version of the gows config (workspaces.yml) format:
+	// 1 (no schema_version in the file) - only the workspace root paths,
+	// 2 - workspace metadata (created / last used, ...)
+	GOWSConfigSchemaVersion = 2
 )
 
 // GOWSWorspacesRootDirAbsPath ...
@@ -28,25 +36,153 @@ func GOWSConfigFileAbsPath() (string, error) {
 // WorkspaceConfigModel ...
 type WorkspaceConfigModel struct {
 	WorkspaceRootPath string `json:"workspace_root_path" yaml:"workspace_root_path"`
+	// ProjectID - the ID of the project (see: UserConfigModel.ProjectID),
+	// to find the workspace if the project is moved to another path
+	ProjectID string `json:"project_id,omitempty" yaml:"project_id,omitempty"`
+	// CreatedAt - when the workspace was created (zero for the workspaces created by earlier gows versions)
+	CreatedAt time.Time `json:"created_at,omitempty" yaml:"created_at,omitempty"`
+	// LastUsedAt - when the last command run in the workspace finished
+	LastUsedAt time.Time `json:"last_used_at,omitempty" yaml:"last_used_at,omitempty"`
+	// PackageName - the package name of the project, at the last use
+	PackageName string `json:"package_name,omitempty" yaml:"package_name,omitempty"`
+	// SyncMode - the sync mode used at the last use
+	SyncMode string `json:"sync_mode,omitempty" yaml:"sync_mode,omitempty"`
+	// GOWSVersion - the version of gows used at the last use
+	GOWSVersion string `json:"gows_version,omitempty" yaml:"gows_version,omitempty"`
+	// LastExitCode - the exit code of the last command run in the workspace
+	LastExitCode *int `json:"last_exit_code,omitempty" yaml:"last_exit_code,omitempty"`
+	// RunCount - the number of commands run in the workspace
+	RunCount int `json:"run_count,omitempty" yaml:"run_count,omitempty"`
 }
 
 // GOWSConfigModel ...
 type GOWSConfigModel struct {
-	Workspaces map[string]WorkspaceConfigModel `json:"workspaces" yaml:"workspaces"`
+	// SchemaVersion - see GOWSConfigSchemaVersion, 0 if it's not set (version 1)
+	SchemaVersion int                             `json:"schema_version,omitempty" yaml:"schema_version,omitempty"`
+	Workspaces    map[string]WorkspaceConfigModel `json:"workspaces" yaml:"workspaces"`
 }
 
 func createDefaultGOWSConfigModel() GOWSConfigModel {
 	return GOWSConfigModel{
-		Workspaces: map[string]WorkspaceConfigModel{},
+		SchemaVersion: GOWSConfigSchemaVersion,
+		Workspaces:    map[string]WorkspaceConfigModel{},
+	}
+}
+
+// CanonicalProjectPath returns the canonical form of a project path (absolute, clean,
+// symlinks resolved), which is used as the project's key in the gows config.
+// If the path does not exist its clean absolute path is returned.
+func CanonicalProjectPath(projectPath string) (string, error) {
+	absPath, err := filepath.Abs(projectPath)
+	if err != nil {
+		return "", fmt.Errorf("Failed to get absolute path of (%s), error: %s", projectPath, err)
+	}
+
+	resolvedPath, err := filepath.EvalSymlinks(absPath)
+	if os.IsNotExist(err) {
+		return filepath.Clean(absPath), nil
+	} else if err != nil {
+		return "", fmt.Errorf("Failed to resolve symlinks of (%s), error: %s", absPath, err)
 	}
+
+	return filepath.Clean(resolvedPath), nil
 }
 
 // WorkspaceForProjectLocation ...
 func (gowsConfig GOWSConfigModel) WorkspaceForProjectLocation(projectPath string) (WorkspaceConfigModel, bool) {
-	wsConfig, isFound := gowsConfig.Workspaces[projectPath]
+	if wsConfig, isFound := gowsConfig.Workspaces[projectPath]; isFound {
+		return wsConfig, true
+	}
+
+	canonicalProjectPath, err := CanonicalProjectPath(projectPath)
+	if err != nil {
+		log.Debugf("Failed to get canonical path of project (%s), error: %s", projectPath, err)
+		return WorkspaceConfigModel{}, false
+	}
+	wsConfig, isFound := gowsConfig.Workspaces[canonicalProjectPath]
 	return wsConfig, isFound
 }
 
+// SetWorkspaceForProjectLocation registers the workspace for the project,
+// with the project's canonical path as the key
+func (gowsConfig *GOWSConfigModel) SetWorkspaceForProjectLocation(projectPath string, wsConfig WorkspaceConfigModel) error {
+	canonicalProjectPath, err := CanonicalProjectPath(projectPath)
+	if err != nil {
+		return err
+	}
+
+	if gowsConfig.Workspaces == nil {
+		gowsConfig.Workspaces = map[string]WorkspaceConfigModel{}
+	}
+	gowsConfig.Workspaces[canonicalProjectPath] = wsConfig
+	return nil
+}
+
+// isProjectPathsCanonical returns true if every project is registered with its canonical path
+func (gowsConfig GOWSConfigModel) isProjectPathsCanonical() bool {
+	for projectPath := range gowsConfig.Workspaces {
+		canonicalProjectPath, err := CanonicalProjectPath(projectPath)
+		if err == nil && canonicalProjectPath != projectPath {
+			return false
+		}
+	}
+	return true
+}
+
+// canonicalizeProjectPaths re-keys the workspaces by the canonical project paths.
+// If more than one registered project path resolves to the same directory only one
+// of the workspaces is kept: the one registered with the canonical path, or
+// the one which still exists on disk.
+// Returns true if the workspaces were changed.
+func (gowsConfig *GOWSConfigModel) canonicalizeProjectPaths() bool {
+	projectPathsByCanonicalPath := map[string][]string{}
+	for projectPath := range gowsConfig.Workspaces {
+		canonicalProjectPath, err := CanonicalProjectPath(projectPath)
+		if err != nil {
+			log.Debugf("Failed to get canonical path of project (%s), error: %s", projectPath, err)
+			canonicalProjectPath = projectPath
+		}
+		projectPathsByCanonicalPath[canonicalProjectPath] = append(projectPathsByCanonicalPath[canonicalProjectPath], projectPath)
+	}
+
+	isChanged := false
+	workspaces := map[string]WorkspaceConfigModel{}
+	for canonicalProjectPath, projectPaths := range projectPathsByCanonicalPath {
+		sort.Strings(projectPaths)
+		keptProjectPath := projectPaths[0]
+		for _, projectPath := range projectPaths {
+			if projectPath == canonicalProjectPath {
+				keptProjectPath = projectPath
+				break
+			}
+			if isExists, _ := pathutil.IsDirExists(gowsConfig.Workspaces[projectPath].WorkspaceRootPath); isExists {
+				if isKeptExists, _ := pathutil.IsDirExists(gowsConfig.Workspaces[keptProjectPath].WorkspaceRootPath); !isKeptExists {
+					keptProjectPath = projectPath
+				}
+			}
+		}
+
+		if len(projectPaths) > 1 {
+			log.Warningf("Multiple registered project paths resolve to the same directory (%s):", canonicalProjectPath)
+			for _, projectPath := range projectPaths {
+				if projectPath == keptProjectPath {
+					log.Warningf(" * %s -> %s (kept)", projectPath, gowsConfig.Workspaces[projectPath].WorkspaceRootPath)
+				} else {
+					log.Warningf(" * %s -> %s (removed from the gows config)", projectPath, gowsConfig.Workspaces[projectPath].WorkspaceRootPath)
+				}
+			}
+		}
+
+		if len(projectPaths) > 1 || keptProjectPath != canonicalProjectPath {
+			isChanged = true
+		}
+		workspaces[canonicalProjectPath] = gowsConfig.Workspaces[keptProjectPath]
+	}
+
+	gowsConfig.Workspaces = workspaces
+	return isChanged
+}
+
 // LoadGOWSConfigFromFile ...
 func LoadGOWSConfigFromFile() (GOWSConfigModel, error) {
 	gowsConfigFileAbsPath, err := GOWSConfigFileAbsPath()
@@ -54,6 +190,24 @@ func LoadGOWSConfigFromFile() (GOWSConfigModel, error) {
 		return GOWSConfigModel{}, fmt.Errorf("Failed to get absolute path of gows config: %s", err)
 	}
 
+	gowsConfig, err := loadGOWSConfigFromFile(gowsConfigFileAbsPath)
+	if err != nil {
+		return GOWSConfigModel{}, err
+	}
+
+	// migrate the project paths registered before the keys were canonicalized
+	if !gowsConfig.isProjectPathsCanonical() {
+		log.Debugf("Canonicalizing the project paths of the gows config ...")
+		if err := UpdateGOWSConfig(func(*GOWSConfigModel) error { return nil }); err != nil {
+			return GOWSConfigModel{}, fmt.Errorf("Failed to save the migrated gows config, error: %s", err)
+		}
+		return loadGOWSConfigFromFile(gowsConfigFileAbsPath)
+	}
+
+	return gowsConfig, nil
+}
+
+func loadGOWSConfigFromFile(gowsConfigFileAbsPath string) (GOWSConfigModel, error) {
 	// If doesn't exist yet, return a default/empty gows config
 	{
 		isExists, err := pathutil.IsPathExists(gowsConfigFileAbsPath)
@@ -74,26 +228,148 @@ func LoadGOWSConfigFromFile() (GOWSConfigModel, error) {
 	if err := yaml.Unmarshal(bytes, &gowsConfig); err != nil {
 		return GOWSConfigModel{}, fmt.Errorf("Failed to parse gows config (should be valid YML, path: %s), error: %s", gowsConfigFileAbsPath, err)
 	}
+	if gowsConfig.Workspaces == nil {
+		gowsConfig.Workspaces = map[string]WorkspaceConfigModel{}
+	}
+	// the files written by earlier gows versions don't have a schema version (and metadata),
+	// they are upgraded on the next write
+	if gowsConfig.SchemaVersion == 0 {
+		log.Debugf("gows config (%s) has no schema version, it was written by an earlier gows version", gowsConfigFileAbsPath)
+	}
 
 	return gowsConfig, nil
 }
 
-// SaveGOWSConfigToFile ...
-func SaveGOWSConfigToFile(gowsConfig GOWSConfigModel) error {
-	bytes, err := yaml.Marshal(gowsConfig)
+// UpdateGOWSConfig does a locked read-modify-write of the gows config:
+// the gows config is loaded, passed to updateFn and saved, while holding the gows config lock,
+// so that parallel gows processes can't overwrite each other's changes.
+// Nothing is saved if updateFn returns an error.
+func UpdateGOWSConfig(updateFn func(gowsConfig *GOWSConfigModel) error) error {
+	gowsConfigFileAbsPath, err := GOWSConfigFileAbsPath()
 	if err != nil {
-		return fmt.Errorf("Failed to generate YML for gows config: %s", err)
+		return fmt.Errorf("Failed to get absolute path of gows config: %s", err)
+	}
+
+	lock, err := LockFile(gowsConfigFileAbsPath + ".lock")
+	if err != nil {
+		return fmt.Errorf("Failed to lock gows config: %s", err)
+	}
+	defer func() {
+		if err := lock.Unlock(); err != nil {
+			log.Errorf("Failed to unlock gows config: %s", err)
+		}
+	}()
+
+	gowsConfig, err := loadGOWSConfigFromFile(gowsConfigFileAbsPath)
+	if err != nil {
+		return err
+	}
+	if gowsConfig.SchemaVersion > GOWSConfigSchemaVersion {
+		// saving it would drop the settings this version doesn't know about
+		return fmt.Errorf("The gows config (%s) was written by a newer gows version (schema version: %d, supported: %d), please upgrade gows", gowsConfigFileAbsPath, gowsConfig.SchemaVersion, GOWSConfigSchemaVersion)
 	}
+	gowsConfig.canonicalizeProjectPaths()
 
+	if err := updateFn(&gowsConfig); err != nil {
+		return err
+	}
+
+	return saveGOWSConfigToFile(gowsConfigFileAbsPath, gowsConfig)
+}
+
+// ViewGOWSConfig calls viewFn with the current gows config, while the gows config is locked,
+// so that no workspace is registered (or unregistered) by another gows process in the meantime.
+// The gows config is not saved.
+func ViewGOWSConfig(viewFn func(gowsConfig GOWSConfigModel) error) error {
 	gowsConfigFileAbsPath, err := GOWSConfigFileAbsPath()
 	if err != nil {
 		return fmt.Errorf("Failed to get absolute path of gows config: %s", err)
 	}
 
-	err = fileutil.WriteBytesToFile(gowsConfigFileAbsPath, bytes)
+	lock, err := LockFile(gowsConfigFileAbsPath + ".lock")
+	if err != nil {
+		return fmt.Errorf("Failed to lock gows config: %s", err)
+	}
+	defer func() {
+		if err := lock.Unlock(); err != nil {
+			log.Errorf("Failed to unlock gows config: %s", err)
+		}
+	}()
+
+	gowsConfig, err := loadGOWSConfigFromFile(gowsConfigFileAbsPath)
 	if err != nil {
-		return fmt.Errorf("Failed to write Project Config into file (%s), error: %s", gowsConfigFileAbsPath, err)
+		return err
+	}
+	gowsConfig.canonicalizeProjectPaths()
+
+	return viewFn(gowsConfig)
+}
+
+// SaveGOWSConfigToFile overwrites the gows config - use UpdateGOWSConfig
+// to modify the current gows config
+func SaveGOWSConfigToFile(gowsConfig GOWSConfigModel) error {
+	return UpdateGOWSConfig(func(currentGOWSConfig *GOWSConfigModel) error {
+		*currentGOWSConfig = gowsConfig
+		return nil
+	})
+}
+
+// saveGOWSConfigToFile - the caller has to hold the gows config lock.
+// The gows config is written into a temp file, which is then renamed, so that the gows config
+// is never left half-written; the previous gows config is kept as a .bak file.
+func saveGOWSConfigToFile(gowsConfigFileAbsPath string, gowsConfig GOWSConfigModel) error {
+	gowsConfig.SchemaVersion = GOWSConfigSchemaVersion
+	bytes, err := yaml.Marshal(gowsConfig)
+	if err != nil {
+		return fmt.Errorf("Failed to generate YML for gows config: %s", err)
+	}
+
+	if err := pathutil.EnsureDirExist(filepath.Dir(gowsConfigFileAbsPath)); err != nil {
+		return fmt.Errorf("Failed to create gows config dir, error: %s", err)
+	}
+
+	prevBytes, err := ioutil.ReadFile(gowsConfigFileAbsPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Failed to read gows config file (%s), error: %s", gowsConfigFileAbsPath, err)
+	} else if err == nil {
+		if err := writeBytesToFileAtomically(gowsConfigFileAbsPath+".bak", prevBytes); err != nil {
+			return fmt.Errorf("Failed to backup gows config, error: %s", err)
+		}
+	}
+
+	if err := writeBytesToFileAtomically(gowsConfigFileAbsPath, bytes); err != nil {
+		return fmt.Errorf("Failed to write gows config into file (%s), error: %s", gowsConfigFileAbsPath, err)
 	}
 
 	return nil
 }
+
+// writeBytesToFileAtomically writes the bytes into a temp file, and renames it to pth
+func writeBytesToFileAtomically(pth string, bytes []byte) error {
+	tmpFile, err := ioutil.TempFile(filepath.Dir(pth), filepath.Base(pth)+".tmp-")
+	if err != nil {
+		return err
+	}
+	tmpFilePth := tmpFile.Name()
+
+	if _, err := tmpFile.Write(bytes); err != nil {
+		_ = tmpFile.Close()
+		_ = os.Remove(tmpFilePth)
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		_ = tmpFile.Close()
+		_ = os.Remove(tmpFilePth)
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		_ = os.Remove(tmpFilePth)
+		return err
+	}
+	if err := os.Chmod(tmpFilePth, 0644); err != nil {
+		_ = os.Remove(tmpFilePth)
+		return err
+	}
+
+	return os.Rename(tmpFilePth, pth)
+}
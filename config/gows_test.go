@@ -1,9 +1,18 @@
 package config
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
 )
 
 func Test_GOWSConfigModel_WorkspaceForProjectLocation(t *testing.T) {
@@ -30,3 +39,242 @@ func Test_GOWSConfigModel_WorkspaceForProjectLocation(t *testing.T) {
 		require.Equal(t, "", wsConfig.WorkspaceRootPath)
 	}
 }
+
+func Test_GOWSConfigModel_canonicalizeProjectPaths(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gows-canonical-")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(tmpDir))
+	}()
+	tmpDir, err = filepath.EvalSymlinks(tmpDir)
+	require.NoError(t, err)
+
+	projectDir := filepath.Join(tmpDir, "project")
+	require.NoError(t, os.MkdirAll(projectDir, 0755))
+	projectLinkPth := filepath.Join(tmpDir, "project-link")
+	require.NoError(t, os.Symlink(projectDir, projectLinkPth))
+	existingWsDir := filepath.Join(tmpDir, "ws-existing")
+	require.NoError(t, os.MkdirAll(existingWsDir, 0755))
+
+	t.Log("Symlinked and trailing slash variants are merged, the existing workspace is kept")
+	{
+		gowsConfig := GOWSConfigModel{
+			Workspaces: map[string]WorkspaceConfigModel{
+				projectLinkPth:     WorkspaceConfigModel{WorkspaceRootPath: filepath.Join(tmpDir, "ws-missing")},
+				projectDir + "/":   WorkspaceConfigModel{WorkspaceRootPath: existingWsDir},
+				"/proj/not/exists": WorkspaceConfigModel{WorkspaceRootPath: "/ws/not/exists"},
+			},
+		}
+
+		require.Equal(t, true, gowsConfig.canonicalizeProjectPaths())
+		require.Equal(t, map[string]WorkspaceConfigModel{
+			projectDir:         WorkspaceConfigModel{WorkspaceRootPath: existingWsDir},
+			"/proj/not/exists": WorkspaceConfigModel{WorkspaceRootPath: "/ws/not/exists"},
+		}, gowsConfig.Workspaces)
+
+		t.Log("Already canonical - no change")
+		require.Equal(t, false, gowsConfig.canonicalizeProjectPaths())
+	}
+
+	t.Log("The entry registered with the canonical path is kept")
+	{
+		gowsConfig := GOWSConfigModel{
+			Workspaces: map[string]WorkspaceConfigModel{
+				projectLinkPth: WorkspaceConfigModel{WorkspaceRootPath: existingWsDir},
+				projectDir:     WorkspaceConfigModel{WorkspaceRootPath: "/ws/canonical"},
+			},
+		}
+
+		require.Equal(t, true, gowsConfig.canonicalizeProjectPaths())
+		require.Equal(t, map[string]WorkspaceConfigModel{
+			projectDir: WorkspaceConfigModel{WorkspaceRootPath: "/ws/canonical"},
+		}, gowsConfig.Workspaces)
+	}
+
+	t.Log("Lookup and register through a symlinked path")
+	{
+		gowsConfig := GOWSConfigModel{}
+		require.NoError(t, gowsConfig.SetWorkspaceForProjectLocation(projectLinkPth+"/", WorkspaceConfigModel{WorkspaceRootPath: existingWsDir}))
+		require.Equal(t, []string{projectDir}, workspaceKeys(gowsConfig))
+
+		wsConfig, isFound := gowsConfig.WorkspaceForProjectLocation(projectLinkPth)
+		require.Equal(t, true, isFound)
+		require.Equal(t, existingWsDir, wsConfig.WorkspaceRootPath)
+	}
+}
+
+func workspaceKeys(gowsConfig GOWSConfigModel) []string {
+	keys := []string{}
+	for key := range gowsConfig.Workspaces {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Test_GOWSConfigHelperProcess isn't a real test, it's used by Test_UpdateGOWSConfig_Concurrent
+// to register projects from a separate process
+func Test_GOWSConfigHelperProcess(t *testing.T) {
+	projectPathPrefix := os.Getenv("GOWS_TEST_REGISTER_PROJECT_PREFIX")
+	if projectPathPrefix == "" {
+		return
+	}
+
+	for i := 0; i < 10; i++ {
+		projectPath := fmt.Sprintf("%s-%d", projectPathPrefix, i)
+		require.NoError(t, UpdateGOWSConfig(func(gowsConfig *GOWSConfigModel) error {
+			return gowsConfig.SetWorkspaceForProjectLocation(projectPath, WorkspaceConfigModel{WorkspaceRootPath: projectPath + "-ws"})
+		}))
+	}
+}
+
+func Test_UpdateGOWSConfig_Concurrent(t *testing.T) {
+	homeDir, err := ioutil.TempDir("", "gows-home-")
+	require.NoError(t, err)
+	origHome := os.Getenv("HOME")
+	require.NoError(t, os.Setenv("HOME", homeDir))
+	defer func() {
+		require.NoError(t, os.Setenv("HOME", origHome))
+		require.NoError(t, os.RemoveAll(homeDir))
+	}()
+
+	expectedProjectPaths := []string{}
+
+	t.Log("Register projects from parallel processes and goroutines")
+	{
+		cmds := []*exec.Cmd{}
+		for i := 0; i < 4; i++ {
+			projectPathPrefix := fmt.Sprintf("/proj/process-%d", i)
+			for j := 0; j < 10; j++ {
+				expectedProjectPaths = append(expectedProjectPaths, fmt.Sprintf("%s-%d", projectPathPrefix, j))
+			}
+
+			cmd := exec.Command(os.Args[0], "-test.run=Test_GOWSConfigHelperProcess")
+			cmd.Env = append(os.Environ(), "GOWS_TEST_REGISTER_PROJECT_PREFIX="+projectPathPrefix)
+			require.NoError(t, cmd.Start())
+			cmds = append(cmds, cmd)
+		}
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 40)
+		for i := 0; i < 40; i++ {
+			projectPath := fmt.Sprintf("/proj/goroutine-%d", i)
+			expectedProjectPaths = append(expectedProjectPaths, projectPath)
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- UpdateGOWSConfig(func(gowsConfig *GOWSConfigModel) error {
+					return gowsConfig.SetWorkspaceForProjectLocation(projectPath, WorkspaceConfigModel{WorkspaceRootPath: projectPath + "-ws"})
+				})
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			require.NoError(t, err)
+		}
+
+		for _, cmd := range cmds {
+			require.NoError(t, cmd.Wait())
+		}
+	}
+
+	t.Log("Every project is registered")
+	{
+		gowsConfig, err := LoadGOWSConfigFromFile()
+		require.NoError(t, err)
+
+		projectPaths := workspaceKeys(gowsConfig)
+		sort.Strings(projectPaths)
+		sort.Strings(expectedProjectPaths)
+		require.Equal(t, expectedProjectPaths, projectPaths)
+		for _, projectPath := range projectPaths {
+			require.Equal(t, projectPath+"-ws", gowsConfig.Workspaces[projectPath].WorkspaceRootPath)
+		}
+	}
+
+	t.Log("The previous gows config is kept as a backup")
+	{
+		gowsConfigFileAbsPath, err := GOWSConfigFileAbsPath()
+		require.NoError(t, err)
+
+		bytes, err := ioutil.ReadFile(gowsConfigFileAbsPath + ".bak")
+		require.NoError(t, err)
+		var backupGOWSConfig GOWSConfigModel
+		require.NoError(t, yaml.Unmarshal(bytes, &backupGOWSConfig))
+		require.Equal(t, len(expectedProjectPaths)-1, len(backupGOWSConfig.Workspaces))
+	}
+}
+
+func Test_GOWSConfig_SchemaVersion(t *testing.T) {
+	homeDir, err := ioutil.TempDir("", "gows-home-")
+	require.NoError(t, err)
+	origHome := os.Getenv("HOME")
+	require.NoError(t, os.Setenv("HOME", homeDir))
+	defer func() {
+		require.NoError(t, os.Setenv("HOME", origHome))
+		require.NoError(t, os.RemoveAll(homeDir))
+	}()
+
+	gowsConfigFileAbsPath, err := GOWSConfigFileAbsPath()
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Dir(gowsConfigFileAbsPath), 0755))
+
+	t.Log("A gows config written by an earlier gows version is loaded, and upgraded on the next write")
+	{
+		require.NoError(t, ioutil.WriteFile(gowsConfigFileAbsPath, []byte(`workspaces:
+  /proj/old:
+    workspace_root_path: /ws/old-1500000000
+`), 0644))
+
+		gowsConfig, err := loadGOWSConfigFromFile(gowsConfigFileAbsPath)
+		require.NoError(t, err)
+		require.Equal(t, 0, gowsConfig.SchemaVersion)
+		require.Equal(t, WorkspaceConfigModel{WorkspaceRootPath: "/ws/old-1500000000"}, gowsConfig.Workspaces["/proj/old"])
+
+		exitCode := 0
+		createdAt := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		require.NoError(t, UpdateGOWSConfig(func(gowsConfig *GOWSConfigModel) error {
+			return gowsConfig.SetWorkspaceForProjectLocation("/proj/new", WorkspaceConfigModel{
+				WorkspaceRootPath: "/ws/new",
+				CreatedAt:         createdAt,
+				LastUsedAt:        createdAt,
+				PackageName:       "github.com/org/new",
+				SyncMode:          SyncModeCopy,
+				GOWSVersion:       "1.0.0",
+				LastExitCode:      &exitCode,
+				RunCount:          1,
+			})
+		}))
+
+		bytes, err := ioutil.ReadFile(gowsConfigFileAbsPath)
+		require.NoError(t, err)
+		require.Contains(t, string(bytes), "schema_version: 2\n")
+		require.Contains(t, string(bytes), "last_exit_code: 0\n")
+		// no metadata is written for the old workspace
+		require.Contains(t, string(bytes), `  /proj/old:
+    workspace_root_path: /ws/old-1500000000
+`)
+
+		gowsConfig, err = loadGOWSConfigFromFile(gowsConfigFileAbsPath)
+		require.NoError(t, err)
+		require.Equal(t, GOWSConfigSchemaVersion, gowsConfig.SchemaVersion)
+		require.Equal(t, createdAt, gowsConfig.Workspaces["/proj/new"].CreatedAt)
+		require.Equal(t, 0, *gowsConfig.Workspaces["/proj/new"].LastExitCode)
+		require.Equal(t, "github.com/org/new", gowsConfig.Workspaces["/proj/new"].PackageName)
+	}
+
+	t.Log("A gows config written by a newer gows version is not overwritten")
+	{
+		require.NoError(t, ioutil.WriteFile(gowsConfigFileAbsPath, []byte(`schema_version: 99
+workspaces: {}
+`), 0644))
+
+		_, err := LoadGOWSConfigFromFile()
+		require.NoError(t, err)
+
+		err = UpdateGOWSConfig(func(gowsConfig *GOWSConfigModel) error { return nil })
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "written by a newer gows version (schema version: 99, supported: 2)")
+	}
+}
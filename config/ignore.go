@@ -0,0 +1,49 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+const (
+	// IgnoreFileName ...
+	IgnoreFileName = ".gowsignore"
+	// IgnoreFilePath - gitignore style patterns of paths not to sync in copy mode
+	IgnoreFilePath = "./" + IgnoreFileName
+)
+
+// IgnoreFileAbsPath ...
+func IgnoreFileAbsPath() (string, error) {
+	return pathutil.AbsPath(IgnoreFilePath)
+}
+
+// LoadIgnorePatternsFromFile returns the pattern lines of the .gowsignore file,
+// or an empty list if there's no .gowsignore file
+func LoadIgnorePatternsFromFile() ([]string, error) {
+	return LoadIgnorePatternsFromDir(".")
+}
+
+// LoadIgnorePatternsFromDir returns the pattern lines of the .gowsignore file of the project in projectDir
+func LoadIgnorePatternsFromDir(projectDir string) ([]string, error) {
+	ignoreFileAbsPath, err := pathutil.AbsPath(filepath.Join(projectDir, IgnoreFileName))
+	if err != nil {
+		return []string{}, fmt.Errorf("Failed to get absolute path of ignore file: %s", err)
+	}
+
+	if isExists, err := pathutil.IsPathExists(ignoreFileAbsPath); err != nil {
+		return []string{}, err
+	} else if !isExists {
+		return []string{}, nil
+	}
+
+	bytes, err := ioutil.ReadFile(ignoreFileAbsPath)
+	if err != nil {
+		return []string{}, fmt.Errorf("Failed to read ignore file (%s), error: %s", ignoreFileAbsPath, err)
+	}
+
+	return strings.Split(string(bytes), "\n"), nil
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/pathutil"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	gowsCopySessionJournalsDirPath = "$HOME/.bitrise-gows/sessions"
+)
+
+const (
+	// CopySessionPhaseSyncIn - the project is being synced into the workspace
+	CopySessionPhaseSyncIn = "sync-in"
+	// CopySessionPhaseRunning - the command is running in the workspace
+	CopySessionPhaseRunning = "running"
+	// CopySessionPhaseSyncBack - the workspace is being synced back into the project
+	CopySessionPhaseSyncBack = "sync-back"
+)
+
+// CopySessionJournalModel describes an in-flight copy mode session,
+// so that an interrupted session can be detected and recovered
+type CopySessionJournalModel struct {
+	ProjectPath          string `json:"project_path" yaml:"project_path"`
+	WorkspaceRootPath    string `json:"workspace_root_path" yaml:"workspace_root_path"`
+	WorkspacePackagePath string `json:"workspace_package_path" yaml:"workspace_package_path"`
+	ActiveFilePath       string `json:"active_file_path" yaml:"active_file_path"`
+	// PID - the gows process of the session, it's only considered running
+	// while the run lock of the workspace is held (the PID might be reused after gows is killed)
+	PID       int       `json:"pid" yaml:"pid"`
+	Command   []string  `json:"command" yaml:"command"`
+	Phase     string    `json:"phase" yaml:"phase"`
+	StartedAt time.Time `json:"started_at" yaml:"started_at"`
+	UpdatedAt time.Time `json:"updated_at" yaml:"updated_at"`
+}
+
+// CopySessionJournalsDirAbsPath ...
+func CopySessionJournalsDirAbsPath() (string, error) {
+	return pathutil.AbsPath(gowsCopySessionJournalsDirPath)
+}
+
+// CopySessionJournalFileAbsPath returns the path of the journal file of the project
+func CopySessionJournalFileAbsPath(projectPath string) (string, error) {
+	journalsDirAbsPath, err := CopySessionJournalsDirAbsPath()
+	if err != nil {
+		return "", err
+	}
+	projectPathHash := fmt.Sprintf("%x", sha1.Sum([]byte(projectPath)))
+	return filepath.Join(journalsDirAbsPath, projectPathHash+".yml"), nil
+}
+
+// CopySessionSnapshotFileAbsPath returns the path of the file, which stores the fingerprints
+// of the project's files at the time the project was synced into the workspace
+func CopySessionSnapshotFileAbsPath(projectPath string) (string, error) {
+	journalFileAbsPath, err := CopySessionJournalFileAbsPath(projectPath)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(journalFileAbsPath, ".yml") + ".snapshot.json", nil
+}
+
+// LoadCopySessionJournalForProject returns the copy session journal of the project,
+// and false if there's no journal for the project
+func LoadCopySessionJournalForProject(projectPath string) (CopySessionJournalModel, bool, error) {
+	journalFileAbsPath, err := CopySessionJournalFileAbsPath(projectPath)
+	if err != nil {
+		return CopySessionJournalModel{}, false, fmt.Errorf("Failed to get absolute path of copy session journal: %s", err)
+	}
+
+	journal, err := loadCopySessionJournalFromFile(journalFileAbsPath)
+	if os.IsNotExist(err) {
+		return CopySessionJournalModel{}, false, nil
+	} else if err != nil {
+		return CopySessionJournalModel{}, false, err
+	}
+
+	return journal, true, nil
+}
+
+// LoadAllCopySessionJournals ...
+func LoadAllCopySessionJournals() ([]CopySessionJournalModel, error) {
+	journalsDirAbsPath, err := CopySessionJournalsDirAbsPath()
+	if err != nil {
+		return []CopySessionJournalModel{}, fmt.Errorf("Failed to get absolute path of copy session journals dir: %s", err)
+	}
+
+	fileInfos, err := ioutil.ReadDir(journalsDirAbsPath)
+	if os.IsNotExist(err) {
+		return []CopySessionJournalModel{}, nil
+	} else if err != nil {
+		return []CopySessionJournalModel{}, fmt.Errorf("Failed to list copy session journals (%s), error: %s", journalsDirAbsPath, err)
+	}
+
+	journals := []CopySessionJournalModel{}
+	for _, fileInfo := range fileInfos {
+		if fileInfo.IsDir() || !strings.HasSuffix(fileInfo.Name(), ".yml") {
+			continue
+		}
+		journal, err := loadCopySessionJournalFromFile(filepath.Join(journalsDirAbsPath, fileInfo.Name()))
+		if err != nil {
+			return []CopySessionJournalModel{}, err
+		}
+		journals = append(journals, journal)
+	}
+	return journals, nil
+}
+
+func loadCopySessionJournalFromFile(pth string) (CopySessionJournalModel, error) {
+	bytes, err := ioutil.ReadFile(pth)
+	if err != nil {
+		return CopySessionJournalModel{}, err
+	}
+	var journal CopySessionJournalModel
+	if err := yaml.Unmarshal(bytes, &journal); err != nil {
+		return CopySessionJournalModel{}, fmt.Errorf("Failed to parse copy session journal (should be valid YML, path: %s), error: %s", pth, err)
+	}
+	return journal, nil
+}
+
+// SaveCopySessionJournal ...
+func SaveCopySessionJournal(journal CopySessionJournalModel) error {
+	journal.UpdatedAt = time.Now()
+
+	bytes, err := yaml.Marshal(journal)
+	if err != nil {
+		return fmt.Errorf("Failed to generate YML for copy session journal: %s", err)
+	}
+
+	journalFileAbsPath, err := CopySessionJournalFileAbsPath(journal.ProjectPath)
+	if err != nil {
+		return fmt.Errorf("Failed to get absolute path of copy session journal: %s", err)
+	}
+	if err := pathutil.EnsureDirExist(filepath.Dir(journalFileAbsPath)); err != nil {
+		return fmt.Errorf("Failed to create copy session journals dir, error: %s", err)
+	}
+
+	// write into a temp file and rename it, so that the journal is never left half-written
+	if err := writeBytesToFileAtomically(journalFileAbsPath, bytes); err != nil {
+		return fmt.Errorf("Failed to write copy session journal into file (%s), error: %s", journalFileAbsPath, err)
+	}
+
+	return nil
+}
+
+// RemoveCopySessionJournal removes the copy session journal and snapshot of the project (if any)
+func RemoveCopySessionJournal(projectPath string) error {
+	journalFileAbsPath, err := CopySessionJournalFileAbsPath(projectPath)
+	if err != nil {
+		return fmt.Errorf("Failed to get absolute path of copy session journal: %s", err)
+	}
+	snapshotFileAbsPath, err := CopySessionSnapshotFileAbsPath(projectPath)
+	if err != nil {
+		return fmt.Errorf("Failed to get absolute path of copy session snapshot: %s", err)
+	}
+
+	for _, pth := range []string{journalFileAbsPath, snapshotFileAbsPath} {
+		if err := os.Remove(pth); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("Failed to remove copy session file (%s), error: %s", pth, err)
+		}
+	}
+	return nil
+}
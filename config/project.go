@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 
 	"github.com/bitrise-io/go-utils/fileutil"
 	"github.com/bitrise-io/go-utils/pathutil"
@@ -10,8 +12,10 @@ import (
 )
 
 const (
+	// ProjectConfigFileName ...
+	ProjectConfigFileName = "gows.yml"
 	// ProjectConfigFilePath ...
-	ProjectConfigFilePath = "./gows.yml"
+	ProjectConfigFilePath = "./" + ProjectConfigFileName
 )
 
 // ProjectConfigFileAbsPath ...
@@ -19,14 +23,132 @@ func ProjectConfigFileAbsPath() (string, error) {
 	return pathutil.AbsPath(ProjectConfigFilePath)
 }
 
+// FindProjectRootDir walks up from startDir, and returns the nearest directory
+// which contains a project config (gows.yml).
+// Returns false if no project config found in startDir or in any of its parent directories.
+func FindProjectRootDir(startDir string) (string, bool, error) {
+	dir, err := filepath.Abs(startDir)
+	if err != nil {
+		return "", false, fmt.Errorf("Failed to get absolute path of (%s), error: %s", startDir, err)
+	}
+
+	for {
+		fileInfo, err := os.Stat(filepath.Join(dir, ProjectConfigFileName))
+		if err == nil && !fileInfo.IsDir() {
+			return dir, true, nil
+		} else if err != nil && !os.IsNotExist(err) {
+			return "", false, fmt.Errorf("Failed to check project config in (%s), error: %s", dir, err)
+		}
+
+		parentDir := filepath.Dir(dir)
+		if parentDir == dir {
+			return "", false, nil
+		}
+		dir = parentDir
+	}
+}
+
 // ProjectConfigModel - stored in ./gows.yml
 type ProjectConfigModel struct {
 	PackageName string `json:"package_name" yaml:"package_name"`
+	// Aliases - additional import paths the project is exposed at inside the workspace,
+	// e.g. the upstream package of a fork, which is still referenced by the fork's imports
+	Aliases []string `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+	// SyncExclude - gitignore style patterns of paths not to sync in copy mode
+	SyncExclude []string `json:"sync_exclude,omitempty" yaml:"sync_exclude,omitempty"`
+	// SyncInclude - gitignore style patterns of paths to sync in copy mode,
+	// even if excluded by a SyncExclude or .gowsignore pattern.
+	// A path inside an excluded directory can't be re-included, just like in .gitignore
+	SyncInclude []string `json:"sync_include,omitempty" yaml:"sync_include,omitempty"`
+	// Env - environment variables to set for the commands run by gows
+	Env map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+	// EnvFiles - .env files (relative to the project's directory) to load the environment variables from,
+	// a missing file is an error, unless it's marked as optional with a - prefix (e.g. -.env.local)
+	EnvFiles []string `json:"env_files,omitempty" yaml:"env_files,omitempty"`
+	// Hermetic - settings of the hermetic mode
+	Hermetic *HermeticConfigModel `json:"hermetic,omitempty" yaml:"hermetic,omitempty"`
+	// Scripts - named commands of the project, which can be run with gows run <name>
+	Scripts map[string]ScriptConfigModel `json:"scripts,omitempty" yaml:"scripts,omitempty"`
+	// Hooks - shell commands to run around the commands run by gows
+	Hooks HooksConfigModel `json:"hooks,omitempty" yaml:"hooks,omitempty"`
+}
+
+// ScriptConfigModel - a named command of the project (gows run <name>)
+type ScriptConfigModel struct {
+	// Description - shown by gows run --list
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// Command - the command (executable) to run, it is not run through a shell
+	Command string `json:"command" yaml:"command"`
+	// Args - arguments of the command
+	Args []string `json:"args,omitempty" yaml:"args,omitempty"`
+	// Env - environment variables to set for the command, in addition to the project's Env
+	Env map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+	// WorkDir - directory (relative to the project's directory) to run the command in,
+	// defaults to the project's directory
+	WorkDir string `json:"workdir,omitempty" yaml:"workdir,omitempty"`
+	// Deps - scripts to run (in order) before this one
+	Deps []string `json:"deps,omitempty" yaml:"deps,omitempty"`
+}
+
+// HermeticConfigModel - in hermetic mode the commands only get the allowlisted environment variables
+// of the shell (and the ones specified in the Env of the project), so that builds are reproducible
+type HermeticConfigModel struct {
+	// Enabled - always use hermetic mode (it can also be enabled with gows --hermetic)
+	Enabled bool `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	// EnvAllowlist - names of the environment variables to keep, in addition to the default ones
+	// (PATH, HOME, USER, ...). A name ending with * matches every variable with that prefix.
+	EnvAllowlist []string `json:"env_allowlist,omitempty" yaml:"env_allowlist,omitempty"`
+	// IsolateNetwork - Linux only: run the commands without network access
+	IsolateNetwork bool `json:"isolate_network,omitempty" yaml:"isolate_network,omitempty"`
+}
+
+const (
+	// HookPrePrepare - runs before the workspace is prepared
+	HookPrePrepare = "pre_prepare"
+	// HookPostPrepare - runs after the workspace is prepared (and the project is synced into it, in copy mode)
+	HookPostPrepare = "post_prepare"
+	// HookPreCommand - runs right before the command
+	HookPreCommand = "pre_command"
+	// HookPostCommand - runs after the command, even if it failed
+	HookPostCommand = "post_command"
+	// HookPostSyncBack - copy mode only: runs after the workspace is synced back into the project
+	HookPostSyncBack = "post_sync_back"
+)
+
+// HooksConfigModel - shell commands (run with sh -c) to run around the commands run by gows
+type HooksConfigModel struct {
+	PrePrepare   []string `json:"pre_prepare,omitempty" yaml:"pre_prepare,omitempty"`
+	PostPrepare  []string `json:"post_prepare,omitempty" yaml:"post_prepare,omitempty"`
+	PreCommand   []string `json:"pre_command,omitempty" yaml:"pre_command,omitempty"`
+	PostCommand  []string `json:"post_command,omitempty" yaml:"post_command,omitempty"`
+	PostSyncBack []string `json:"post_sync_back,omitempty" yaml:"post_sync_back,omitempty"`
+}
+
+// Commands returns the commands of the hook (HookPrePrepare, HookPostPrepare, ...)
+func (hooks HooksConfigModel) Commands(hook string) []string {
+	switch hook {
+	case HookPrePrepare:
+		return hooks.PrePrepare
+	case HookPostPrepare:
+		return hooks.PostPrepare
+	case HookPreCommand:
+		return hooks.PreCommand
+	case HookPostCommand:
+		return hooks.PostCommand
+	case HookPostSyncBack:
+		return hooks.PostSyncBack
+	}
+	return []string{}
 }
 
 // LoadProjectConfigFromFile ...
 func LoadProjectConfigFromFile() (ProjectConfigModel, error) {
-	projectConfigFileAbsPath, err := ProjectConfigFileAbsPath()
+	return LoadProjectConfigFromDir(".")
+}
+
+// LoadProjectConfigFromDir loads the project config (gows.yml) of the project in projectDir
+func LoadProjectConfigFromDir(projectDir string) (ProjectConfigModel, error) {
+	projectConfigFileAbsPath, err := pathutil.AbsPath(filepath.Join(projectDir, ProjectConfigFileName))
 	if err != nil {
 		return ProjectConfigModel{}, fmt.Errorf("Failed to get absolute path of project config: %s", err)
 	}
@@ -45,14 +167,20 @@ func LoadProjectConfigFromFile() (ProjectConfigModel, error) {
 
 // SaveProjectConfigToFile ...
 func SaveProjectConfigToFile(projectConf ProjectConfigModel) error {
+	return SaveProjectConfigToDir(".", projectConf)
+}
+
+// SaveProjectConfigToDir saves the project config (gows.yml) of the project in projectDir
+func SaveProjectConfigToDir(projectDir string, projectConf ProjectConfigModel) error {
 	bytes, err := yaml.Marshal(projectConf)
 	if err != nil {
 		return fmt.Errorf("Failed to parse Project Config (should be valid YML): %s", err)
 	}
 
-	err = fileutil.WriteBytesToFile(ProjectConfigFilePath, bytes)
+	projectConfigFilePath := filepath.Join(projectDir, ProjectConfigFileName)
+	err = fileutil.WriteBytesToFile(projectConfigFilePath, bytes)
 	if err != nil {
-		return fmt.Errorf("Failed to write Project Config into file (%s), error: %s", ProjectConfigFilePath, err)
+		return fmt.Errorf("Failed to write Project Config into file (%s), error: %s", projectConfigFilePath, err)
 	}
 
 	return nil
@@ -1 +1,60 @@
-package config
\ No newline at end of file
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_FindProjectRootDir(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gows-project-root-")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(tmpDir))
+	}()
+	tmpDir, err = filepath.EvalSymlinks(tmpDir)
+	require.NoError(t, err)
+
+	projectDir := filepath.Join(tmpDir, "project")
+	subDir := filepath.Join(projectDir, "cmd", "tool")
+	require.NoError(t, os.MkdirAll(subDir, 0755))
+
+	t.Log("No project config")
+	{
+		_, isFound, err := FindProjectRootDir(subDir)
+		require.NoError(t, err)
+		require.Equal(t, false, isFound)
+	}
+
+	require.NoError(t, SaveProjectConfigToDir(projectDir, ProjectConfigModel{PackageName: "github.com/bitrise-io/gows"}))
+
+	t.Log("From the project root")
+	{
+		rootDir, isFound, err := FindProjectRootDir(projectDir)
+		require.NoError(t, err)
+		require.Equal(t, true, isFound)
+		require.Equal(t, projectDir, rootDir)
+	}
+
+	t.Log("From a sub directory")
+	{
+		rootDir, isFound, err := FindProjectRootDir(subDir)
+		require.NoError(t, err)
+		require.Equal(t, true, isFound)
+		require.Equal(t, projectDir, rootDir)
+	}
+
+	t.Log("Nearest project config wins")
+	{
+		nestedProjectDir := filepath.Join(projectDir, "cmd")
+		require.NoError(t, SaveProjectConfigToDir(nestedProjectDir, ProjectConfigModel{PackageName: "github.com/bitrise-io/gows/cmd"}))
+
+		rootDir, isFound, err := FindProjectRootDir(subDir)
+		require.NoError(t, err)
+		require.Equal(t, true, isFound)
+		require.Equal(t, nestedProjectDir, rootDir)
+	}
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+)
+
+var projectIDLinePattern = regexp.MustCompile(`(?m)^project_id:.*$`)
+
+// NewProjectID generates a random project ID, which identifies the project
+// even if it's moved to another path (see: UserConfigModel.ProjectID)
+func NewProjectID() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("Failed to generate project ID, error: %s", err)
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// ProjectPathsForID returns the (sorted) paths of the projects registered with the project ID
+func (gowsConfig GOWSConfigModel) ProjectPathsForID(projectID string) []string {
+	projectPaths := []string{}
+	if projectID == "" {
+		return projectPaths
+	}
+	for projectPath, wsConfig := range gowsConfig.Workspaces {
+		if wsConfig.ProjectID == projectID {
+			projectPaths = append(projectPaths, projectPath)
+		}
+	}
+	sort.Strings(projectPaths)
+	return projectPaths
+}
+
+// SaveProjectIDToDir sets the project ID in the user config (.gows.user.yml) of the project in projectDir.
+// Only the project_id line of an existing user config is changed (or appended), the rest of the file is kept as is.
+func SaveProjectIDToDir(projectDir, projectID string) error {
+	userConfigFilePath := filepath.Join(projectDir, UserConfigFileName)
+	projectIDLine := "project_id: " + projectID
+
+	content, err := ioutil.ReadFile(userConfigFilePath)
+	if os.IsNotExist(err) {
+		userConfig := CreateDefaultUserConfig()
+		userConfig.ProjectID = projectID
+		return SaveUserConfigToDir(projectDir, userConfig)
+	} else if err != nil {
+		return fmt.Errorf("Failed to read user config file (%s), error: %s", userConfigFilePath, err)
+	}
+
+	contentStr := string(content)
+	if projectIDLinePattern.MatchString(contentStr) {
+		contentStr = projectIDLinePattern.ReplaceAllLiteralString(contentStr, projectIDLine)
+	} else {
+		if contentStr != "" && !strings.HasSuffix(contentStr, "\n") {
+			contentStr += "\n"
+		}
+		contentStr += projectIDLine + "\n"
+	}
+
+	if err := fileutil.WriteStringToFile(userConfigFilePath, contentStr); err != nil {
+		return fmt.Errorf("Failed to write user config file (%s), error: %s", userConfigFilePath, err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NewProjectID(t *testing.T) {
+	projectID, err := NewProjectID()
+	require.NoError(t, err)
+	require.Equal(t, 32, len(projectID))
+
+	otherProjectID, err := NewProjectID()
+	require.NoError(t, err)
+	require.NotEqual(t, projectID, otherProjectID)
+}
+
+func Test_ProjectPathsForID(t *testing.T) {
+	gowsConfig := GOWSConfigModel{
+		Workspaces: map[string]WorkspaceConfigModel{
+			"/proj/b": {WorkspaceRootPath: "/ws/b", ProjectID: "id-1"},
+			"/proj/a": {WorkspaceRootPath: "/ws/a", ProjectID: "id-1"},
+			"/proj/c": {WorkspaceRootPath: "/ws/c", ProjectID: "id-2"},
+			"/proj/d": {WorkspaceRootPath: "/ws/d"},
+		},
+	}
+
+	require.Equal(t, []string{"/proj/a", "/proj/b"}, gowsConfig.ProjectPathsForID("id-1"))
+	require.Equal(t, []string{"/proj/c"}, gowsConfig.ProjectPathsForID("id-2"))
+	require.Equal(t, []string{}, gowsConfig.ProjectPathsForID("id-3"))
+	require.Equal(t, []string{}, gowsConfig.ProjectPathsForID(""))
+}
+
+func Test_SaveProjectIDToDir(t *testing.T) {
+	projectDir, err := ioutil.TempDir("", "gows-projectid-")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(projectDir))
+	}()
+	userConfigFilePath := filepath.Join(projectDir, UserConfigFileName)
+
+	t.Log("No user config yet")
+	{
+		require.NoError(t, SaveProjectIDToDir(projectDir, "id-1"))
+		userConfig, err := LoadUserConfigFromDir(projectDir)
+		require.NoError(t, err)
+		require.Equal(t, "id-1", userConfig.ProjectID)
+		require.Equal(t, DefaultSyncMode, userConfig.SyncMode)
+	}
+
+	t.Log("The rest of the user config is kept")
+	{
+		require.NoError(t, ioutil.WriteFile(userConfigFilePath, []byte("# my settings\nsync_mode: copy"), 0644))
+		require.NoError(t, SaveProjectIDToDir(projectDir, "id-2"))
+		content, err := ioutil.ReadFile(userConfigFilePath)
+		require.NoError(t, err)
+		require.Equal(t, "# my settings\nsync_mode: copy\nproject_id: id-2\n", string(content))
+
+		require.NoError(t, SaveProjectIDToDir(projectDir, "id-3"))
+		content, err = ioutil.ReadFile(userConfigFilePath)
+		require.NoError(t, err)
+		require.Equal(t, "# my settings\nsync_mode: copy\nproject_id: id-3\n", string(content))
+
+		userConfig, err := LoadUserConfigFromDir(projectDir)
+		require.NoError(t, err)
+		require.Equal(t, "id-3", userConfig.ProjectID)
+		require.Equal(t, SyncModeCopy, userConfig.SyncMode)
+	}
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/pathutil"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	gowsRunLocksDirPath = "$HOME/.bitrise-gows/locks"
+)
+
+// RunLockHolderModel describes a gows command holding the run lock of a workspace
+type RunLockHolderModel struct {
+	PID         int       `json:"pid" yaml:"pid"`
+	Command     []string  `json:"command" yaml:"command"`
+	ProjectPath string    `json:"project_path" yaml:"project_path"`
+	SyncMode    string    `json:"sync_mode" yaml:"sync_mode"`
+	IsShared    bool      `json:"is_shared" yaml:"is_shared"`
+	AcquiredAt  time.Time `json:"acquired_at" yaml:"acquired_at"`
+}
+
+// RunLocksDirAbsPath ...
+func RunLocksDirAbsPath() (string, error) {
+	return pathutil.AbsPath(gowsRunLocksDirPath)
+}
+
+// RunLockFileAbsPath returns the path of the run lock file of the workspace
+func RunLockFileAbsPath(workspaceRootPath string) (string, error) {
+	runLocksDirAbsPath, err := RunLocksDirAbsPath()
+	if err != nil {
+		return "", err
+	}
+	workspaceRootPathHash := fmt.Sprintf("%x", sha1.Sum([]byte(workspaceRootPath)))
+	return filepath.Join(runLocksDirAbsPath, workspaceRootPathHash+".lock"), nil
+}
+
+func runLockHoldersDirAbsPath(workspaceRootPath string) (string, error) {
+	runLockFileAbsPath, err := RunLockFileAbsPath(workspaceRootPath)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(runLockFileAbsPath, ".lock") + ".holders", nil
+}
+
+// SaveRunLockHolder records the holder of the workspace's run lock
+func SaveRunLockHolder(workspaceRootPath string, holder RunLockHolderModel) error {
+	bytes, err := yaml.Marshal(holder)
+	if err != nil {
+		return fmt.Errorf("Failed to generate YML for run lock holder: %s", err)
+	}
+
+	holdersDirAbsPath, err := runLockHoldersDirAbsPath(workspaceRootPath)
+	if err != nil {
+		return fmt.Errorf("Failed to get absolute path of run lock holders dir: %s", err)
+	}
+	if err := pathutil.EnsureDirExist(holdersDirAbsPath); err != nil {
+		return fmt.Errorf("Failed to create run lock holders dir, error: %s", err)
+	}
+
+	holderFileAbsPath := filepath.Join(holdersDirAbsPath, strconv.Itoa(holder.PID)+".yml")
+	if err := writeBytesToFileAtomically(holderFileAbsPath, bytes); err != nil {
+		return fmt.Errorf("Failed to write run lock holder into file (%s), error: %s", holderFileAbsPath, err)
+	}
+	return nil
+}
+
+// RemoveRunLockHolder removes the run lock holder record of the process (if any)
+func RemoveRunLockHolder(workspaceRootPath string, pid int) error {
+	holdersDirAbsPath, err := runLockHoldersDirAbsPath(workspaceRootPath)
+	if err != nil {
+		return fmt.Errorf("Failed to get absolute path of run lock holders dir: %s", err)
+	}
+
+	holderFileAbsPath := filepath.Join(holdersDirAbsPath, strconv.Itoa(pid)+".yml")
+	if err := os.Remove(holderFileAbsPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Failed to remove run lock holder file (%s), error: %s", holderFileAbsPath, err)
+	}
+	return nil
+}
+
+// LoadRunLockHolders returns the recorded holders of the workspace's run lock.
+// The records of the processes killed while holding the lock are not removed,
+// the caller should check whether the process is still running.
+func LoadRunLockHolders(workspaceRootPath string) ([]RunLockHolderModel, error) {
+	holdersDirAbsPath, err := runLockHoldersDirAbsPath(workspaceRootPath)
+	if err != nil {
+		return []RunLockHolderModel{}, fmt.Errorf("Failed to get absolute path of run lock holders dir: %s", err)
+	}
+
+	fileInfos, err := ioutil.ReadDir(holdersDirAbsPath)
+	if os.IsNotExist(err) {
+		return []RunLockHolderModel{}, nil
+	} else if err != nil {
+		return []RunLockHolderModel{}, fmt.Errorf("Failed to list run lock holders (%s), error: %s", holdersDirAbsPath, err)
+	}
+
+	holders := []RunLockHolderModel{}
+	for _, fileInfo := range fileInfos {
+		if fileInfo.IsDir() || !strings.HasSuffix(fileInfo.Name(), ".yml") {
+			continue
+		}
+
+		pth := filepath.Join(holdersDirAbsPath, fileInfo.Name())
+		bytes, err := ioutil.ReadFile(pth)
+		if os.IsNotExist(err) {
+			// released in the meantime
+			continue
+		} else if err != nil {
+			return []RunLockHolderModel{}, fmt.Errorf("Failed to read run lock holder file (%s), error: %s", pth, err)
+		}
+
+		var holder RunLockHolderModel
+		if err := yaml.Unmarshal(bytes, &holder); err != nil {
+			return []RunLockHolderModel{}, fmt.Errorf("Failed to parse run lock holder (should be valid YML, path: %s), error: %s", pth, err)
+		}
+		holders = append(holders, holder)
+	}
+	return holders, nil
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"io/ioutil"
+	"path/filepath"
 
 	"github.com/bitrise-io/go-utils/fileutil"
 	"github.com/bitrise-io/go-utils/pathutil"
@@ -10,15 +11,50 @@ import (
 )
 
 const (
+	// UserConfigFileName ...
+	UserConfigFileName = ".gows.user.yml"
 	// UserConfigFilePath ...
-	UserConfigFilePath = "./.gows.user.yml"
+	UserConfigFilePath = "./" + UserConfigFileName
 
 	// SyncModeSymlink ...
 	SyncModeSymlink = "symlink"
 	// SyncModeCopy ...
 	SyncModeCopy = "copy"
+	// SyncModeMount - Linux only: the command runs in a user & mount namespace,
+	// in which the project is bind mounted into the workspace
+	SyncModeMount = "mount"
 	// DefaultSyncMode ...
 	DefaultSyncMode = SyncModeSymlink
+
+	// SyncEngineNative ...
+	SyncEngineNative = "native"
+	// SyncEngineRsync ...
+	SyncEngineRsync = "rsync"
+	// DefaultSyncEngine ...
+	DefaultSyncEngine = SyncEngineNative
+
+	// SyncConflictPolicyWorkspaceWins - the workspace version is synced back,
+	// the project version is kept as a .gows-conflict copy
+	SyncConflictPolicyWorkspaceWins = "workspace-wins"
+	// SyncConflictPolicyProjectWins - the project version is kept,
+	// the workspace version is saved as a .gows-conflict copy
+	SyncConflictPolicyProjectWins = "project-wins"
+	// SyncConflictPolicyAbort - nothing is synced back if there's a conflict
+	SyncConflictPolicyAbort = "abort"
+	// DefaultSyncConflictPolicy ...
+	DefaultSyncConflictPolicy = SyncConflictPolicyWorkspaceWins
+
+	// RunLockWait - wait (at most RunLockTimeout seconds) for the workspace to be released
+	RunLockWait = "wait"
+	// RunLockFailFast - fail if the workspace is in use by another gows command
+	RunLockFailFast = "fail-fast"
+	// RunLockShared - same as RunLockWait, except that in symlink mode
+	// more than one gows command can use the workspace at the same time
+	RunLockShared = "shared"
+	// DefaultRunLock ...
+	DefaultRunLock = RunLockShared
+	// DefaultRunLockTimeout - in seconds
+	DefaultRunLockTimeout = 600
 )
 
 // UserConfigFileAbsPath ...
@@ -29,6 +65,51 @@ func UserConfigFileAbsPath() (string, error) {
 // UserConfigModel - stored in ./.gows.user.yml
 type UserConfigModel struct {
 	SyncMode string `json:"sync_mode" yaml:"sync_mode"`
+	// SyncEngine is the engine used for syncing in copy mode: native (default) or rsync
+	SyncEngine string `json:"sync_engine,omitempty" yaml:"sync_engine,omitempty"`
+	// SyncChecksum - compare the content of the files in copy mode,
+	// not just their size and modification time
+	SyncChecksum bool `json:"sync_checksum,omitempty" yaml:"sync_checksum,omitempty"`
+	// SyncExclude - gitignore style patterns of paths not to sync in copy mode
+	SyncExclude []string `json:"sync_exclude,omitempty" yaml:"sync_exclude,omitempty"`
+	// SyncInclude - gitignore style patterns of paths to sync in copy mode,
+	// even if excluded by a SyncExclude or .gowsignore pattern.
+	// A path inside an excluded directory can't be re-included, just like in .gitignore
+	SyncInclude []string `json:"sync_include,omitempty" yaml:"sync_include,omitempty"`
+	// SyncConflictPolicy - what to do in copy mode with the files changed both in
+	// the project and in the workspace: workspace-wins (default), project-wins or abort
+	SyncConflictPolicy string `json:"sync_conflict_policy,omitempty" yaml:"sync_conflict_policy,omitempty"`
+	// RunLock - what to do if the workspace is in use by another gows command:
+	// wait, fail-fast or shared (default)
+	RunLock string `json:"run_lock,omitempty" yaml:"run_lock,omitempty"`
+	// RunLockTimeout - how long to wait for the workspace to be released, in seconds
+	RunLockTimeout int `json:"run_lock_timeout,omitempty" yaml:"run_lock_timeout,omitempty"`
+	// Env - environment variables to set for the commands run by gows,
+	// overrides the ones specified in the project config
+	Env map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+	// EnvFiles - .env files (relative to the project's directory) to load the environment variables from,
+	// a missing file is an error, unless it's marked as optional with a - prefix (e.g. -.env.local)
+	EnvFiles []string `json:"env_files,omitempty" yaml:"env_files,omitempty"`
+	// WorkspaceNameTemplate - text/template of the name of the workspace directories created for the project,
+	// see WorkspaceNameTemplateDataModel for the available data (default: DefaultWorkspaceNameTemplate)
+	WorkspaceNameTemplate string `json:"workspace_name_template,omitempty" yaml:"workspace_name_template,omitempty"`
+	// Hooks - shell commands to run around the commands run by gows,
+	// run after the hooks of the project config
+	Hooks HooksConfigModel `json:"hooks,omitempty" yaml:"hooks,omitempty"`
+	// ProjectID - generated by gows, identifies the project (and its workspace)
+	// even if the project is moved to another path
+	ProjectID string `json:"project_id,omitempty" yaml:"project_id,omitempty"`
+	// Links - local directories (e.g. the checkouts of libraries developed side by side with the project)
+	// linked into the workspace, instead of the packages fetched with go get (see: gows link)
+	Links []LinkConfigModel `json:"links,omitempty" yaml:"links,omitempty"`
+}
+
+// LinkConfigModel - a local directory linked into the workspace
+type LinkConfigModel struct {
+	// ImportPath - the directory is linked into GOPATH/src/<ImportPath> of the workspace
+	ImportPath string `json:"import_path" yaml:"import_path"`
+	// Path - the absolute path of the linked directory
+	Path string `json:"path" yaml:"path"`
 }
 
 // CreateDefaultUserConfig ...
@@ -40,7 +121,12 @@ func CreateDefaultUserConfig() UserConfigModel {
 
 // LoadUserConfigFromFile ...
 func LoadUserConfigFromFile() (UserConfigModel, error) {
-	UserConfigFileAbsPath, err := UserConfigFileAbsPath()
+	return LoadUserConfigFromDir(".")
+}
+
+// LoadUserConfigFromDir loads the user config (.gows.user.yml) of the project in projectDir
+func LoadUserConfigFromDir(projectDir string) (UserConfigModel, error) {
+	UserConfigFileAbsPath, err := pathutil.AbsPath(filepath.Join(projectDir, UserConfigFileName))
 	if err != nil {
 		return UserConfigModel{}, fmt.Errorf("Failed to get absolute path of project config: %s", err)
 	}
@@ -59,14 +145,20 @@ func LoadUserConfigFromFile() (UserConfigModel, error) {
 
 // SaveUserConfigToFile ...
 func SaveUserConfigToFile(projectConf UserConfigModel) error {
+	return SaveUserConfigToDir(".", projectConf)
+}
+
+// SaveUserConfigToDir saves the user config (.gows.user.yml) of the project in projectDir
+func SaveUserConfigToDir(projectDir string, projectConf UserConfigModel) error {
 	bytes, err := yaml.Marshal(projectConf)
 	if err != nil {
 		return fmt.Errorf("Failed to parse Project Config (should be valid YML): %s", err)
 	}
 
-	err = fileutil.WriteBytesToFile(UserConfigFilePath, bytes)
+	userConfigFilePath := filepath.Join(projectDir, UserConfigFileName)
+	err = fileutil.WriteBytesToFile(userConfigFilePath, bytes)
 	if err != nil {
-		return fmt.Errorf("Failed to write Project Config into file (%s), error: %s", UserConfigFilePath, err)
+		return fmt.Errorf("Failed to write Project Config into file (%s), error: %s", userConfigFilePath, err)
 	}
 
 	return nil
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultWorkspaceNameTemplate - the workspace directory is named after the project's directory,
+	// and the hash of the project's canonical path
+	DefaultWorkspaceNameTemplate = "{{.Name}}-{{.Hash}}"
+
+	// maxWorkspaceNameSuffix - at most this many numbered variants (name-2, name-3, ...)
+	// are tried if the generated workspace directory name is taken
+	maxWorkspaceNameSuffix = 100
+)
+
+// WorkspaceNameTemplateDataModel - the data available in the workspace name template
+type WorkspaceNameTemplateDataModel struct {
+	// Name - the base name of the project's directory
+	Name string
+	// Parent - the base name of the project's parent directory
+	Parent string
+	// Hash - the first 12 characters of FullHash
+	Hash string
+	// FullHash - the (hex) SHA1 hash of the project's canonical path
+	FullHash string
+	// Timestamp - the current unix timestamp (the naming of the workspaces created by earlier gows versions)
+	Timestamp int64
+}
+
+// WorkspaceDirName generates the name of the project's workspace directory, from the (text/template) nameTemplate.
+// The name only depends on the project's canonical path, unless the template includes the Timestamp.
+func WorkspaceDirName(nameTemplate, projectPath string) (string, error) {
+	if nameTemplate == "" {
+		nameTemplate = DefaultWorkspaceNameTemplate
+	}
+
+	canonicalProjectPath, err := CanonicalProjectPath(projectPath)
+	if err != nil {
+		return "", err
+	}
+
+	tmpl, err := template.New("workspace_name").Option("missingkey=error").Parse(nameTemplate)
+	if err != nil {
+		return "", fmt.Errorf("Invalid workspace name template (%s), error: %s", nameTemplate, err)
+	}
+
+	fullHash := fmt.Sprintf("%x", sha1.Sum([]byte(canonicalProjectPath)))
+	data := WorkspaceNameTemplateDataModel{
+		Name:      filepath.Base(canonicalProjectPath),
+		Parent:    filepath.Base(filepath.Dir(canonicalProjectPath)),
+		Hash:      fullHash[:12],
+		FullHash:  fullHash,
+		Timestamp: time.Now().Unix(),
+	}
+
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, data); err != nil {
+		return "", fmt.Errorf("Failed to generate workspace name from template (%s), error: %s", nameTemplate, err)
+	}
+
+	name := strings.TrimSpace(buffer.String())
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("Invalid workspace name (%s) generated from template (%s): it should be a single, non empty directory name", name, nameTemplate)
+	}
+	return name, nil
+}
+
+// ProjectsOfWorkspace returns the (sorted) paths of the projects registered with the workspace
+func (gowsConfig GOWSConfigModel) ProjectsOfWorkspace(workspaceRootPath string) []string {
+	projectPaths := []string{}
+	for projectPath, wsConfig := range gowsConfig.Workspaces {
+		if wsConfig.WorkspaceRootPath != "" && filepath.Clean(wsConfig.WorkspaceRootPath) == filepath.Clean(workspaceRootPath) {
+			projectPaths = append(projectPaths, projectPath)
+		}
+	}
+	sort.Strings(projectPaths)
+	return projectPaths
+}
+
+// AvailableWorkspaceRootPath returns the path of the project's workspace in workspacesRootDir, named dirName.
+// If that path is registered for another project, or it exists but it's not registered for the project,
+// numbered variants (dirName-2, dirName-3, ...) are tried.
+func (gowsConfig GOWSConfigModel) AvailableWorkspaceRootPath(workspacesRootDir, dirName, projectPath string) (string, error) {
+	canonicalProjectPath, err := CanonicalProjectPath(projectPath)
+	if err != nil {
+		return "", err
+	}
+
+	for i := 1; i <= maxWorkspaceNameSuffix; i++ {
+		name := dirName
+		if i > 1 {
+			name = fmt.Sprintf("%s-%d", dirName, i)
+		}
+		workspaceRootPath := filepath.Join(workspacesRootDir, name)
+
+		projectPaths := gowsConfig.ProjectsOfWorkspace(workspaceRootPath)
+		if len(projectPaths) > 0 {
+			if len(projectPaths) == 1 && projectPaths[0] == canonicalProjectPath {
+				return workspaceRootPath, nil
+			}
+			log.Debugf("Workspace (%s) is registered for another project (%s)", workspaceRootPath, strings.Join(projectPaths, ", "))
+			continue
+		}
+
+		if _, err := os.Lstat(workspaceRootPath); err == nil {
+			log.Debugf("Workspace (%s) already exists, but it's not registered for the project", workspaceRootPath)
+			continue
+		} else if !os.IsNotExist(err) {
+			return "", fmt.Errorf("Failed to check workspace (%s), error: %s", workspaceRootPath, err)
+		}
+
+		return workspaceRootPath, nil
+	}
+
+	return "", fmt.Errorf("Failed to find an available workspace directory name (%s, %s-2, ... %s-%d are all taken)", dirName, dirName, dirName, maxWorkspaceNameSuffix)
+}
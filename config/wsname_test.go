@@ -0,0 +1,141 @@
+package config
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WorkspaceDirName(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gows-wsname-")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(tmpDir))
+	}()
+	tmpDir, err = filepath.EvalSymlinks(tmpDir)
+	require.NoError(t, err)
+
+	projectDir := filepath.Join(tmpDir, "team-a", "api")
+	otherProjectDir := filepath.Join(tmpDir, "team-b", "api")
+	require.NoError(t, os.MkdirAll(projectDir, 0755))
+	require.NoError(t, os.MkdirAll(otherProjectDir, 0755))
+	projectLinkPth := filepath.Join(tmpDir, "api-link")
+	require.NoError(t, os.Symlink(projectDir, projectLinkPth))
+
+	projectHash := fmt.Sprintf("%x", sha1.Sum([]byte(projectDir)))
+
+	t.Log("Default template - readable prefix and the hash of the canonical project path")
+	{
+		name, err := WorkspaceDirName("", projectDir)
+		require.NoError(t, err)
+		require.Equal(t, "api-"+projectHash[:12], name)
+
+		t.Log("deterministic, and the same through a symlink")
+		{
+			linkName, err := WorkspaceDirName("", projectLinkPth)
+			require.NoError(t, err)
+			require.Equal(t, name, linkName)
+		}
+
+		t.Log("projects with the same name get different names")
+		{
+			otherName, err := WorkspaceDirName(DefaultWorkspaceNameTemplate, otherProjectDir)
+			require.NoError(t, err)
+			require.NotEqual(t, name, otherName)
+		}
+	}
+
+	t.Log("Custom template")
+	{
+		name, err := WorkspaceDirName("{{.Parent}}_{{.Name}}_{{.FullHash}}", projectDir)
+		require.NoError(t, err)
+		require.Equal(t, "team-a_api_"+projectHash, name)
+
+		name, err = WorkspaceDirName("{{.Name}}-{{.Timestamp}}", projectDir)
+		require.NoError(t, err)
+		require.Regexp(t, `^api-[0-9]+$`, name)
+		_, err = strconv.ParseInt(name[len("api-"):], 10, 64)
+		require.NoError(t, err)
+	}
+
+	t.Log("Invalid template")
+	{
+		_, err := WorkspaceDirName("{{.Name", projectDir)
+		require.Error(t, err)
+
+		_, err = WorkspaceDirName("{{.Unknown}}", projectDir)
+		require.Error(t, err)
+
+		_, err = WorkspaceDirName("{{.Parent}}/{{.Name}}", projectDir)
+		require.Error(t, err)
+
+		_, err = WorkspaceDirName("  ", projectDir)
+		require.Error(t, err)
+	}
+}
+
+func Test_GOWSConfigModel_AvailableWorkspaceRootPath(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gows-wsname-available-")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(tmpDir))
+	}()
+	tmpDir, err = filepath.EvalSymlinks(tmpDir)
+	require.NoError(t, err)
+
+	wsDirs := filepath.Join(tmpDir, "wsdirs")
+	projectDir := filepath.Join(tmpDir, "project")
+	require.NoError(t, os.MkdirAll(projectDir, 0755))
+
+	t.Log("Free")
+	{
+		gowsConfig := createDefaultGOWSConfigModel()
+		pth, err := gowsConfig.AvailableWorkspaceRootPath(wsDirs, "project-abc", projectDir)
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(wsDirs, "project-abc"), pth)
+	}
+
+	t.Log("Registered for the project itself")
+	{
+		gowsConfig := GOWSConfigModel{
+			Workspaces: map[string]WorkspaceConfigModel{
+				projectDir: {WorkspaceRootPath: filepath.Join(wsDirs, "project-abc")},
+			},
+		}
+		pth, err := gowsConfig.AvailableWorkspaceRootPath(wsDirs, "project-abc", projectDir)
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(wsDirs, "project-abc"), pth)
+	}
+
+	t.Log("Registered for another project, or exists but not registered")
+	{
+		gowsConfig := GOWSConfigModel{
+			Workspaces: map[string]WorkspaceConfigModel{
+				"/other/project": {WorkspaceRootPath: filepath.Join(wsDirs, "project-abc")},
+			},
+		}
+		require.NoError(t, os.MkdirAll(filepath.Join(wsDirs, "project-abc-2"), 0755))
+
+		pth, err := gowsConfig.AvailableWorkspaceRootPath(wsDirs, "project-abc", projectDir)
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(wsDirs, "project-abc-3"), pth)
+	}
+}
+
+func Test_GOWSConfigModel_ProjectsOfWorkspace(t *testing.T) {
+	gowsConfig := GOWSConfigModel{
+		Workspaces: map[string]WorkspaceConfigModel{
+			"/proj/b": {WorkspaceRootPath: "/ws/api-1500000000"},
+			"/proj/a": {WorkspaceRootPath: "/ws/api-1500000000/"},
+			"/proj/c": {WorkspaceRootPath: "/ws/other"},
+		},
+	}
+	require.Equal(t, []string{"/proj/a", "/proj/b"}, gowsConfig.ProjectsOfWorkspace("/ws/api-1500000000"))
+	require.Equal(t, []string{}, gowsConfig.ProjectsOfWorkspace("/ws/none"))
+}
@@ -0,0 +1,506 @@
+package dirsync
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	tmpFilePrefix = ".gows-sync-tmp-"
+)
+
+// Options ...
+type Options struct {
+	// Delete removes every file and directory from the target
+	// which does not exist in the source (like rsync --delete)
+	Delete bool
+	// CompareContent compares the content hash of the files which have
+	// the same size and modification time (like rsync --checksum)
+	CompareContent bool
+	// Exclude - paths matching these patterns are neither synced
+	// nor deleted from the target
+	Exclude *Matcher
+}
+
+// Stats - the summary of a sync run
+type Stats struct {
+	FilesCopied    int
+	FilesDeleted   int
+	SymlinksSynced int
+	DirsCreated    int
+	BytesCopied    int64
+}
+
+// ChangeType ...
+type ChangeType string
+
+const (
+	// ChangeAdded ...
+	ChangeAdded ChangeType = "added"
+	// ChangeModified ...
+	ChangeModified ChangeType = "modified"
+	// ChangeDeleted ...
+	ChangeDeleted ChangeType = "deleted"
+)
+
+// Change - a change a sync would do in the target
+type Change struct {
+	Path string
+	Type ChangeType
+}
+
+// String ...
+func (stats Stats) String() string {
+	return fmt.Sprintf("files copied: %d, symlinks synced: %d, dirs created: %d, deleted: %d, bytes copied: %d",
+		stats.FilesCopied, stats.SymlinksSynced, stats.DirsCreated, stats.FilesDeleted, stats.BytesCopied)
+}
+
+// SyncDirWithDir syncs the content of syncContentOf into syncIntoDir,
+// so that syncIntoDir will be a mirror of syncContentOf.
+// Symlinks are synced as symlinks (their targets are not followed),
+// file and directory permissions and modification times are preserved.
+func SyncDirWithDir(syncContentOf, syncIntoDir string, opts Options) (Stats, error) {
+	syncContentOf = filepath.Clean(syncContentOf)
+	syncIntoDir = filepath.Clean(syncIntoDir)
+
+	stats := Stats{}
+
+	srcInfo, err := os.Stat(syncContentOf)
+	if err != nil {
+		return stats, fmt.Errorf("Failed to check sync source (at: %s), error: %s", syncContentOf, err)
+	}
+	if !srcInfo.IsDir() {
+		return stats, fmt.Errorf("Sync source is not a directory: %s", syncContentOf)
+	}
+	if err := os.MkdirAll(syncIntoDir, 0777); err != nil {
+		return stats, fmt.Errorf("Failed to create target (at: %s), error: %s", syncIntoDir, err)
+	}
+
+	s := syncer{
+		srcRoot: syncContentOf,
+		dstRoot: syncIntoDir,
+		opts:    opts,
+		stats:   &stats,
+		srcRels: map[string]bool{},
+	}
+	if err := s.copyTree(); err != nil {
+		return stats, err
+	}
+	if opts.Delete {
+		if err := s.deleteExtraneous(); err != nil {
+			return stats, err
+		}
+	}
+	if err := s.restoreDirTimes(); err != nil {
+		return stats, err
+	}
+
+	return stats, nil
+}
+
+// Diff returns the changes SyncDirWithDir would do in syncIntoDir,
+// without changing anything
+func Diff(syncContentOf, syncIntoDir string, opts Options) ([]Change, error) {
+	syncContentOf = filepath.Clean(syncContentOf)
+	syncIntoDir = filepath.Clean(syncIntoDir)
+
+	s := syncer{
+		srcRoot: syncContentOf,
+		dstRoot: syncIntoDir,
+		opts:    opts,
+		stats:   &Stats{},
+		srcRels: map[string]bool{},
+		dryRun:  true,
+		changes: []Change{},
+	}
+	if err := s.copyTree(); err != nil {
+		return nil, err
+	}
+	if opts.Delete {
+		if isExists, err := isDirExists(syncIntoDir); err != nil {
+			return nil, err
+		} else if isExists {
+			if err := s.deleteExtraneous(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return s.changes, nil
+}
+
+func isDirExists(pth string) (bool, error) {
+	info, err := os.Stat(pth)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("Failed to check (at: %s), error: %s", pth, err)
+	}
+	return info.IsDir(), nil
+}
+
+type dirTime struct {
+	path    string
+	modTime time.Time
+}
+
+type syncer struct {
+	srcRoot string
+	dstRoot string
+	opts    Options
+	stats   *Stats
+
+	srcRels  map[string]bool
+	dirTimes []dirTime
+
+	// dryRun only records the changes, without doing them
+	dryRun  bool
+	changes []Change
+}
+
+func (s *syncer) recordChange(dstPth string, changeType ChangeType) {
+	relPth, err := filepath.Rel(s.dstRoot, dstPth)
+	if err != nil {
+		relPth = dstPth
+	}
+	s.changes = append(s.changes, Change{Path: relPth, Type: changeType})
+}
+
+func (s *syncer) copyTree() error {
+	return filepath.Walk(s.srcRoot, func(srcPth string, srcInfo os.FileInfo, walkErr error) error {
+		if walkErr != nil {
+			return fmt.Errorf("Failed to walk (at: %s), error: %s", srcPth, walkErr)
+		}
+
+		relPth, err := filepath.Rel(s.srcRoot, srcPth)
+		if err != nil {
+			return err
+		}
+		if relPth != "." && s.opts.Exclude.Match(relPth, srcInfo.IsDir()) {
+			if srcInfo.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		s.srcRels[relPth] = true
+		dstPth := filepath.Join(s.dstRoot, relPth)
+
+		mode := srcInfo.Mode()
+		switch {
+		case mode.IsDir():
+			return s.syncDir(srcInfo, dstPth)
+		case mode&os.ModeSymlink != 0:
+			return s.syncSymlink(srcPth, dstPth)
+		case mode.IsRegular():
+			return s.syncFile(srcPth, srcInfo, dstPth)
+		default:
+			// sockets, devices, named pipes, ... are not synced
+			return nil
+		}
+	})
+}
+
+func (s *syncer) syncDir(srcInfo os.FileInfo, dstPth string) error {
+	dstInfo, err := os.Lstat(dstPth)
+	if s.dryRun {
+		if err == nil && dstInfo.IsDir() {
+			return nil
+		} else if err == nil {
+			s.recordChange(dstPth, ChangeModified)
+		} else {
+			s.recordChange(dstPth, ChangeAdded)
+		}
+		return nil
+	}
+
+	if err == nil && !dstInfo.IsDir() {
+		// a file or symlink is in the way
+		if err := os.Remove(dstPth); err != nil {
+			return fmt.Errorf("Failed to remove (at: %s), error: %s", dstPth, err)
+		}
+		s.stats.FilesDeleted++
+		dstInfo = nil
+	} else if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Failed to check (at: %s), error: %s", dstPth, err)
+	}
+
+	if dstInfo == nil {
+		if err := os.Mkdir(dstPth, srcInfo.Mode().Perm()|0700); err != nil {
+			return fmt.Errorf("Failed to create directory (at: %s), error: %s", dstPth, err)
+		}
+		s.stats.DirsCreated++
+	}
+	if err := os.Chmod(dstPth, srcInfo.Mode().Perm()|0700); err != nil {
+		return fmt.Errorf("Failed to set permissions of directory (at: %s), error: %s", dstPth, err)
+	}
+
+	s.dirTimes = append(s.dirTimes, dirTime{path: dstPth, modTime: srcInfo.ModTime()})
+	return nil
+}
+
+func (s *syncer) syncSymlink(srcPth, dstPth string) error {
+	linkTarget, err := os.Readlink(srcPth)
+	if err != nil {
+		return fmt.Errorf("Failed to read symlink (at: %s), error: %s", srcPth, err)
+	}
+
+	if dstInfo, err := os.Lstat(dstPth); err == nil {
+		if dstInfo.Mode()&os.ModeSymlink != 0 {
+			if dstTarget, err := os.Readlink(dstPth); err == nil && dstTarget == linkTarget {
+				return nil
+			}
+		}
+		if s.dryRun {
+			s.recordChange(dstPth, ChangeModified)
+			return nil
+		}
+		if err := os.RemoveAll(dstPth); err != nil {
+			return fmt.Errorf("Failed to remove (at: %s), error: %s", dstPth, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("Failed to check (at: %s), error: %s", dstPth, err)
+	} else if s.dryRun {
+		s.recordChange(dstPth, ChangeAdded)
+		return nil
+	}
+
+	if err := os.Symlink(linkTarget, dstPth); err != nil {
+		return fmt.Errorf("Failed to create symlink (at: %s), error: %s", dstPth, err)
+	}
+	s.stats.SymlinksSynced++
+	return nil
+}
+
+func (s *syncer) syncFile(srcPth string, srcInfo os.FileInfo, dstPth string) error {
+	dstInfo, err := os.Lstat(dstPth)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Failed to check (at: %s), error: %s", dstPth, err)
+	}
+
+	if s.dryRun {
+		if os.IsNotExist(err) {
+			s.recordChange(dstPth, ChangeAdded)
+			return nil
+		}
+		if dstInfo.Mode().IsRegular() {
+			if isSame, err := s.isSameFile(srcPth, srcInfo, dstPth, dstInfo); err != nil {
+				return err
+			} else if isSame {
+				return nil
+			}
+		}
+		s.recordChange(dstPth, ChangeModified)
+		return nil
+	}
+
+	if err == nil {
+		if dstInfo.Mode().IsRegular() {
+			isSame, err := s.isSameFile(srcPth, srcInfo, dstPth, dstInfo)
+			if err != nil {
+				return err
+			}
+			if isSame {
+				if dstInfo.Mode().Perm() != srcInfo.Mode().Perm() {
+					if err := os.Chmod(dstPth, srcInfo.Mode().Perm()); err != nil {
+						return fmt.Errorf("Failed to set permissions of file (at: %s), error: %s", dstPth, err)
+					}
+				}
+				return nil
+			}
+		} else {
+			// a directory or symlink is in the way
+			if err := os.RemoveAll(dstPth); err != nil {
+				return fmt.Errorf("Failed to remove (at: %s), error: %s", dstPth, err)
+			}
+			s.stats.FilesDeleted++
+		}
+	}
+
+	written, err := copyFile(srcPth, srcInfo, dstPth)
+	if err != nil {
+		return err
+	}
+	s.stats.FilesCopied++
+	s.stats.BytesCopied += written
+	return nil
+}
+
+func (s *syncer) isSameFile(srcPth string, srcInfo os.FileInfo, dstPth string, dstInfo os.FileInfo) (bool, error) {
+	if srcInfo.Size() != dstInfo.Size() {
+		return false, nil
+	}
+	if !srcInfo.ModTime().Equal(dstInfo.ModTime()) {
+		return false, nil
+	}
+	if !s.opts.CompareContent {
+		return true, nil
+	}
+
+	srcHash, err := FileContentHash(srcPth)
+	if err != nil {
+		return false, err
+	}
+	dstHash, err := FileContentHash(dstPth)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(srcHash, dstHash), nil
+}
+
+// deleteExtraneous removes everything from the target which
+// was not found in the source, except the excluded paths.
+// An extraneous directory is only removed if it doesn't contain any excluded path.
+func (s *syncer) deleteExtraneous() error {
+	filesToRemove := []string{}
+	dirsToRemove := []string{}
+	keptDirs := map[string]bool{}
+	err := filepath.Walk(s.dstRoot, func(dstPth string, dstInfo os.FileInfo, walkErr error) error {
+		if walkErr != nil {
+			return fmt.Errorf("Failed to walk (at: %s), error: %s", dstPth, walkErr)
+		}
+
+		relPth, err := filepath.Rel(s.dstRoot, dstPth)
+		if err != nil {
+			return err
+		}
+		if s.srcRels[relPth] {
+			return nil
+		}
+		if s.opts.Exclude.Match(relPth, dstInfo.IsDir()) {
+			// excluded paths are never deleted, neither are the directories containing them
+			for dir := filepath.Dir(relPth); dir != "."; dir = filepath.Dir(dir) {
+				keptDirs[filepath.Join(s.dstRoot, dir)] = true
+			}
+			if dstInfo.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if dstInfo.IsDir() {
+			dirsToRemove = append(dirsToRemove, dstPth)
+		} else {
+			filesToRemove = append(filesToRemove, dstPth)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// the walk lists parent directories before their content,
+	// in reverse order a directory is removed after its sub directories
+	emptyDirs := []string{}
+	for i := len(dirsToRemove) - 1; i >= 0; i-- {
+		if !keptDirs[dirsToRemove[i]] {
+			emptyDirs = append(emptyDirs, dirsToRemove[i])
+		}
+	}
+
+	if s.dryRun {
+		for _, pth := range filesToRemove {
+			s.recordChange(pth, ChangeDeleted)
+		}
+		for _, pth := range emptyDirs {
+			s.recordChange(pth, ChangeDeleted)
+		}
+		return nil
+	}
+
+	for _, pth := range filesToRemove {
+		if err := os.Remove(pth); err != nil {
+			return fmt.Errorf("Failed to remove (at: %s), error: %s", pth, err)
+		}
+		s.stats.FilesDeleted++
+	}
+	for _, pth := range emptyDirs {
+		if err := os.Remove(pth); err != nil {
+			return fmt.Errorf("Failed to remove directory (at: %s), error: %s", pth, err)
+		}
+	}
+	return nil
+}
+
+func (s *syncer) restoreDirTimes() error {
+	// deepest first, so that setting the time of a child directory
+	// won't change the parent's modification time again
+	sort.SliceStable(s.dirTimes, func(i, j int) bool {
+		return strings.Count(s.dirTimes[i].path, string(filepath.Separator)) > strings.Count(s.dirTimes[j].path, string(filepath.Separator))
+	})
+	for _, dt := range s.dirTimes {
+		if err := os.Chtimes(dt.path, dt.modTime, dt.modTime); err != nil {
+			return fmt.Errorf("Failed to set modification time of directory (at: %s), error: %s", dt.path, err)
+		}
+	}
+	return nil
+}
+
+// copyFile copies the file through a temporary file in the target directory,
+// so that the target file is never left half-written
+func copyFile(srcPth string, srcInfo os.FileInfo, dstPth string) (int64, error) {
+	srcFile, err := os.Open(srcPth)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to open file (at: %s), error: %s", srcPth, err)
+	}
+	defer func() {
+		_ = srcFile.Close()
+	}()
+
+	tmpFile, err := ioutil.TempFile(filepath.Dir(dstPth), tmpFilePrefix)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to create temporary file for (%s), error: %s", dstPth, err)
+	}
+	tmpPth := tmpFile.Name()
+	isDone := false
+	defer func() {
+		if !isDone {
+			_ = tmpFile.Close()
+			_ = os.Remove(tmpPth)
+		}
+	}()
+
+	written, err := io.Copy(tmpFile, srcFile)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to copy (%s) to (%s), error: %s", srcPth, dstPth, err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return 0, fmt.Errorf("Failed to close temporary file (%s), error: %s", tmpPth, err)
+	}
+	if err := os.Chmod(tmpPth, srcInfo.Mode().Perm()); err != nil {
+		return 0, fmt.Errorf("Failed to set permissions of file (at: %s), error: %s", tmpPth, err)
+	}
+	if err := os.Chtimes(tmpPth, srcInfo.ModTime(), srcInfo.ModTime()); err != nil {
+		return 0, fmt.Errorf("Failed to set modification time of file (at: %s), error: %s", tmpPth, err)
+	}
+	if err := os.Rename(tmpPth, dstPth); err != nil {
+		return 0, fmt.Errorf("Failed to move temporary file (%s) to (%s), error: %s", tmpPth, dstPth, err)
+	}
+	isDone = true
+
+	return written, nil
+}
+
+// FileContentHash returns the SHA256 hash of the file's content
+func FileContentHash(pth string) ([]byte, error) {
+	f, err := os.Open(pth)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open file (at: %s), error: %s", pth, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, fmt.Errorf("Failed to read file (at: %s), error: %s", pth, err)
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,208 @@
+package dirsync
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestFile(t *testing.T, pth, content string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(pth), 0777))
+	require.NoError(t, ioutil.WriteFile(pth, []byte(content), 0644))
+}
+
+func readTestFile(t *testing.T, pth string) string {
+	b, err := ioutil.ReadFile(pth)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func Test_SyncDirWithDir(t *testing.T) {
+	srcDir, err := ioutil.TempDir("", "gows-dirsync-src-")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(srcDir))
+	}()
+	dstDir, err := ioutil.TempDir("", "gows-dirsync-dst-")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dstDir))
+	}()
+
+	writeTestFile(t, filepath.Join(srcDir, "main.go"), "package main")
+	writeTestFile(t, filepath.Join(srcDir, "pkg", "a", "a.go"), "package a")
+	require.NoError(t, os.Chmod(filepath.Join(srcDir, "pkg", "a", "a.go"), 0600))
+	require.NoError(t, os.Symlink("pkg/a", filepath.Join(srcDir, "alink")))
+
+	t.Log("Initial sync")
+	{
+		stats, err := SyncDirWithDir(srcDir, dstDir, Options{Delete: true})
+		require.NoError(t, err)
+		require.Equal(t, 2, stats.FilesCopied)
+		require.Equal(t, 1, stats.SymlinksSynced)
+		require.Equal(t, int64(len("package main")+len("package a")), stats.BytesCopied)
+
+		require.Equal(t, "package main", readTestFile(t, filepath.Join(dstDir, "main.go")))
+		require.Equal(t, "package a", readTestFile(t, filepath.Join(dstDir, "pkg", "a", "a.go")))
+
+		fileInfo, err := os.Stat(filepath.Join(dstDir, "pkg", "a", "a.go"))
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0600), fileInfo.Mode().Perm())
+
+		linkTarget, err := os.Readlink(filepath.Join(dstDir, "alink"))
+		require.NoError(t, err)
+		require.Equal(t, "pkg/a", linkTarget)
+	}
+
+	t.Log("Nothing changed - nothing to copy")
+	{
+		stats, err := SyncDirWithDir(srcDir, dstDir, Options{Delete: true, CompareContent: true})
+		require.NoError(t, err)
+		require.Equal(t, Stats{}, stats)
+	}
+
+	t.Log("Changed content with the same size and modification time - only detected with CompareContent")
+	{
+		pth := filepath.Join(dstDir, "main.go")
+		fileInfo, err := os.Stat(pth)
+		require.NoError(t, err)
+		writeTestFile(t, pth, "package xxxx")
+		require.NoError(t, os.Chtimes(pth, fileInfo.ModTime(), fileInfo.ModTime()))
+
+		stats, err := SyncDirWithDir(srcDir, dstDir, Options{Delete: true})
+		require.NoError(t, err)
+		require.Equal(t, 0, stats.FilesCopied)
+		require.Equal(t, "package xxxx", readTestFile(t, pth))
+
+		stats, err = SyncDirWithDir(srcDir, dstDir, Options{Delete: true, CompareContent: true})
+		require.NoError(t, err)
+		require.Equal(t, 1, stats.FilesCopied)
+		require.Equal(t, "package main", readTestFile(t, pth))
+	}
+
+	t.Log("Modified and removed files")
+	{
+		writeTestFile(t, filepath.Join(srcDir, "main.go"), "package main // modified")
+		future := time.Now().Add(time.Hour)
+		require.NoError(t, os.Chtimes(filepath.Join(srcDir, "main.go"), future, future))
+		require.NoError(t, os.RemoveAll(filepath.Join(srcDir, "pkg")))
+		writeTestFile(t, filepath.Join(dstDir, "extra", "file.txt"), "extra")
+
+		stats, err := SyncDirWithDir(srcDir, dstDir, Options{Delete: true})
+		require.NoError(t, err)
+		require.Equal(t, 1, stats.FilesCopied)
+		require.Equal(t, 2, stats.FilesDeleted)
+
+		require.Equal(t, "package main // modified", readTestFile(t, filepath.Join(dstDir, "main.go")))
+		_, err = os.Lstat(filepath.Join(dstDir, "pkg"))
+		require.True(t, os.IsNotExist(err))
+		_, err = os.Lstat(filepath.Join(dstDir, "extra"))
+		require.True(t, os.IsNotExist(err))
+	}
+
+	t.Log("Without Delete extraneous files are kept")
+	{
+		writeTestFile(t, filepath.Join(dstDir, "extra.txt"), "extra")
+
+		stats, err := SyncDirWithDir(srcDir, dstDir, Options{})
+		require.NoError(t, err)
+		require.Equal(t, 0, stats.FilesDeleted)
+		require.Equal(t, "extra", readTestFile(t, filepath.Join(dstDir, "extra.txt")))
+	}
+
+	t.Log("A directory in the target is replaced by a file")
+	{
+		writeTestFile(t, filepath.Join(dstDir, "replaced", "sub.txt"), "sub")
+		writeTestFile(t, filepath.Join(srcDir, "replaced"), "now a file")
+
+		_, err := SyncDirWithDir(srcDir, dstDir, Options{Delete: true})
+		require.NoError(t, err)
+		require.Equal(t, "now a file", readTestFile(t, filepath.Join(dstDir, "replaced")))
+	}
+}
+
+func Test_SyncDirWithDir_Exclude(t *testing.T) {
+	srcDir, err := ioutil.TempDir("", "gows-dirsync-src-")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(srcDir))
+	}()
+	dstDir, err := ioutil.TempDir("", "gows-dirsync-dst-")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dstDir))
+	}()
+
+	writeTestFile(t, filepath.Join(srcDir, "main.go"), "package main")
+	writeTestFile(t, filepath.Join(srcDir, ".git", "HEAD"), "ref: refs/heads/master")
+	writeTestFile(t, filepath.Join(srcDir, "build", "out.bin"), "binary")
+	writeTestFile(t, filepath.Join(dstDir, "build", "ws-only.bin"), "only in the workspace")
+
+	exclude, err := NewMatcher([]string{".git/", "build/"})
+	require.NoError(t, err)
+
+	stats, err := SyncDirWithDir(srcDir, dstDir, Options{Delete: true, Exclude: exclude})
+	require.NoError(t, err)
+	require.Equal(t, 1, stats.FilesCopied)
+	require.Equal(t, 0, stats.FilesDeleted)
+
+	_, err = os.Lstat(filepath.Join(dstDir, ".git"))
+	require.True(t, os.IsNotExist(err))
+	_, err = os.Lstat(filepath.Join(dstDir, "build", "out.bin"))
+	require.True(t, os.IsNotExist(err))
+	// excluded paths are not deleted from the target
+	require.Equal(t, "only in the workspace", readTestFile(t, filepath.Join(dstDir, "build", "ws-only.bin")))
+}
+
+func Test_SyncDirWithDir_ExcludeInExtraneousDir(t *testing.T) {
+	srcDir, err := ioutil.TempDir("", "gows-dirsync-src-")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(srcDir))
+	}()
+	dstDir, err := ioutil.TempDir("", "gows-dirsync-dst-")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dstDir))
+	}()
+
+	writeTestFile(t, filepath.Join(srcDir, "main.go"), "package main")
+	// foo/ is only in the workspace, with excluded paths in it
+	writeTestFile(t, filepath.Join(dstDir, "foo", "foo.go"), "package foo")
+	writeTestFile(t, filepath.Join(dstDir, "foo", ".env"), "KEY=value")
+	writeTestFile(t, filepath.Join(dstDir, "foo", "build", "out.bin"), "binary")
+	writeTestFile(t, filepath.Join(dstDir, "foo", "sub", "sub.go"), "package sub")
+
+	exclude, err := NewMatcher([]string{".env", "build/"})
+	require.NoError(t, err)
+
+	t.Log("Diff lists the non excluded paths")
+	{
+		changes, err := Diff(srcDir, dstDir, Options{Delete: true, Exclude: exclude})
+		require.NoError(t, err)
+		require.Equal(t, []Change{
+			{Path: "main.go", Type: ChangeAdded},
+			{Path: filepath.Join("foo", "foo.go"), Type: ChangeDeleted},
+			{Path: filepath.Join("foo", "sub", "sub.go"), Type: ChangeDeleted},
+			{Path: filepath.Join("foo", "sub"), Type: ChangeDeleted},
+		}, changes)
+	}
+
+	t.Log("Only the non excluded paths are deleted")
+	{
+		stats, err := SyncDirWithDir(srcDir, dstDir, Options{Delete: true, Exclude: exclude})
+		require.NoError(t, err)
+		require.Equal(t, 2, stats.FilesDeleted)
+
+		require.Equal(t, "KEY=value", readTestFile(t, filepath.Join(dstDir, "foo", ".env")))
+		require.Equal(t, "binary", readTestFile(t, filepath.Join(dstDir, "foo", "build", "out.bin")))
+		_, err = os.Lstat(filepath.Join(dstDir, "foo", "foo.go"))
+		require.True(t, os.IsNotExist(err))
+		_, err = os.Lstat(filepath.Join(dstDir, "foo", "sub"))
+		require.True(t, os.IsNotExist(err))
+	}
+}
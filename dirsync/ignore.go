@@ -0,0 +1,184 @@
+package dirsync
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Pattern - a single gitignore style pattern
+type Pattern struct {
+	Raw      string
+	Negate   bool
+	DirOnly  bool
+	Anchored bool
+
+	// glob is the pattern without the negation, the leading and the trailing slash
+	glob  string
+	regex *regexp.Regexp
+}
+
+// Matcher matches paths against a list of gitignore style patterns.
+// As in .gitignore the last matching pattern decides,
+// and a negated pattern (!pattern) re-includes a previously excluded path.
+type Matcher struct {
+	patterns []Pattern
+}
+
+// NewMatcher creates a Matcher from gitignore style pattern lines.
+// Empty lines and comments (lines starting with #) are skipped.
+func NewMatcher(lines []string) (*Matcher, error) {
+	m := &Matcher{}
+	for _, line := range lines {
+		p, ok, err := ParsePattern(line)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			m.patterns = append(m.patterns, p)
+		}
+	}
+	return m, nil
+}
+
+// Patterns ...
+func (m *Matcher) Patterns() []Pattern {
+	if m == nil {
+		return nil
+	}
+	return m.patterns
+}
+
+// Match returns true if the (slash or OS separated) path,
+// relative to the sync root, is excluded
+func (m *Matcher) Match(relPath string, isDir bool) bool {
+	if m == nil {
+		return false
+	}
+	relPath = filepath.ToSlash(relPath)
+
+	isExcluded := false
+	for _, p := range m.patterns {
+		if p.DirOnly && !isDir {
+			continue
+		}
+		if p.regex.MatchString(relPath) {
+			isExcluded = !p.Negate
+		}
+	}
+	return isExcluded
+}
+
+// ParsePattern parses a single gitignore style pattern line.
+// Returns false if the line does not contain a pattern (empty line or comment).
+func ParsePattern(line string) (Pattern, bool, error) {
+	p := Pattern{Raw: line}
+
+	line = strings.TrimRight(line, "\r\n")
+	if !strings.HasSuffix(line, "\\ ") {
+		line = strings.TrimRight(line, " \t")
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return Pattern{}, false, nil
+	}
+
+	if strings.HasPrefix(line, "!") {
+		p.Negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		p.DirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.HasPrefix(line, "/") {
+		p.Anchored = true
+		line = strings.TrimLeft(line, "/")
+	} else if strings.Contains(line, "/") {
+		// a slash in the middle anchors the pattern, just like in .gitignore
+		p.Anchored = true
+	}
+	if line == "" {
+		return Pattern{}, false, nil
+	}
+	p.glob = line
+
+	expr := globToRegexp(line)
+	if p.Anchored {
+		expr = "^" + expr + "$"
+	} else {
+		expr = "^(?:.*/)?" + expr + "$"
+	}
+	regex, err := regexp.Compile(expr)
+	if err != nil {
+		return Pattern{}, false, fmt.Errorf("Invalid pattern (%s), error: %s", p.Raw, err)
+	}
+	p.regex = regex
+
+	return p, true, nil
+}
+
+// RsyncFilterRule returns the equivalent rsync filter rule of the pattern
+func (p Pattern) RsyncFilterRule() string {
+	rule := "- "
+	if p.Negate {
+		rule = "+ "
+	}
+	if p.Anchored {
+		rule += "/"
+	}
+	rule += p.glob
+	if p.DirOnly {
+		rule += "/"
+	}
+	return rule
+}
+
+func globToRegexp(glob string) string {
+	var buf strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				switch {
+				case i+2 < len(glob) && glob[i+2] == '/':
+					// **/ - zero or more directories
+					buf.WriteString("(?:.*/)?")
+					i += 2
+				default:
+					// ** - anything, including slashes
+					buf.WriteString(".*")
+					i++
+				}
+			} else {
+				buf.WriteString("[^/]*")
+			}
+		case '?':
+			buf.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				buf.WriteString(regexp.QuoteMeta(string(c)))
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			buf.WriteString("[" + class + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				buf.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return buf.String()
+}
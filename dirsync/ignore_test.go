@@ -0,0 +1,73 @@
+package dirsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Matcher_Match(t *testing.T) {
+	t.Log("Simple patterns - match at any level")
+	{
+		m, err := NewMatcher([]string{"# comment", "", "*.log", ".git/", "tmp"})
+		require.NoError(t, err)
+		require.Equal(t, 3, len(m.Patterns()))
+
+		require.Equal(t, true, m.Match("build.log", false))
+		require.Equal(t, true, m.Match("a/b/build.log", false))
+		require.Equal(t, true, m.Match(".git", true))
+		require.Equal(t, true, m.Match("sub/.git", true))
+		require.Equal(t, false, m.Match(".git", false))
+		require.Equal(t, true, m.Match("tmp", false))
+		require.Equal(t, true, m.Match("a/tmp", true))
+		require.Equal(t, false, m.Match("main.go", false))
+		require.Equal(t, false, m.Match("tmpfile", false))
+	}
+
+	t.Log("Anchored patterns")
+	{
+		m, err := NewMatcher([]string{"/bin", "testdata/fixtures/", "docs/**/*.png"})
+		require.NoError(t, err)
+
+		require.Equal(t, true, m.Match("bin", true))
+		require.Equal(t, false, m.Match("cmd/bin", true))
+		require.Equal(t, true, m.Match("testdata/fixtures", true))
+		require.Equal(t, false, m.Match("a/testdata/fixtures", true))
+		require.Equal(t, true, m.Match("docs/a.png", false))
+		require.Equal(t, true, m.Match("docs/a/b/c.png", false))
+		require.Equal(t, false, m.Match("docs/a.jpg", false))
+	}
+
+	t.Log("Negation - the last matching pattern wins")
+	{
+		m, err := NewMatcher([]string{"*.txt", "!keep.txt"})
+		require.NoError(t, err)
+
+		require.Equal(t, true, m.Match("a.txt", false))
+		require.Equal(t, false, m.Match("keep.txt", false))
+		require.Equal(t, false, m.Match("sub/keep.txt", false))
+	}
+
+	t.Log("Nil matcher excludes nothing")
+	{
+		var m *Matcher
+		require.Equal(t, false, m.Match("a.txt", false))
+	}
+}
+
+func Test_Pattern_RsyncFilterRule(t *testing.T) {
+	testMap := map[string]string{
+		"*.log":     "- *.log",
+		"/bin":      "- /bin",
+		".git/":     "- .git/",
+		"!keep.txt": "+ keep.txt",
+		"a/b":       "- /a/b",
+	}
+
+	for line, rule := range testMap {
+		p, ok, err := ParsePattern(line)
+		require.NoError(t, err)
+		require.Equal(t, true, ok)
+		require.Equal(t, rule, p.RsyncFilterRule())
+	}
+}
@@ -0,0 +1,276 @@
+package dirsync
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Fingerprint of a file or symlink
+type Fingerprint struct {
+	IsSymlink  bool        `json:"is_symlink,omitempty"`
+	LinkTarget string      `json:"link_target,omitempty"`
+	Size       int64       `json:"size"`
+	ModTime    int64       `json:"mod_time"`
+	Mode       os.FileMode `json:"mode"`
+	// Hash is the content hash, only calculated with Options.CompareContent
+	Hash string `json:"hash,omitempty"`
+}
+
+// Equal ...
+func (fp Fingerprint) Equal(other Fingerprint) bool {
+	if fp.IsSymlink != other.IsSymlink {
+		return false
+	}
+	if fp.IsSymlink {
+		return fp.LinkTarget == other.LinkTarget
+	}
+	if fp.Size != other.Size || fp.ModTime != other.ModTime || fp.Mode != other.Mode {
+		return false
+	}
+	if fp.Hash != "" && other.Hash != "" {
+		return fp.Hash == other.Hash
+	}
+	return true
+}
+
+// Snapshot - file and symlink fingerprints, by path relative to the snapshot root
+type Snapshot map[string]Fingerprint
+
+// TakeSnapshot fingerprints every (not excluded) file and symlink in the root directory
+func TakeSnapshot(root string, opts Options) (Snapshot, error) {
+	root = filepath.Clean(root)
+	snapshot := Snapshot{}
+
+	err := filepath.Walk(root, func(pth string, info os.FileInfo, walkErr error) error {
+		if walkErr != nil {
+			return fmt.Errorf("Failed to walk (at: %s), error: %s", pth, walkErr)
+		}
+		relPth, err := filepath.Rel(root, pth)
+		if err != nil {
+			return err
+		}
+		if relPth == "." {
+			return nil
+		}
+		if opts.Exclude.Match(relPth, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		fp, ok, err := fingerprintOf(pth, info, opts.CompareContent)
+		if err != nil {
+			return err
+		}
+		if ok {
+			snapshot[relPth] = fp
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+func fingerprintOf(pth string, info os.FileInfo, withHash bool) (Fingerprint, bool, error) {
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		linkTarget, err := os.Readlink(pth)
+		if err != nil {
+			return Fingerprint{}, false, fmt.Errorf("Failed to read symlink (at: %s), error: %s", pth, err)
+		}
+		return Fingerprint{IsSymlink: true, LinkTarget: linkTarget}, true, nil
+	case info.Mode().IsRegular():
+		fp := Fingerprint{
+			Size:    info.Size(),
+			ModTime: info.ModTime().UnixNano(),
+			Mode:    info.Mode().Perm(),
+		}
+		if withHash {
+			hash, err := FileContentHash(pth)
+			if err != nil {
+				return Fingerprint{}, false, err
+			}
+			fp.Hash = hex.EncodeToString(hash)
+		}
+		return fp, true, nil
+	default:
+		return Fingerprint{}, false, nil
+	}
+}
+
+// LoadSnapshotFromFile ...
+func LoadSnapshotFromFile(pth string) (Snapshot, error) {
+	content, err := ioutil.ReadFile(pth)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := Snapshot{}
+	if err := json.Unmarshal(content, &snapshot); err != nil {
+		return nil, fmt.Errorf("Failed to parse snapshot (should be valid JSON, path: %s), error: %s", pth, err)
+	}
+	return snapshot, nil
+}
+
+// SaveToFile ...
+func (snapshot Snapshot) SaveToFile(pth string) error {
+	content, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("Failed to generate JSON for snapshot: %s", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(pth), 0777); err != nil {
+		return fmt.Errorf("Failed to create directory for snapshot (%s), error: %s", pth, err)
+	}
+	if err := ioutil.WriteFile(pth, content, 0600); err != nil {
+		return fmt.Errorf("Failed to write snapshot into file (%s), error: %s", pth, err)
+	}
+	return nil
+}
+
+// Conflict - a path changed on both sides since the snapshot, differently
+type Conflict struct {
+	Path            string
+	ProjectChange   ChangeType
+	WorkspaceChange ChangeType
+	IsInProject     bool
+	IsInWorkspace   bool
+}
+
+// DetectConflicts compares the current state of the project and the workspace
+// with the snapshot taken of the project when it was synced into the workspace,
+// and returns the paths which were changed on both sides, differently.
+func DetectConflicts(snapshot Snapshot, projectRoot, workspaceRoot string, opts Options) ([]Conflict, error) {
+	projectSnapshot, err := TakeSnapshot(projectRoot, opts)
+	if err != nil {
+		return nil, err
+	}
+	workspaceSnapshot, err := TakeSnapshot(workspaceRoot, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := map[string]bool{}
+	for _, s := range []Snapshot{snapshot, projectSnapshot, workspaceSnapshot} {
+		for pth := range s {
+			paths[pth] = true
+		}
+	}
+
+	conflicts := []Conflict{}
+	for pth := range paths {
+		origFp, isInOrig := snapshot[pth]
+		projectFp, isInProject := projectSnapshot[pth]
+		workspaceFp, isInWorkspace := workspaceSnapshot[pth]
+
+		projectChange, isProjectChanged := changeSince(origFp, isInOrig, projectFp, isInProject)
+		workspaceChange, isWorkspaceChanged := changeSince(origFp, isInOrig, workspaceFp, isInWorkspace)
+		if !isProjectChanged || !isWorkspaceChanged {
+			continue
+		}
+
+		// changed on both sides, but to the same
+		if !isInProject && !isInWorkspace {
+			continue
+		}
+		if isInProject && isInWorkspace {
+			isSame, err := isSameContent(filepath.Join(projectRoot, pth), projectFp, filepath.Join(workspaceRoot, pth), workspaceFp)
+			if err != nil {
+				return nil, err
+			}
+			if isSame {
+				continue
+			}
+		}
+
+		conflicts = append(conflicts, Conflict{
+			Path:            pth,
+			ProjectChange:   projectChange,
+			WorkspaceChange: workspaceChange,
+			IsInProject:     isInProject,
+			IsInWorkspace:   isInWorkspace,
+		})
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].Path < conflicts[j].Path
+	})
+
+	return conflicts, nil
+}
+
+func changeSince(origFp Fingerprint, isInOrig bool, currFp Fingerprint, isInCurr bool) (ChangeType, bool) {
+	switch {
+	case !isInOrig && !isInCurr:
+		return "", false
+	case !isInOrig:
+		return ChangeAdded, true
+	case !isInCurr:
+		return ChangeDeleted, true
+	case !origFp.Equal(currFp):
+		return ChangeModified, true
+	default:
+		return "", false
+	}
+}
+
+func isSameContent(pth1 string, fp1 Fingerprint, pth2 string, fp2 Fingerprint) (bool, error) {
+	if fp1.IsSymlink || fp2.IsSymlink {
+		return fp1.IsSymlink == fp2.IsSymlink && fp1.LinkTarget == fp2.LinkTarget, nil
+	}
+	if fp1.Size != fp2.Size {
+		return false, nil
+	}
+	hash1, err := FileContentHash(pth1)
+	if err != nil {
+		return false, err
+	}
+	hash2, err := FileContentHash(pth2)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(hash1, hash2), nil
+}
+
+// CopyPath copies a file or symlink, preserving its permissions and modification time.
+// The parent directory of the target is created if it does not exist yet.
+func CopyPath(srcPth, dstPth string) error {
+	srcInfo, err := os.Lstat(srcPth)
+	if err != nil {
+		return fmt.Errorf("Failed to check (at: %s), error: %s", srcPth, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dstPth), 0777); err != nil {
+		return fmt.Errorf("Failed to create directory for (%s), error: %s", dstPth, err)
+	}
+	if err := os.RemoveAll(dstPth); err != nil {
+		return fmt.Errorf("Failed to remove (at: %s), error: %s", dstPth, err)
+	}
+
+	switch {
+	case srcInfo.Mode()&os.ModeSymlink != 0:
+		linkTarget, err := os.Readlink(srcPth)
+		if err != nil {
+			return fmt.Errorf("Failed to read symlink (at: %s), error: %s", srcPth, err)
+		}
+		if err := os.Symlink(linkTarget, dstPth); err != nil {
+			return fmt.Errorf("Failed to create symlink (at: %s), error: %s", dstPth, err)
+		}
+		return nil
+	case srcInfo.Mode().IsRegular():
+		_, err := copyFile(srcPth, srcInfo, dstPth)
+		return err
+	default:
+		return fmt.Errorf("Not a file or symlink: %s", srcPth)
+	}
+}
@@ -0,0 +1,78 @@
+package dirsync
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_DetectConflicts(t *testing.T) {
+	projectDir, err := ioutil.TempDir("", "gows-dirsync-project-")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(projectDir))
+	}()
+	workspaceDir, err := ioutil.TempDir("", "gows-dirsync-workspace-")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(workspaceDir))
+	}()
+
+	for _, name := range []string{"both.go", "same.go", "project-only.go", "workspace-only.go", "deleted.go"} {
+		writeTestFile(t, filepath.Join(projectDir, name), "package orig")
+	}
+
+	snapshot, err := TakeSnapshot(projectDir, Options{})
+	require.NoError(t, err)
+	require.Equal(t, 5, len(snapshot))
+
+	_, err = SyncDirWithDir(projectDir, workspaceDir, Options{Delete: true})
+	require.NoError(t, err)
+
+	modify := func(pth, content string) {
+		writeTestFile(t, pth, content)
+		future := time.Now().Add(time.Hour)
+		require.NoError(t, os.Chtimes(pth, future, future))
+	}
+	modify(filepath.Join(projectDir, "both.go"), "package project")
+	modify(filepath.Join(workspaceDir, "both.go"), "package workspace")
+	modify(filepath.Join(projectDir, "same.go"), "package same")
+	modify(filepath.Join(workspaceDir, "same.go"), "package same")
+	modify(filepath.Join(projectDir, "project-only.go"), "package project")
+	modify(filepath.Join(workspaceDir, "workspace-only.go"), "package workspace")
+	require.NoError(t, os.Remove(filepath.Join(projectDir, "deleted.go")))
+	modify(filepath.Join(workspaceDir, "deleted.go"), "package workspace")
+	modify(filepath.Join(projectDir, "new.go"), "package project")
+	modify(filepath.Join(workspaceDir, "new.go"), "package workspace")
+
+	conflicts, err := DetectConflicts(snapshot, projectDir, workspaceDir, Options{})
+	require.NoError(t, err)
+	require.Equal(t, []Conflict{
+		{Path: "both.go", ProjectChange: ChangeModified, WorkspaceChange: ChangeModified, IsInProject: true, IsInWorkspace: true},
+		{Path: "deleted.go", ProjectChange: ChangeDeleted, WorkspaceChange: ChangeModified, IsInProject: false, IsInWorkspace: true},
+		{Path: "new.go", ProjectChange: ChangeAdded, WorkspaceChange: ChangeAdded, IsInProject: true, IsInWorkspace: true},
+	}, conflicts)
+}
+
+func Test_Snapshot_SaveToFile(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gows-dirsync-snapshot-")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(tmpDir))
+	}()
+
+	snapshot := Snapshot{
+		"main.go": Fingerprint{Size: 12, ModTime: 1000, Mode: 0644, Hash: "abcd"},
+		"link":    Fingerprint{IsSymlink: true, LinkTarget: "main.go"},
+	}
+	pth := filepath.Join(tmpDir, "sub", "snapshot.json")
+	require.NoError(t, snapshot.SaveToFile(pth))
+
+	loaded, err := LoadSnapshotFromFile(pth)
+	require.NoError(t, err)
+	require.Equal(t, snapshot, loaded)
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
@@ -86,20 +87,136 @@ func filteredEnvsList(envsList []string, ignoreEnv string) []string {
 // CreateCommand creates a command, prepared to run
 // in the isolated workspace environment.
 func CreateCommand(cmdWorkdir string, gopath string, cmdName string, cmdArgs ...string) *exec.Cmd {
+	return createCommand(cmdWorkdir, gopath, CommandOptions{}, cmdName, cmdArgs...)
+}
+
+// DefaultHermeticEnvAllowlist - the environment variables kept in hermetic mode, in addition to the allowlisted ones
+var DefaultHermeticEnvAllowlist = []string{"PATH", "HOME", "USER", "LOGNAME", "SHELL", "TERM", "TMPDIR", "LANG", "LC_*", "TZ"}
+
+// HermeticOptions - the command only gets the allowlisted environment variables
+// (and the ones specified in CommandOptions.Env)
+type HermeticOptions struct {
+	// EnvAllowlist - names of the environment variables to keep, in addition to DefaultHermeticEnvAllowlist.
+	// A name ending with * matches every variable with that prefix.
+	EnvAllowlist []string
+	// IsolateNetwork - run the command in a new (Linux) network namespace, without network access
+	IsolateNetwork bool
+}
+
+// CommandOptions ...
+type CommandOptions struct {
+	// Env - environment variables to set for the command
+	Env map[string]string
+	// Hermetic - if not nil the command runs in hermetic mode
+	Hermetic *HermeticOptions
+}
+
+// CreateCommandWithOptions creates a command, prepared to run
+// in the isolated workspace environment, with the specified options.
+func CreateCommandWithOptions(cmdWorkdir string, gopath string, opts CommandOptions, cmdName string, cmdArgs ...string) (*exec.Cmd, error) {
+	cmd := createCommand(cmdWorkdir, gopath, opts, cmdName, cmdArgs...)
+
+	if opts.Hermetic != nil && opts.Hermetic.IsolateNetwork {
+		if err := isolateCommandNetwork(cmd); err != nil {
+			return nil, fmt.Errorf("Failed to isolate the network of the command: %s", err)
+		}
+	}
+
+	return cmd, nil
+}
+
+func createCommand(cmdWorkdir string, gopath string, opts CommandOptions, cmdName string, cmdArgs ...string) *exec.Cmd {
 	cmd := exec.Command(cmdName, cmdArgs...)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Dir = cmdWorkdir
 	//
-	cmdEnvs := os.Environ()
+	cmdEnvs := BaseEnvironment(opts)
 	cmdEnvs = filteredEnvsList(cmdEnvs, "GOPATH")
 	cmdEnvs = filteredEnvsList(cmdEnvs, "PWD")
+	for _, key := range sortedKeys(opts.Env) {
+		cmdEnvs = filteredEnvsList(cmdEnvs, key)
+		cmdEnvs = append(cmdEnvs, fmt.Sprintf("%s=%s", key, opts.Env[key]))
+	}
 	cmdEnvs = append(cmdEnvs,
 		fmt.Sprintf("GOPATH=%s", gopath),
 		fmt.Sprintf("PWD=%s", cmdWorkdir),
 	)
 	cmd.Env = cmdEnvs
 
+	if log.IsLevelEnabled(log.DebugLevel) {
+		for _, line := range envsListDiff(os.Environ(), cmdEnvs) {
+			log.Debugf("[CreateCommand] env: %s", line)
+		}
+	}
+
 	return cmd
 }
+
+// BaseEnvironment returns the environment the command's environment is based on:
+// the current process's environment, in hermetic mode only its allowlisted variables
+func BaseEnvironment(opts CommandOptions) []string {
+	envs := os.Environ()
+	if opts.Hermetic != nil {
+		envs = allowlistedEnvsList(envs, append(DefaultHermeticEnvAllowlist, opts.Hermetic.EnvAllowlist...))
+	}
+	return envs
+}
+
+// envsListDiff lists the added (+), changed (~) and removed (-) env items
+func envsListDiff(fromEnvsList, toEnvsList []string) []string {
+	fromEnvs := envsListToMap(fromEnvsList)
+	toEnvs := envsListToMap(toEnvsList)
+
+	diff := []string{}
+	for _, key := range sortedKeys(toEnvs) {
+		fromValue, isFound := fromEnvs[key]
+		if !isFound {
+			diff = append(diff, fmt.Sprintf("+ %s=%s", key, toEnvs[key]))
+		} else if fromValue != toEnvs[key] {
+			diff = append(diff, fmt.Sprintf("~ %s=%s", key, toEnvs[key]))
+		}
+	}
+	for _, key := range sortedKeys(fromEnvs) {
+		if _, isFound := toEnvs[key]; !isFound {
+			diff = append(diff, fmt.Sprintf("- %s", key))
+		}
+	}
+	return diff
+}
+
+func envsListToMap(envsList []string) map[string]string {
+	envs := map[string]string{}
+	for _, envItem := range envsList {
+		split := strings.SplitN(envItem, "=", 2)
+		if len(split) == 2 {
+			envs[split[0]] = split[1]
+		}
+	}
+	return envs
+}
+
+// allowlistedEnvsList returns the env items whose key is allowlisted
+func allowlistedEnvsList(envsList []string, allowlist []string) []string {
+	allowlistedEnvs := []string{}
+	for _, envItem := range envsList {
+		key := strings.SplitN(envItem, "=", 2)[0]
+		for _, allowed := range allowlist {
+			if key == allowed || (strings.HasSuffix(allowed, "*") && strings.HasPrefix(key, strings.TrimSuffix(allowed, "*"))) {
+				allowlistedEnvs = append(allowlistedEnvs, envItem)
+				break
+			}
+		}
+	}
+	return allowlistedEnvs
+}
+
+func sortedKeys(envs map[string]string) []string {
+	keys := []string{}
+	for key := range envs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,6 +1,8 @@
 package gows
 
 import (
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -37,3 +39,66 @@ func Test_filteredEnvsList(t *testing.T) {
 		require.Equal(t, []string{"env2=value two"}, filteredEnvs)
 	}
 }
+
+func Test_allowlistedEnvsList(t *testing.T) {
+	inputEnvs := []string{"PATH=/bin", "GOFLAGS=-mod=vendor", "LC_ALL=C", "LC_CTYPE=UTF-8", "LCX=1", "HOME=/home/user"}
+
+	t.Log("Exact names and prefixes")
+	{
+		allowlistedEnvs := allowlistedEnvsList(inputEnvs, []string{"PATH", "HOME", "LC_*"})
+		require.Equal(t, []string{"PATH=/bin", "LC_ALL=C", "LC_CTYPE=UTF-8", "HOME=/home/user"}, allowlistedEnvs)
+	}
+
+	t.Log("Empty allowlist")
+	{
+		allowlistedEnvs := allowlistedEnvsList(inputEnvs, []string{})
+		require.Equal(t, []string{}, allowlistedEnvs)
+	}
+}
+
+func Test_CreateCommandWithOptions(t *testing.T) {
+	origGOFLAGS := os.Getenv("GOFLAGS")
+	require.NoError(t, os.Setenv("GOFLAGS", "-mod=vendor"))
+	defer func() {
+		require.NoError(t, os.Setenv("GOFLAGS", origGOFLAGS))
+	}()
+
+	t.Log("Not hermetic - env overrides")
+	{
+		cmd, err := CreateCommandWithOptions("/ws/src/pkg", "/ws", CommandOptions{Env: map[string]string{"CGO_ENABLED": "0", "GOFLAGS": "-race"}}, "go", "build")
+		require.NoError(t, err)
+		require.Contains(t, cmd.Env, "CGO_ENABLED=0")
+		require.Contains(t, cmd.Env, "GOFLAGS=-race")
+		require.NotContains(t, cmd.Env, "GOFLAGS=-mod=vendor")
+		require.Contains(t, cmd.Env, "GOPATH=/ws")
+		require.Contains(t, cmd.Env, "PWD=/ws/src/pkg")
+	}
+
+	t.Log("Hermetic - only the allowlisted envs are kept")
+	{
+		cmd, err := CreateCommandWithOptions("/ws/src/pkg", "/ws", CommandOptions{
+			Env:      map[string]string{"CGO_ENABLED": "0"},
+			Hermetic: &HermeticOptions{},
+		}, "go", "build")
+		require.NoError(t, err)
+		require.Contains(t, cmd.Env, "CGO_ENABLED=0")
+		require.Contains(t, cmd.Env, "GOPATH=/ws")
+		for _, envItem := range cmd.Env {
+			require.False(t, strings.HasPrefix(envItem, "GOFLAGS="), envItem)
+		}
+
+		cmd, err = CreateCommandWithOptions("/ws/src/pkg", "/ws", CommandOptions{
+			Hermetic: &HermeticOptions{EnvAllowlist: []string{"GOFLAGS"}},
+		}, "go", "build")
+		require.NoError(t, err)
+		require.Contains(t, cmd.Env, "GOFLAGS=-mod=vendor")
+	}
+}
+
+func Test_envsListDiff(t *testing.T) {
+	diff := envsListDiff(
+		[]string{"KEPT=1", "CHANGED=old", "REMOVED=1"},
+		[]string{"KEPT=1", "CHANGED=new", "ADDED=1"},
+	)
+	require.Equal(t, []string{"+ ADDED=1", "~ CHANGED=new", "- REMOVED"}, diff)
+}
@@ -0,0 +1,99 @@
+//go:build linux
+// +build linux
+
+package gows
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// CheckMountNamespaceSupport returns an error if the current user can't create
+// user (and mount) namespaces - e.g. if unprivileged user namespaces are disabled
+func CheckMountNamespaceSupport() error {
+	if bytes, err := ioutil.ReadFile("/proc/sys/user/max_user_namespaces"); err == nil && strings.TrimSpace(string(bytes)) == "0" {
+		return errors.New("user namespaces are disabled on this system (user.max_user_namespaces is 0)")
+	}
+	if os.Geteuid() != 0 {
+		// Debian and Ubuntu specific setting
+		if bytes, err := ioutil.ReadFile("/proc/sys/kernel/unprivileged_userns_clone"); err == nil && strings.TrimSpace(string(bytes)) == "0" {
+			return errors.New("unprivileged user namespaces are disabled on this system (kernel.unprivileged_userns_clone is 0)")
+		}
+	}
+	return nil
+}
+
+// CreateMountNamespaceCommand creates a command, prepared to run in the isolated workspace environment,
+// inside a new (unprivileged) user and mount namespace.
+// The command is started through gows's mount namespace helper (helperArgs are the arguments of the gows
+// executable to call the helper with), which bind mounts the project into the workspace and then runs the command.
+func CreateMountNamespaceCommand(cmdWorkdir string, gopath string, projectDir string, opts CommandOptions, helperArgs ...string) (*exec.Cmd, error) {
+	if err := CheckMountNamespaceSupport(); err != nil {
+		return nil, err
+	}
+
+	gowsExecutablePth, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get the path of the gows executable, error: %s", err)
+	}
+
+	cmd, err := CreateCommandWithOptions(cmdWorkdir, gopath, opts, gowsExecutablePth, helperArgs...)
+	if err != nil {
+		return nil, err
+	}
+	// the command's work dir only exists inside the namespace, once the project is mounted
+	cmd.Dir = projectDir
+	addUserNamespace(cmd, syscall.CLONE_NEWNS)
+
+	return cmd, nil
+}
+
+// isolateCommandNetwork makes the command to run in a new user & network namespace,
+// which only has a (down) loopback interface
+func isolateCommandNetwork(cmd *exec.Cmd) error {
+	if err := CheckMountNamespaceSupport(); err != nil {
+		return err
+	}
+	addUserNamespace(cmd, syscall.CLONE_NEWNET)
+	return nil
+}
+
+// addUserNamespace makes the command to run in a new user namespace (and in the other
+// namespaces specified by cloneFlags), in which the user keeps its own uid / gid
+func addUserNamespace(cmd *exec.Cmd, cloneFlags uintptr) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	if cmd.SysProcAttr.Cloneflags&syscall.CLONE_NEWUSER == 0 {
+		cmd.SysProcAttr.UidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}}
+		cmd.SysProcAttr.GidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}}
+	}
+	cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWUSER | cloneFlags
+}
+
+// ExecInMountNamespace bind mounts the project directory to the mount point (the project's path inside the workspace),
+// and replaces the current process with the command, started in cmdWorkdir.
+// Should be called inside the mount namespace created by CreateMountNamespaceCommand.
+func ExecInMountNamespace(projectDir, mountPointPth, cmdWorkdir, cmdName string, cmdArgs ...string) error {
+	// the mounts of the namespace should not propagate to the parent namespace
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("Failed to make the mounts of the namespace private, error: %s", err)
+	}
+	if err := syscall.Mount(projectDir, mountPointPth, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("Failed to bind mount the project (%s) to (%s), error: %s", projectDir, mountPointPth, err)
+	}
+	if err := os.Chdir(cmdWorkdir); err != nil {
+		return fmt.Errorf("Failed to change directory to (%s), error: %s", cmdWorkdir, err)
+	}
+
+	cmdPth, err := exec.LookPath(cmdName)
+	if err != nil {
+		return err
+	}
+	return syscall.Exec(cmdPth, append([]string{cmdName}, cmdArgs...), os.Environ())
+}
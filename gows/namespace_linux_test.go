@@ -0,0 +1,65 @@
+//go:build linux
+// +build linux
+
+package gows
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test_MountNamespaceHelperProcess isn't a real test, it's used by Test_CreateMountNamespaceCommand
+// as the mount namespace helper
+func Test_MountNamespaceHelperProcess(t *testing.T) {
+	helperArgs := os.Getenv("GOWS_TEST_MOUNT_NAMESPACE_HELPER")
+	if helperArgs == "" {
+		return
+	}
+
+	args := strings.Split(helperArgs, "|")
+	err := ExecInMountNamespace(args[0], args[1], args[1], "cat", "file.txt")
+	require.NoError(t, err)
+}
+
+func Test_CreateMountNamespaceCommand(t *testing.T) {
+	if err := CheckMountNamespaceSupport(); err != nil {
+		t.Skipf("mount namespaces are not supported: %s", err)
+	}
+
+	tmpDir, err := ioutil.TempDir("", "gows-mountns-")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(tmpDir))
+	}()
+
+	projectDir := filepath.Join(tmpDir, "project")
+	require.NoError(t, os.MkdirAll(projectDir, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(projectDir, "file.txt"), []byte("project content"), 0644))
+	mountPointPth := filepath.Join(tmpDir, "ws", "src", "github.com", "bitrise-io", "project")
+	require.NoError(t, os.MkdirAll(mountPointPth, 0755))
+
+	cmd, err := CreateMountNamespaceCommand(mountPointPth, filepath.Join(tmpDir, "ws"), projectDir, CommandOptions{}, "-test.run=^Test_MountNamespaceHelperProcess$")
+	require.NoError(t, err)
+	cmd.Env = append(cmd.Env, "GOWS_TEST_MOUNT_NAMESPACE_HELPER="+projectDir+"|"+mountPointPth)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+
+	if err := cmd.Start(); err != nil {
+		t.Skipf("failed to create user namespace: %s", err)
+	}
+	require.NoError(t, cmd.Wait())
+	require.Equal(t, "project content", stdout.String())
+
+	t.Log("the mount is not visible outside of the namespace")
+	{
+		fileInfos, err := ioutil.ReadDir(mountPointPth)
+		require.NoError(t, err)
+		require.Equal(t, 0, len(fileInfos))
+	}
+}
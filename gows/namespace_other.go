@@ -0,0 +1,32 @@
+//go:build !linux
+// +build !linux
+
+package gows
+
+import (
+	"errors"
+	"os/exec"
+)
+
+var errMountNamespaceNotSupported = errors.New("mount namespaces are only supported on Linux")
+
+// CheckMountNamespaceSupport returns an error if the current user can't create
+// user (and mount) namespaces - always, except on Linux
+func CheckMountNamespaceSupport() error {
+	return errMountNamespaceNotSupported
+}
+
+// CreateMountNamespaceCommand is only supported on Linux
+func CreateMountNamespaceCommand(cmdWorkdir string, gopath string, projectDir string, opts CommandOptions, helperArgs ...string) (*exec.Cmd, error) {
+	return nil, errMountNamespaceNotSupported
+}
+
+// isolateCommandNetwork is only supported on Linux
+func isolateCommandNetwork(cmd *exec.Cmd) error {
+	return errors.New("network isolation is only supported on Linux")
+}
+
+// ExecInMountNamespace is only supported on Linux
+func ExecInMountNamespace(projectDir, mountPointPth, cmdWorkdir, cmdName string, cmdArgs ...string) error {
+	return errMountNamespaceNotSupported
+}
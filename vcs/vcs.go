@@ -0,0 +1,260 @@
+package vcs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// Git ...
+	Git = "git"
+	// Mercurial ...
+	Mercurial = "hg"
+)
+
+// Checkout is a VCS checkout of a package, inside a GOPATH/src directory
+type Checkout struct {
+	// ImportPath - the path of the checkout relative to GOPATH/src
+	ImportPath string
+	// Dir - the absolute path of the checkout
+	Dir string
+	// VCS - git or hg
+	VCS string
+}
+
+// vcsOfDir returns the VCS of the checkout in dir, or an empty string if dir is not a checkout
+func vcsOfDir(dir string) string {
+	if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+		// .git is a directory, or a file in the checkouts of a git worktree / submodule
+		return Git
+	}
+	if fileInfo, err := os.Lstat(filepath.Join(dir, ".hg")); err == nil && fileInfo.IsDir() {
+		return Mercurial
+	}
+	return ""
+}
+
+// FindCheckouts returns the VCS checkouts inside the GOPATH/src directory, sorted by import path.
+// Symlinks are not followed, and the checkouts nested into another checkout are not listed.
+// The import paths listed in skipImportPaths (e.g. the project's own package) are skipped.
+func FindCheckouts(srcDir string, skipImportPaths []string) ([]Checkout, error) {
+	checkouts := []Checkout{}
+	err := filepath.Walk(srcDir, func(pth string, fileInfo os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && pth == srcDir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !fileInfo.IsDir() || pth == srcDir {
+			return nil
+		}
+
+		relPth, err := filepath.Rel(srcDir, pth)
+		if err != nil {
+			return err
+		}
+		importPath := filepath.ToSlash(relPth)
+		for _, skipImportPath := range skipImportPaths {
+			if importPath == skipImportPath {
+				return filepath.SkipDir
+			}
+		}
+
+		if vcs := vcsOfDir(pth); vcs != "" {
+			checkouts = append(checkouts, Checkout{ImportPath: importPath, Dir: pth, VCS: vcs})
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return []Checkout{}, fmt.Errorf("Failed to find the checkouts in (%s), error: %s", srcDir, err)
+	}
+
+	sort.Slice(checkouts, func(i, j int) bool {
+		return checkouts[i].ImportPath < checkouts[j].ImportPath
+	})
+	return checkouts, nil
+}
+
+// runVCSCommand runs the VCS command in dir, and returns its (trimmed) output
+func runVCSCommand(dir, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	log.Debugf("[runVCSCommand] $ %s %s (in: %s)", name, strings.Join(args, " "), dir)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("Command failed: $ %s %s (in: %s), error: %s, output: %s", name, strings.Join(args, " "), dir, err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Remote returns the URL of the checkout's default remote (origin), or an empty string if it has none
+func (checkout Checkout) Remote() (string, error) {
+	name, args := "", []string{}
+	switch checkout.VCS {
+	case Git:
+		name, args = "git", []string{"config", "--get", "remote.origin.url"}
+	case Mercurial:
+		name, args = "hg", []string{"paths", "default"}
+	default:
+		return "", fmt.Errorf("Unsupported VCS: %s", checkout.VCS)
+	}
+
+	cmd := exec.Command(name, args...)
+	cmd.Dir = checkout.Dir
+	out, err := cmd.Output()
+	if exitError, ok := err.(*exec.ExitError); ok && exitError.ExitCode() == 1 {
+		// the remote is not set
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("Command failed: $ %s %s (in: %s), error: %s", name, strings.Join(args, " "), checkout.Dir, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Revision returns the revision (commit hash / changeset ID) checked out
+func (checkout Checkout) Revision() (string, error) {
+	switch checkout.VCS {
+	case Git:
+		return runVCSCommand(checkout.Dir, "git", "rev-parse", "HEAD")
+	case Mercurial:
+		return runVCSCommand(checkout.Dir, "hg", "log", "-r", ".", "--template", "{node}")
+	}
+	return "", fmt.Errorf("Unsupported VCS: %s", checkout.VCS)
+}
+
+// IsDirty returns true if the checkout has uncommitted changes
+func (checkout Checkout) IsDirty() (bool, error) {
+	out := ""
+	var err error
+	switch checkout.VCS {
+	case Git:
+		out, err = runVCSCommand(checkout.Dir, "git", "status", "--porcelain")
+	case Mercurial:
+		out, err = runVCSCommand(checkout.Dir, "hg", "status")
+	default:
+		return false, fmt.Errorf("Unsupported VCS: %s", checkout.VCS)
+	}
+	if err != nil {
+		return false, err
+	}
+	return out != "", nil
+}
+
+// Restore checks out the revision into dir.
+// If dir is a checkout already the missing revision is fetched into it,
+// otherwise the first of the sources (local mirrors or remotes) which has the revision is cloned into dir.
+// The remote (origin) of a new checkout is set to remote.
+func Restore(vcs, dir, remote, revision string, sources []string) error {
+	switch vcs {
+	case Git, Mercurial:
+	default:
+		return fmt.Errorf("Unsupported VCS: %s", vcs)
+	}
+
+	if vcsOfDir(dir) == vcs {
+		checkout := Checkout{Dir: dir, VCS: vcs}
+		if currentRevision, err := checkout.Revision(); err == nil && currentRevision == revision {
+			return nil
+		}
+		if isDirty, err := checkout.IsDirty(); err != nil {
+			return err
+		} else if isDirty {
+			return fmt.Errorf("The checkout (%s) has uncommitted changes", dir)
+		}
+
+		errs := []string{}
+		for _, source := range sources {
+			if err := fetchAndCheckout(vcs, dir, source, revision); err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+			return nil
+		}
+		return fmt.Errorf("Failed to check out revision %s in (%s): %s", revision, dir, strings.Join(errs, "; "))
+	}
+
+	if _, err := os.Lstat(dir); err == nil {
+		return fmt.Errorf("The directory (%s) exists, but it's not a %s checkout", dir, vcs)
+	}
+
+	errs := []string{}
+	for _, source := range sources {
+		if err := cloneAndCheckout(vcs, dir, source, remote, revision); err != nil {
+			errs = append(errs, err.Error())
+			if err := os.RemoveAll(dir); err != nil {
+				return fmt.Errorf("Failed to remove the failed checkout (%s), error: %s", dir, err)
+			}
+			continue
+		}
+		return nil
+	}
+	return fmt.Errorf("Failed to clone revision %s into (%s): %s", revision, dir, strings.Join(errs, "; "))
+}
+
+func cloneAndCheckout(vcs, dir, source, remote, revision string) error {
+	if err := os.MkdirAll(filepath.Dir(dir), 0777); err != nil {
+		return fmt.Errorf("Failed to create the parent directory of (%s), error: %s", dir, err)
+	}
+
+	switch vcs {
+	case Git:
+		if _, err := runVCSCommand(filepath.Dir(dir), "git", "clone", "--quiet", "--no-checkout", "--", source, dir); err != nil {
+			return err
+		}
+		if _, err := runVCSCommand(dir, "git", "checkout", "--quiet", revision); err != nil {
+			return err
+		}
+		if remote != "" && remote != source {
+			if _, err := runVCSCommand(dir, "git", "remote", "set-url", "origin", remote); err != nil {
+				return err
+			}
+		}
+	case Mercurial:
+		if _, err := runVCSCommand(filepath.Dir(dir), "hg", "clone", "--quiet", "--noupdate", "--", source, dir); err != nil {
+			return err
+		}
+		if _, err := runVCSCommand(dir, "hg", "update", "--quiet", "--rev", revision); err != nil {
+			return err
+		}
+		if remote != "" && remote != source {
+			hgrc := fmt.Sprintf("[paths]\ndefault = %s\n", remote)
+			if err := ioutil.WriteFile(filepath.Join(dir, ".hg", "hgrc"), []byte(hgrc), 0644); err != nil {
+				return fmt.Errorf("Failed to set the default path of (%s), error: %s", dir, err)
+			}
+		}
+	}
+	return nil
+}
+
+func fetchAndCheckout(vcs, dir, source, revision string) error {
+	switch vcs {
+	case Git:
+		// the revision might be available already
+		if _, err := runVCSCommand(dir, "git", "cat-file", "-e", revision+"^{commit}"); err != nil {
+			// the branches are only fetched into FETCH_HEAD, the remote-tracking branches of origin are not changed
+			if _, err := runVCSCommand(dir, "git", "fetch", "--quiet", "--tags", "--", source, "refs/heads/*"); err != nil {
+				return err
+			}
+		}
+		if _, err := runVCSCommand(dir, "git", "checkout", "--quiet", revision); err != nil {
+			return err
+		}
+	case Mercurial:
+		if _, err := runVCSCommand(dir, "hg", "pull", "--quiet", "--", source); err != nil {
+			return err
+		}
+		if _, err := runVCSCommand(dir, "hg", "update", "--quiet", "--rev", revision); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package vcs
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	args = append([]string{"-c", "user.name=gows", "-c", "user.email=gows@example.com", "-c", "init.defaultBranch=master"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	return string(out)
+}
+
+// createRemote creates a bare git repository with two commits, and returns its path and revisions
+func createRemote(t *testing.T, tmpDir, name string) (string, []string) {
+	remotePath := filepath.Join(tmpDir, "remotes", name+".git")
+	require.NoError(t, os.MkdirAll(remotePath, 0755))
+	runGit(t, remotePath, "init", "--quiet", "--bare")
+
+	workPath := filepath.Join(tmpDir, "work", name)
+	runGit(t, tmpDir, "clone", "--quiet", remotePath, workPath)
+	revisions := []string{}
+	for _, content := range []string{"v1", "v2"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(workPath, "file.go"), []byte(content), 0644))
+		runGit(t, workPath, "add", "file.go")
+		runGit(t, workPath, "commit", "--quiet", "-m", content)
+		revision, err := Checkout{Dir: workPath, VCS: Git}.Revision()
+		require.NoError(t, err)
+		revisions = append(revisions, revision)
+	}
+	runGit(t, workPath, "push", "--quiet", "origin", "HEAD:master")
+	return remotePath, revisions
+}
+
+func Test_FindCheckouts(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gows-vcs-")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(tmpDir))
+	}()
+
+	srcDir := filepath.Join(tmpDir, "src")
+	remotePath, revisions := createRemote(t, tmpDir, "lib")
+	runGit(t, tmpDir, "clone", "--quiet", remotePath, filepath.Join(srcDir, "github.com", "org", "lib"))
+	// nested checkout - not listed
+	runGit(t, tmpDir, "clone", "--quiet", remotePath, filepath.Join(srcDir, "github.com", "org", "lib", "nested"))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(srcDir, "github.com", "org", "lib", ".git", "info", "exclude"), []byte("nested/\n"), 0644))
+	// skipped import path (e.g. the project)
+	runGit(t, tmpDir, "clone", "--quiet", remotePath, filepath.Join(srcDir, "github.com", "org", "project"))
+	// symlink - not followed
+	require.NoError(t, os.Symlink(filepath.Join(srcDir, "github.com", "org", "lib"), filepath.Join(srcDir, "github.com", "org", "linked")))
+	// no remote
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "example.com", "local"), 0755))
+	runGit(t, filepath.Join(srcDir, "example.com", "local"), "init", "--quiet")
+
+	checkouts, err := FindCheckouts(srcDir, []string{"github.com/org/project"})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(checkouts))
+
+	require.Equal(t, "example.com/local", checkouts[0].ImportPath)
+	remote, err := checkouts[0].Remote()
+	require.NoError(t, err)
+	require.Equal(t, "", remote)
+
+	require.Equal(t, "github.com/org/lib", checkouts[1].ImportPath)
+	require.Equal(t, Git, checkouts[1].VCS)
+	remote, err = checkouts[1].Remote()
+	require.NoError(t, err)
+	require.Equal(t, remotePath, remote)
+	revision, err := checkouts[1].Revision()
+	require.NoError(t, err)
+	require.Equal(t, revisions[1], revision)
+	isDirty, err := checkouts[1].IsDirty()
+	require.NoError(t, err)
+	require.Equal(t, false, isDirty)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(checkouts[1].Dir, "file.go"), []byte("changed"), 0644))
+	isDirty, err = checkouts[1].IsDirty()
+	require.NoError(t, err)
+	require.Equal(t, true, isDirty)
+
+	checkouts, err = FindCheckouts(filepath.Join(tmpDir, "no-src"), nil)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(checkouts))
+}
+
+func Test_Restore(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gows-vcs-")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(tmpDir))
+	}()
+
+	remotePath, revisions := createRemote(t, tmpDir, "lib")
+	dir := filepath.Join(tmpDir, "src", "github.com", "org", "lib")
+
+	t.Log("Clone - the first source with the revision is used, the remote is set")
+	{
+		err := Restore(Git, dir, remotePath, revisions[0], []string{filepath.Join(tmpDir, "no-mirror"), remotePath})
+		require.NoError(t, err)
+
+		checkout := Checkout{Dir: dir, VCS: Git}
+		revision, err := checkout.Revision()
+		require.NoError(t, err)
+		require.Equal(t, revisions[0], revision)
+		content, err := ioutil.ReadFile(filepath.Join(dir, "file.go"))
+		require.NoError(t, err)
+		require.Equal(t, "v1", string(content))
+	}
+
+	t.Log("Existing checkout - checks out the revision")
+	{
+		require.NoError(t, Restore(Git, dir, remotePath, revisions[1], []string{remotePath}))
+		revision, err := Checkout{Dir: dir, VCS: Git}.Revision()
+		require.NoError(t, err)
+		require.Equal(t, revisions[1], revision)
+	}
+
+	t.Log("Clone from a mirror")
+	{
+		mirrorDir := filepath.Join(tmpDir, "mirror-clone")
+		require.NoError(t, Restore(Git, mirrorDir, "https://example.com/org/lib.git", revisions[1], []string{dir}))
+
+		remote, err := Checkout{Dir: mirrorDir, VCS: Git}.Remote()
+		require.NoError(t, err)
+		require.Equal(t, "https://example.com/org/lib.git", remote)
+	}
+
+	t.Log("Uncommitted changes")
+	{
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "file.go"), []byte("changed"), 0644))
+		err := Restore(Git, dir, remotePath, revisions[0], []string{remotePath})
+		require.EqualError(t, err, "The checkout ("+dir+") has uncommitted changes")
+	}
+
+	t.Log("A source is never an option")
+	{
+		markerPth := filepath.Join(tmpDir, "pwned")
+		err := Restore(Git, filepath.Join(tmpDir, "option"), "", revisions[0], []string{"--upload-pack=touch " + markerPth})
+		require.Error(t, err)
+		_, err = os.Stat(markerPth)
+		require.True(t, os.IsNotExist(err))
+	}
+
+	t.Log("Unknown revision")
+	{
+		err := Restore(Git, filepath.Join(tmpDir, "unknown"), remotePath, "0123456789012345678901234567890123456789", []string{remotePath})
+		require.Error(t, err)
+		_, err = os.Stat(filepath.Join(tmpDir, "unknown"))
+		require.True(t, os.IsNotExist(err))
+	}
+}